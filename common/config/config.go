@@ -2,9 +2,11 @@ package config
 
 import (
 	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"io/ioutil"
 	"log"
+	"math"
 	"math/big"
 	"os"
 	"time"
@@ -14,6 +16,11 @@ const (
 	DefaultConfigFilename = "./config.json"
 	MINGENBLOCKTIME       = 2
 	DEFAULTGENBLOCKTIME   = 6
+	DefaultStoreBackend   = "leveldb"
+
+	// MinPruneBlocks is the number of recent blocks a pruned node keeps at
+	// least, the deepest reorganization it can follow.
+	MinPruneBlocks = 288
 )
 
 var (
@@ -29,6 +36,42 @@ var (
 		MaxOrphanBlocks:    10000,
 		MinMemoryNodes:     20160,
 		SpendCoinbaseSpan:  100,
+		Checkpoints:        []Checkpoint{},
+
+		RuleChangeActivationThreshold: 684, // 95% of MinerConfirmationWindow
+		MinerConfirmationWindow:       720,
+		Deployments: []Deployment{
+			{
+				Name:      DeploymentSequenceLocks,
+				Bit:       0,
+				StartTime: 1798761600, // January 1, 2027 UTC
+				Timeout:   1830297600, // January 1, 2028 UTC
+			},
+			{
+				Name:      DeploymentBalanceInputs,
+				Bit:       1,
+				StartTime: 1798761600, // January 1, 2027 UTC
+				Timeout:   1830297600, // January 1, 2028 UTC
+			},
+			{
+				Name:      DeploymentAssetIssuance,
+				Bit:       2,
+				StartTime: 1798761600, // January 1, 2027 UTC
+				Timeout:   1830297600, // January 1, 2028 UTC
+			},
+			{
+				Name:      DeploymentScripts,
+				Bit:       3,
+				StartTime: 1798761600, // January 1, 2027 UTC
+				Timeout:   1830297600, // January 1, 2028 UTC
+			},
+			{
+				Name:      DeploymentSigHashTypes,
+				Bit:       4,
+				StartTime: 1798761600, // January 1, 2027 UTC
+				Timeout:   1830297600, // January 1, 2028 UTC
+			},
+		},
 	}
 	testNet = &ChainParams{
 		Name:               "TestNet",
@@ -40,6 +83,48 @@ var (
 		MaxOrphanBlocks:    10000,
 		MinMemoryNodes:     20160,
 		SpendCoinbaseSpan:  100,
+		Checkpoints:        []Checkpoint{},
+
+		RuleChangeActivationThreshold: 8, // 75% of MinerConfirmationWindow
+		MinerConfirmationWindow:       10,
+		Deployments: []Deployment{
+			{
+				Name:      DeploymentTestDummy,
+				Bit:       28,
+				StartTime: 1514764800, // January 1, 2018 UTC
+				Timeout:   math.MaxInt64,
+			},
+			{
+				Name:      DeploymentSequenceLocks,
+				Bit:       0,
+				StartTime: 1514764800, // January 1, 2018 UTC
+				Timeout:   math.MaxInt64,
+			},
+			{
+				Name:      DeploymentBalanceInputs,
+				Bit:       1,
+				StartTime: 1514764800, // January 1, 2018 UTC
+				Timeout:   math.MaxInt64,
+			},
+			{
+				Name:      DeploymentAssetIssuance,
+				Bit:       2,
+				StartTime: 1514764800, // January 1, 2018 UTC
+				Timeout:   math.MaxInt64,
+			},
+			{
+				Name:      DeploymentScripts,
+				Bit:       3,
+				StartTime: 1514764800, // January 1, 2018 UTC
+				Timeout:   math.MaxInt64,
+			},
+			{
+				Name:      DeploymentSigHashTypes,
+				Bit:       4,
+				StartTime: 1514764800, // January 1, 2018 UTC
+				Timeout:   math.MaxInt64,
+			},
+		},
 	}
 	regNet = &ChainParams{
 		Name:               "RegNet",
@@ -51,6 +136,47 @@ var (
 		MaxOrphanBlocks:    10000,
 		MinMemoryNodes:     20160,
 		SpendCoinbaseSpan:  100,
+
+		RuleChangeActivationThreshold: 8, // 75% of MinerConfirmationWindow
+		MinerConfirmationWindow:       10,
+		Deployments: []Deployment{
+			{
+				Name:      DeploymentTestDummy,
+				Bit:       28,
+				StartTime: 0,
+				Timeout:   math.MaxInt64,
+			},
+			{
+				Name:      DeploymentSequenceLocks,
+				Bit:       0,
+				StartTime: 0,
+				Timeout:   math.MaxInt64,
+			},
+			{
+				Name:      DeploymentBalanceInputs,
+				Bit:       1,
+				StartTime: 0,
+				Timeout:   math.MaxInt64,
+			},
+			{
+				Name:      DeploymentAssetIssuance,
+				Bit:       2,
+				StartTime: 0,
+				Timeout:   math.MaxInt64,
+			},
+			{
+				Name:      DeploymentScripts,
+				Bit:       3,
+				StartTime: 0,
+				Timeout:   math.MaxInt64,
+			},
+			{
+				Name:      DeploymentSigHashTypes,
+				Bit:       4,
+				StartTime: 0,
+				Timeout:   math.MaxInt64,
+			},
+		},
 	}
 )
 
@@ -88,12 +214,65 @@ type Configuration struct {
 	MaxTxInBlock        int              `json:"MaxTransactionInBlock"`
 	MaxBlockSize        int              `json:"MaxBlockSize"`
 	PowConfiguration    PowConfiguration `json:"PowConfiguration"`
+	Checkpoints         []Checkpoint     `json:"Checkpoints"`
+	DataDir             string           `json:"DataDir"`
+	StoreBackend        string           `json:"StoreBackend"`
+	AddressIndex        bool             `json:"AddressIndex"`
+	SpentIndex          bool             `json:"SpentIndex"`
+	PruneBlocks         uint32           `json:"PruneBlocks"`
+	PruneSizeMB         uint32           `json:"PruneSizeMB"`
+	VerifyChainDepth    uint32           `json:"VerifyChainDepth"`
 }
 
 type ConfigFile struct {
 	ConfigFile Configuration `json:"Configuration"`
 }
 
+// Checkpoint is a block hash every node agrees on at the given height,
+// the hash is in the reversed hex form returned by getblockhash.
+type Checkpoint struct {
+	Height uint32 `json:"Height"`
+	Hash   string `json:"Hash"`
+}
+
+const (
+	// DeploymentTestDummy is a deployment without rule changes, for
+	// testing the version bits signalling.
+	DeploymentTestDummy = "testdummy"
+
+	// DeploymentSequenceLocks enables time based lock times compared to
+	// the median time past, and relative lock times in input sequences.
+	DeploymentSequenceLocks = "sequencelocks"
+
+	// DeploymentBalanceInputs enables balance inputs, and outputs of the
+	// Balance record type assets.
+	DeploymentBalanceInputs = "balanceinputs"
+
+	// DeploymentAssetIssuance enables IssueAsset transactions, and outputs
+	// of every registered asset.
+	DeploymentAssetIssuance = "assetissuance"
+
+	// DeploymentScripts runs the programs of transactions through the
+	// script engine, only single and multi signature codes are verified
+	// before.
+	DeploymentScripts = "scripts"
+
+	// DeploymentSigHashTypes accepts the signatures with a hash type, which
+	// sign the signature hash of the transaction, in the programs run
+	// through the script engine.
+	DeploymentSigHashTypes = "sighashtypes"
+)
+
+// Deployment is a consensus rule change deployed by miners signalling Bit
+// in the block version, as described by BIP9. StartTime and Timeout are
+// unix times compared to the median time past of the blocks.
+type Deployment struct {
+	Name      string
+	Bit       uint8
+	StartTime int64
+	Timeout   int64
+}
+
 type ChainParams struct {
 	Name               string
 	PowLimit           *big.Int
@@ -104,6 +283,13 @@ type ChainParams struct {
 	MaxOrphanBlocks    int
 	MinMemoryNodes     uint32
 	SpendCoinbaseSpan  uint32
+	Checkpoints        []Checkpoint
+
+	// A deployment is locked in once RuleChangeActivationThreshold of the
+	// MinerConfirmationWindow blocks of a retarget window signal it.
+	RuleChangeActivationThreshold uint32
+	MinerConfirmationWindow       uint32
+	Deployments                   []Deployment
 }
 
 type configParams struct {
@@ -128,6 +314,13 @@ func init() {
 	}
 	//	Parameters = &(config.ConfigFile)
 	Parameters.Configuration = &(config.ConfigFile)
+	if Parameters.StoreBackend == "" {
+		Parameters.StoreBackend = DefaultStoreBackend
+	}
+	if Parameters.PruneBlocks > 0 && Parameters.PruneBlocks < MinPruneBlocks {
+		log.Printf("PruneBlocks %d is below the minimum, keep %d blocks", Parameters.PruneBlocks, MinPruneBlocks)
+		Parameters.PruneBlocks = MinPruneBlocks
+	}
 	if Parameters.PowConfiguration.ActiveNet == "MainNet" {
 		Parameters.ChainParam = mainNet
 	} else if Parameters.PowConfiguration.ActiveNet == "TestNet" {
@@ -136,4 +329,23 @@ func init() {
 		Parameters.ChainParam = regNet
 	}
 
+	// checkpoints from the config file are added to the built-in ones,
+	// replacing the built-in checkpoint at the same height.
+	for _, cp := range Parameters.Checkpoints {
+		if hash, e := hex.DecodeString(cp.Hash); e != nil || len(hash) != 32 {
+			log.Fatalf("Invalid checkpoint hash %s at height %d", cp.Hash, cp.Height)
+			os.Exit(1)
+		}
+		if Parameters.ChainParam == nil {
+			continue
+		}
+		checkpoints := make([]Checkpoint, 0, len(Parameters.ChainParam.Checkpoints)+1)
+		for _, c := range Parameters.ChainParam.Checkpoints {
+			if c.Height != cp.Height {
+				checkpoints = append(checkpoints, c)
+			}
+		}
+		Parameters.ChainParam.Checkpoints = append(checkpoints, cp)
+	}
+
 }
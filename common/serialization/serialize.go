@@ -247,6 +247,10 @@ func ToArray(data SerializableData) []byte {
 
 func byteXReader(reader io.Reader, x uint64) ([]byte, error) {
 	p := make([]byte, x)
+	// an empty value may be the last one of the reader
+	if x == 0 {
+		return p, nil
+	}
 	n, err := reader.Read(p)
 	if n > 0 {
 		return p[:], nil
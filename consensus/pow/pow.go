@@ -19,6 +19,7 @@ import (
 	tx "Elastos.ELA/core/transaction"
 	"Elastos.ELA/core/transaction/payload"
 	"Elastos.ELA/crypto"
+	. "Elastos.ELA/errors"
 	"Elastos.ELA/events"
 	//	"ELA/net"
 )
@@ -34,10 +35,6 @@ const (
 
 var (
 	TargetTimePerBlock = int64(config.Parameters.ChainParam.TargetTimePerBlock / time.Second)
-
-	OrginAmountOfEla = 3300 * 10000 * 100000000
-	SubsidyInterval  = 365 * 24 * 60 * 60 / TargetTimePerBlock
-	RetargetPersent  = 25
 )
 
 type msgBlock struct {
@@ -125,20 +122,6 @@ func (pow *PowService) CreateCoinbaseTrx(nextBlockHeight uint32, addr string) (*
 	return txn, nil
 }
 
-func calcBlockSubsidy(currentHeight uint32) int64 {
-	ToTalAmountOfEla := int64(OrginAmountOfEla)
-	for i := uint32(0); i < (currentHeight / uint32(SubsidyInterval)); i++ {
-		incr := float64(ToTalAmountOfEla) / float64(RetargetPersent)
-		subsidyPerBlock := int64(float64(incr) / float64(SubsidyInterval))
-		ToTalAmountOfEla += subsidyPerBlock * int64(SubsidyInterval)
-	}
-	incr := float64(ToTalAmountOfEla) / float64(RetargetPersent)
-	subsidyPerBlock := int64(float64(incr) / float64(SubsidyInterval))
-	log.Trace("subsidyPerBlock: ", subsidyPerBlock)
-
-	return subsidyPerBlock
-}
-
 type txSorter []*tx.Transaction
 
 func (s txSorter) Len() int {
@@ -161,7 +144,7 @@ func (pow *PowService) GenerateBlock(addr string) (*ledger.Block, error) {
 	}
 
 	blockData := &ledger.Blockdata{
-		Version:          0,
+		Version:          ledger.DefaultLedger.Blockchain.ComputeBlockVersion(ledger.DefaultLedger.Blockchain.BestChain),
 		PrevBlockHash:    *ledger.DefaultLedger.Blockchain.BestChain.Hash,
 		TransactionsRoot: Uint256{},
 		Timestamp:        uint32(ledger.DefaultLedger.Blockchain.MedianAdjustedTime().Unix()),
@@ -196,7 +179,7 @@ func (pow *PowService) GenerateBlock(addr string) (*ledger.Block, error) {
 			break
 		}
 
-		if !ledger.IsFinalizedTransaction(tx, nextBlockHeight) {
+		if ledger.DefaultLedger.Blockchain.CheckTransactionFinality(tx) != Success {
 			continue
 		}
 		fee := tx.GetFee(ledger.DefaultLedger.Blockchain.AssetID)
@@ -209,11 +192,11 @@ func (pow *PowService) GenerateBlock(addr string) (*ledger.Block, error) {
 		totalFee += fee
 	}
 
-	subsidy := calcBlockSubsidy(nextBlockHeight)
-	reward := totalFee + subsidy
-	reward_foundation := Fixed64(float64(reward) * 0.3)
+	subsidy := ledger.CalcBlockSubsidy(nextBlockHeight)
+	reward := Fixed64(totalFee) + subsidy
+	reward_foundation := ledger.CalcFoundationReward(reward)
 	msgBlock.Transactions[0].Outputs[0].Value = reward_foundation
-	msgBlock.Transactions[0].Outputs[1].Value = Fixed64(reward) - reward_foundation
+	msgBlock.Transactions[0].Outputs[1].Value = reward - reward_foundation
 
 	txHash := []Uint256{}
 	for _, tx := range msgBlock.Transactions {
@@ -345,13 +328,15 @@ func (pow *PowService) Halt() {
 }
 
 func (pow *PowService) RollbackTransaction(v interface{}) {
-	if block, ok := v.(*ledger.Block); ok {
-		for _, tx := range block.Transactions[1:] {
-			err := pow.localNet.MaybeAcceptTransaction(tx)
-			if err == nil {
-				pow.localNet.RemoveTransaction(tx)
-			} else {
-				log.Error(err)
+	if blocks, ok := v.([]*ledger.Block); ok {
+		for _, block := range blocks {
+			for _, tx := range block.Transactions[1:] {
+				err := pow.localNet.MaybeAcceptTransaction(tx)
+				if err == nil {
+					pow.localNet.RemoveTransaction(tx)
+				} else {
+					log.Error(err)
+				}
 			}
 		}
 	}
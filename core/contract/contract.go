@@ -9,6 +9,10 @@ import (
 	"Elastos.ELA/common/serialization"
 )
 
+// MaxScriptSize is the maximum size of the code and of the parameter of a
+// program.
+const MaxScriptSize = 10000
+
 //Contract address is the hash of contract program .
 //which be used to control asset or indicate the smart contract address
 
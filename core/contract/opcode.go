@@ -0,0 +1,59 @@
+package contract
+
+// The opcodes of the program scripts. A program is run by executing its
+// parameter, which may only push data, followed by its code.
+const (
+	OP_0         = 0x00 // push an empty item
+	OP_DATA_1    = 0x01 // 0x01-0x4b push the next n bytes
+	OP_DATA_75   = 0x4b
+	OP_PUSHDATA1 = 0x4c // push the bytes counted by the next byte
+	OP_PUSHDATA2 = 0x4d // push the bytes counted by the next 2 bytes
+	OP_PUSHDATA4 = 0x4e // push the bytes counted by the next 4 bytes
+	OP_1NEGATE   = 0x4f
+	OP_1         = 0x51 // 0x51-0x60 push the number 1-16
+	OP_16        = 0x60
+
+	OP_NOP    = 0x61
+	OP_IF     = 0x63
+	OP_NOTIF  = 0x64
+	OP_ELSE   = 0x67
+	OP_ENDIF  = 0x68
+	OP_VERIFY = 0x69
+	OP_RETURN = 0x6a
+
+	OP_DROP = 0x75
+	OP_DUP  = 0x76
+	OP_SWAP = 0x7c
+	OP_SIZE = 0x82
+
+	OP_EQUAL       = 0x87
+	OP_EQUALVERIFY = 0x88
+
+	OP_SHA256  = 0xa8
+	OP_HASH160 = 0xa9
+	OP_HASH256 = 0xaa
+
+	OP_CHECKSIG            = 0xac
+	OP_CHECKSIGVERIFY      = 0xad
+	OP_CHECKMULTISIG       = 0xae
+	OP_CHECKMULTISIGVERIFY = 0xaf
+
+	OP_CHECKLOCKTIMEVERIFY = 0xb1
+)
+
+// isKnownOpcode reports whether the opcode is supported by the script
+// engine, a script with any other opcode is invalid.
+func isKnownOpcode(op byte) bool {
+	if op <= OP_1NEGATE || (op >= OP_1 && op <= OP_16) {
+		return true
+	}
+	switch op {
+	case OP_NOP, OP_IF, OP_NOTIF, OP_ELSE, OP_ENDIF, OP_VERIFY, OP_RETURN,
+		OP_DROP, OP_DUP, OP_SWAP, OP_SIZE, OP_EQUAL, OP_EQUALVERIFY,
+		OP_SHA256, OP_HASH160, OP_HASH256,
+		OP_CHECKSIG, OP_CHECKSIGVERIFY, OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY,
+		OP_CHECKLOCKTIMEVERIFY:
+		return true
+	}
+	return false
+}
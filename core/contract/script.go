@@ -0,0 +1,505 @@
+package contract
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/golang/crypto/ripemd160"
+)
+
+const (
+	// MaxScriptElementSize is the maximum size of an item pushed on the
+	// stack.
+	MaxScriptElementSize = 520
+
+	// MaxOpsPerScript is the maximum number of operations other than
+	// pushes run by a program, the public keys of a multi sign check are
+	// counted as operations too.
+	MaxOpsPerScript = 201
+
+	// MaxStackSize is the maximum number of items on the stack.
+	MaxStackSize = 1000
+
+	// MaxPubKeysPerMultiSig is the maximum number of public keys of a
+	// multi sign check.
+	MaxPubKeysPerMultiSig = 20
+
+	// the maximum length of a number operand, lock times may take 5 bytes
+	// to cover the whole uint32 range
+	maxScriptNumLength   = 4
+	maxLockTimeNumLength = 5
+)
+
+// SignatureChecker checks the signatures and lock times of a program
+// against the transaction it belongs to.
+type SignatureChecker interface {
+	// CheckSignature reports whether signature is a valid signature of
+	// the transaction by the encoded public key.
+	CheckSignature(publicKey, signature []byte) bool
+
+	// CheckLockTime reports whether the transaction can only be included
+	// in the chain once lockTime has passed.
+	CheckLockTime(lockTime uint32) bool
+}
+
+type parsedOpcode struct {
+	opcode byte
+	data   []byte
+}
+
+// parseScript splits a script into its opcodes and the data they push.
+func parseScript(script []byte) ([]parsedOpcode, error) {
+	if len(script) > MaxScriptSize {
+		return nil, errors.New(fmt.Sprintf("script size %d exceeds %d", len(script), MaxScriptSize))
+	}
+
+	var ops []parsedOpcode
+	for i := 0; i < len(script); {
+		op := script[i]
+		i++
+		if !isKnownOpcode(op) {
+			return nil, errors.New(fmt.Sprintf("unknown opcode 0x%02x", op))
+		}
+
+		var size uint64
+		switch {
+		case op >= OP_DATA_1 && op <= OP_DATA_75:
+			size = uint64(op)
+		case op == OP_PUSHDATA1, op == OP_PUSHDATA2, op == OP_PUSHDATA4:
+			width := 1 << (op - OP_PUSHDATA1)
+			if len(script)-i < width {
+				return nil, errors.New("truncated push data length")
+			}
+			length := make([]byte, 8)
+			copy(length, script[i:i+width])
+			size = binary.LittleEndian.Uint64(length)
+			i += width
+		}
+		if size > uint64(len(script)-i) {
+			return nil, errors.New("truncated push data")
+		}
+		ops = append(ops, parsedOpcode{opcode: op, data: script[i : i+int(size)]})
+		i += int(size)
+	}
+
+	return ops, nil
+}
+
+type engine struct {
+	checker   SignatureChecker
+	stack     [][]byte
+	condStack []bool
+	opCount   int
+}
+
+// ExecuteProgram runs the parameter of a program followed by its code on
+// one stack. The parameter may only push data. The program succeeds when
+// it leaves exactly one item on the stack and that item is true.
+func ExecuteProgram(code, param []byte, checker SignatureChecker) error {
+	if len(code) == 0 {
+		return errors.New("empty program code")
+	}
+	paramOps, err := parseScript(param)
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid program parameter: %v", err))
+	}
+	for _, op := range paramOps {
+		if op.opcode > OP_16 {
+			return errors.New("program parameter is not push only")
+		}
+	}
+	codeOps, err := parseScript(code)
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid program code: %v", err))
+	}
+
+	e := &engine{checker: checker}
+	if err := e.run(paramOps); err != nil {
+		return err
+	}
+	if err := e.run(codeOps); err != nil {
+		return err
+	}
+
+	if len(e.stack) != 1 {
+		return errors.New(fmt.Sprintf("program finished with %d items on the stack", len(e.stack)))
+	}
+	if !asBool(e.stack[0]) {
+		return errors.New("program evaluated to false")
+	}
+
+	return nil
+}
+
+func (e *engine) run(ops []parsedOpcode) error {
+	for _, op := range ops {
+		if err := e.step(op); err != nil {
+			return err
+		}
+	}
+	if len(e.condStack) != 0 {
+		return errors.New("unbalanced conditional")
+	}
+
+	return nil
+}
+
+// executing reports whether all the enclosing conditional branches are
+// taken.
+func (e *engine) executing() bool {
+	for _, cond := range e.condStack {
+		if !cond {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *engine) step(op parsedOpcode) error {
+	if len(op.data) > MaxScriptElementSize {
+		return errors.New(fmt.Sprintf("push of %d bytes exceeds %d", len(op.data), MaxScriptElementSize))
+	}
+	if op.opcode > OP_16 {
+		if err := e.countOps(1); err != nil {
+			return err
+		}
+	}
+
+	// Conditionals are tracked in the branches not taken too.
+	executing := e.executing()
+	switch op.opcode {
+	case OP_IF, OP_NOTIF:
+		cond := false
+		if executing {
+			item, err := e.pop()
+			if err != nil {
+				return err
+			}
+			cond = asBool(item) == (op.opcode == OP_IF)
+		}
+		e.condStack = append(e.condStack, cond)
+		return nil
+
+	case OP_ELSE:
+		if len(e.condStack) == 0 {
+			return errors.New("OP_ELSE without OP_IF")
+		}
+		e.condStack[len(e.condStack)-1] = !e.condStack[len(e.condStack)-1]
+		return nil
+
+	case OP_ENDIF:
+		if len(e.condStack) == 0 {
+			return errors.New("OP_ENDIF without OP_IF")
+		}
+		e.condStack = e.condStack[:len(e.condStack)-1]
+		return nil
+	}
+	if !executing {
+		return nil
+	}
+
+	switch {
+	case op.opcode <= OP_PUSHDATA4:
+		e.push(op.data)
+	case op.opcode == OP_1NEGATE:
+		e.push(scriptNumBytes(-1))
+	case op.opcode >= OP_1 && op.opcode <= OP_16:
+		e.push(scriptNumBytes(int64(op.opcode - OP_1 + 1)))
+	default:
+		if err := e.execute(op.opcode); err != nil {
+			return err
+		}
+	}
+	if len(e.stack) > MaxStackSize {
+		return errors.New(fmt.Sprintf("stack size exceeds %d", MaxStackSize))
+	}
+
+	return nil
+}
+
+func (e *engine) execute(opcode byte) error {
+	switch opcode {
+	case OP_NOP:
+
+	case OP_VERIFY:
+		item, err := e.pop()
+		if err != nil {
+			return err
+		}
+		if !asBool(item) {
+			return errors.New("OP_VERIFY failed")
+		}
+
+	case OP_RETURN:
+		return errors.New("OP_RETURN executed")
+
+	case OP_DROP:
+		if _, err := e.pop(); err != nil {
+			return err
+		}
+
+	case OP_DUP:
+		item, err := e.peek()
+		if err != nil {
+			return err
+		}
+		e.push(item)
+
+	case OP_SWAP:
+		if len(e.stack) < 2 {
+			return errors.New("stack underflow")
+		}
+		n := len(e.stack)
+		e.stack[n-1], e.stack[n-2] = e.stack[n-2], e.stack[n-1]
+
+	case OP_SIZE:
+		item, err := e.peek()
+		if err != nil {
+			return err
+		}
+		e.push(scriptNumBytes(int64(len(item))))
+
+	case OP_EQUAL, OP_EQUALVERIFY:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		return e.result(bytes.Equal(a, b), opcode == OP_EQUALVERIFY, "OP_EQUALVERIFY failed")
+
+	case OP_SHA256, OP_HASH160, OP_HASH256:
+		item, err := e.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(item)
+		switch opcode {
+		case OP_SHA256:
+			e.push(hash[:])
+		case OP_HASH160:
+			md := ripemd160.New()
+			md.Write(hash[:])
+			e.push(md.Sum(nil))
+		case OP_HASH256:
+			hash = sha256.Sum256(hash[:])
+			e.push(hash[:])
+		}
+
+	case OP_CHECKSIG, OP_CHECKSIGVERIFY:
+		publicKey, err := e.pop()
+		if err != nil {
+			return err
+		}
+		signature, err := e.pop()
+		if err != nil {
+			return err
+		}
+		valid := e.checker.CheckSignature(publicKey, signature)
+		return e.result(valid, opcode == OP_CHECKSIGVERIFY, "OP_CHECKSIGVERIFY failed")
+
+	case OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY:
+		valid, err := e.checkMultiSig()
+		if err != nil {
+			return err
+		}
+		return e.result(valid, opcode == OP_CHECKMULTISIGVERIFY, "OP_CHECKMULTISIGVERIFY failed")
+
+	case OP_CHECKLOCKTIMEVERIFY:
+		// The lock time is left on the stack.
+		item, err := e.peek()
+		if err != nil {
+			return err
+		}
+		lockTime, err := makeScriptNum(item, maxLockTimeNumLength)
+		if err != nil {
+			return err
+		}
+		if lockTime < 0 || lockTime > math.MaxUint32 {
+			return errors.New(fmt.Sprintf("invalid lock time %d", lockTime))
+		}
+		if !e.checker.CheckLockTime(uint32(lockTime)) {
+			return errors.New(fmt.Sprintf("transaction is not locked until %d", lockTime))
+		}
+
+	default:
+		return errors.New(fmt.Sprintf("unknown opcode 0x%02x", opcode))
+	}
+
+	return nil
+}
+
+// checkMultiSig pops n, the n public keys, m and the m signatures. It
+// reports whether every signature is made by a different one of the keys,
+// the signatures may be in any order.
+func (e *engine) checkMultiSig() (bool, error) {
+	n, err := e.popNum()
+	if err != nil {
+		return false, err
+	}
+	if n < 0 || n > MaxPubKeysPerMultiSig {
+		return false, errors.New(fmt.Sprintf("invalid multi sign public key count %d", n))
+	}
+	if err := e.countOps(int(n)); err != nil {
+		return false, err
+	}
+	publicKeys := make([][]byte, n)
+	for i := len(publicKeys) - 1; i >= 0; i-- {
+		if publicKeys[i], err = e.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	m, err := e.popNum()
+	if err != nil {
+		return false, err
+	}
+	if m < 0 || m > n {
+		return false, errors.New(fmt.Sprintf("invalid multi sign signature count %d of %d", m, n))
+	}
+	signatures := make([][]byte, m)
+	for i := len(signatures) - 1; i >= 0; i-- {
+		if signatures[i], err = e.pop(); err != nil {
+			return false, err
+		}
+	}
+
+	used := make([]bool, n)
+	for _, signature := range signatures {
+		signed := false
+		for i, publicKey := range publicKeys {
+			if !used[i] && e.checker.CheckSignature(publicKey, signature) {
+				used[i] = true
+				signed = true
+				break
+			}
+		}
+		if !signed {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// result pushes the result of a check, or fails on a false result of the
+// VERIFY form of the check.
+func (e *engine) result(valid, verify bool, failure string) error {
+	if !verify {
+		e.push(boolBytes(valid))
+		return nil
+	}
+	if !valid {
+		return errors.New(failure)
+	}
+	return nil
+}
+
+func (e *engine) countOps(n int) error {
+	e.opCount += n
+	if e.opCount > MaxOpsPerScript {
+		return errors.New(fmt.Sprintf("operation count exceeds %d", MaxOpsPerScript))
+	}
+	return nil
+}
+
+func (e *engine) push(item []byte) {
+	e.stack = append(e.stack, item)
+}
+
+func (e *engine) pop() ([]byte, error) {
+	item, err := e.peek()
+	if err != nil {
+		return nil, err
+	}
+	e.stack = e.stack[:len(e.stack)-1]
+	return item, nil
+}
+
+func (e *engine) peek() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, errors.New("stack underflow")
+	}
+	return e.stack[len(e.stack)-1], nil
+}
+
+func (e *engine) popNum() (int64, error) {
+	item, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+	return makeScriptNum(item, maxScriptNumLength)
+}
+
+// makeScriptNum decodes a little endian number whose most significant bit
+// is the sign.
+func makeScriptNum(b []byte, maxLength int) (int64, error) {
+	if len(b) > maxLength {
+		return 0, errors.New(fmt.Sprintf("number operand of %d bytes exceeds %d", len(b), maxLength))
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	var n int64
+	for i, v := range b {
+		n |= int64(v) << uint(8*i)
+	}
+	if b[len(b)-1]&0x80 != 0 {
+		n &= ^(int64(0x80) << uint(8*(len(b)-1)))
+		return -n, nil
+	}
+
+	return n, nil
+}
+
+func scriptNumBytes(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+
+	negative := n < 0
+	if negative {
+		n = -n
+	}
+	var b []byte
+	for n > 0 {
+		b = append(b, byte(n&0xff))
+		n >>= 8
+	}
+	if b[len(b)-1]&0x80 != 0 {
+		sign := byte(0)
+		if negative {
+			sign = 0x80
+		}
+		b = append(b, sign)
+	} else if negative {
+		b[len(b)-1] |= 0x80
+	}
+
+	return b
+}
+
+// asBool is false for an empty item and for any encoding of zero,
+// negative zero included.
+func asBool(b []byte) bool {
+	for i, v := range b {
+		if v != 0 {
+			return i != len(b)-1 || v != 0x80
+		}
+	}
+	return false
+}
+
+func boolBytes(v bool) []byte {
+	if v {
+		return []byte{1}
+	}
+	return nil
+}
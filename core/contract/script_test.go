@@ -0,0 +1,285 @@
+package contract
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testChecker accepts the signature of public key i made of 64 bytes i,
+// and the lock times up to lockTime.
+type testChecker struct {
+	lockTime uint32
+}
+
+func (c *testChecker) CheckSignature(publicKey, signature []byte) bool {
+	return len(publicKey) == 33 && len(signature) == 64 &&
+		bytes.Equal(signature, bytes.Repeat(publicKey[1:2], 64))
+}
+
+func (c *testChecker) CheckLockTime(lockTime uint32) bool {
+	return lockTime <= c.lockTime
+}
+
+func testPublicKey(i byte) []byte {
+	return append([]byte{0x02}, bytes.Repeat([]byte{i}, 32)...)
+}
+
+func testSignature(i byte) []byte {
+	return bytes.Repeat([]byte{i}, 64)
+}
+
+// pushData returns the shortest push of data.
+func pushData(data []byte) []byte {
+	switch {
+	case len(data) <= OP_DATA_75:
+		return append([]byte{byte(len(data))}, data...)
+	case len(data) <= 0xff:
+		return append([]byte{OP_PUSHDATA1, byte(len(data))}, data...)
+	default:
+		return append([]byte{OP_PUSHDATA2, byte(len(data)), byte(len(data) >> 8)}, data...)
+	}
+}
+
+// opNum returns the opcode pushing the number n, 1-16.
+func opNum(n int) byte {
+	return byte(OP_1 + n - 1)
+}
+
+func script(parts ...[]byte) []byte {
+	var s []byte
+	for _, part := range parts {
+		s = append(s, part...)
+	}
+	return s
+}
+
+// standardCode is the single public key layout, 0x21 || key || CHECKSIG.
+func standardCode(i byte) []byte {
+	return script(pushData(testPublicKey(i)), []byte{OP_CHECKSIG})
+}
+
+// multiSignCode is the m of n layout, m || 0x21 || key ... || n ||
+// CHECKMULTISIG.
+func multiSignCode(m int, keys ...byte) []byte {
+	code := []byte{opNum(m)}
+	for _, i := range keys {
+		code = append(code, pushData(testPublicKey(i))...)
+	}
+	return append(code, opNum(len(keys)), OP_CHECKMULTISIG)
+}
+
+// signatures is the parameter layout, 0x40 || signature for each signer.
+func signatures(signers ...byte) []byte {
+	var param []byte
+	for _, i := range signers {
+		param = append(param, pushData(testSignature(i))...)
+	}
+	return param
+}
+
+func checkResult(t *testing.T, name string, err error, want string) {
+	if want == "" {
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("%s: got error %v, want %q", name, err, want)
+	}
+}
+
+func TestStandardProgram(t *testing.T) {
+	code := standardCode(1)
+	if len(code) != 35 || code[0] != 0x21 || code[34] != 0xac {
+		t.Fatalf("standard code layout %x", code)
+	}
+
+	tests := []struct {
+		name  string
+		param []byte
+		want  string
+	}{
+		{"signed", signatures(1), ""},
+		{"signed by another key", signatures(2), "evaluated to false"},
+		{"no signature", nil, "stack underflow"},
+		{"truncated signature", pushData(testSignature(1)[:63]), "evaluated to false"},
+		{"extra signature", signatures(1, 1), "2 items on the stack"},
+	}
+	for _, test := range tests {
+		err := ExecuteProgram(code, test.param, &testChecker{})
+		checkResult(t, test.name, err, test.want)
+	}
+}
+
+func TestMultiSignProgram(t *testing.T) {
+	code := multiSignCode(2, 1, 2, 3)
+	if code[0] != 0x52 || code[len(code)-2] != 0x53 || code[len(code)-1] != 0xae {
+		t.Fatalf("multi sign code layout %x", code)
+	}
+
+	tests := []struct {
+		name  string
+		param []byte
+		want  string
+	}{
+		{"in key order", signatures(1, 2), ""},
+		{"out of key order", signatures(3, 1), ""},
+		{"one unknown signer", signatures(1, 4), "evaluated to false"},
+		{"duplicated signature", signatures(2, 2), "evaluated to false"},
+		{"too few signatures", signatures(1), "stack underflow"},
+		{"too many signatures", signatures(1, 2, 3), "2 items on the stack"},
+	}
+	for _, test := range tests {
+		err := ExecuteProgram(code, test.param, &testChecker{})
+		checkResult(t, test.name, err, test.want)
+	}
+
+	// m may not exceed n, n may not exceed MaxPubKeysPerMultiSig
+	err := ExecuteProgram(script([]byte{opNum(3)}, pushData(testPublicKey(1)), pushData(testPublicKey(2)),
+		[]byte{opNum(2), OP_CHECKMULTISIG}), signatures(1, 2, 2), &testChecker{})
+	checkResult(t, "3 of 2", err, "invalid multi sign signature count")
+
+	var keys []byte
+	for i := byte(1); i <= MaxPubKeysPerMultiSig+1; i++ {
+		keys = append(keys, pushData(testPublicKey(i))...)
+	}
+	err = ExecuteProgram(script([]byte{OP_1}, keys, pushData(scriptNumBytes(MaxPubKeysPerMultiSig+1)),
+		[]byte{OP_CHECKMULTISIG}), signatures(1), &testChecker{})
+	checkResult(t, "too many keys", err, "invalid multi sign public key count")
+}
+
+func TestConditionals(t *testing.T) {
+	// IF (NOTIF 2 ELSE 3 ENDIF) ELSE 4 ENDIF
+	code := []byte{OP_IF, OP_NOTIF, opNum(2), OP_ELSE, opNum(3), OP_ENDIF, OP_ELSE, opNum(4), OP_ENDIF}
+	tests := []struct {
+		name  string
+		param []byte
+		want  int
+	}{
+		{"outer true, inner false", []byte{OP_0, OP_1}, 2},
+		{"outer true, inner true", []byte{OP_1, OP_1}, 3},
+		// the inner condition is not popped in the branch not taken
+		{"outer false", []byte{OP_0}, 4},
+	}
+	for _, test := range tests {
+		equal := script(code, []byte{opNum(test.want), OP_EQUAL})
+		err := ExecuteProgram(equal, test.param, &testChecker{})
+		checkResult(t, test.name, err, "")
+	}
+
+	invalid := []struct {
+		name string
+		code []byte
+		want string
+	}{
+		{"ELSE without IF", []byte{OP_ELSE, OP_1}, "OP_ELSE without OP_IF"},
+		{"ENDIF without IF", []byte{OP_ENDIF, OP_1}, "OP_ENDIF without OP_IF"},
+		{"IF without ENDIF", []byte{OP_IF, OP_1}, "unbalanced conditional"},
+		{"IF on an empty stack", []byte{OP_DROP, OP_IF, OP_ENDIF, OP_1}, "stack underflow"},
+		{"RETURN in a branch taken", []byte{OP_IF, OP_RETURN, OP_ENDIF, OP_1}, "OP_RETURN executed"},
+	}
+	for _, test := range invalid {
+		err := ExecuteProgram(test.code, []byte{OP_1}, &testChecker{})
+		checkResult(t, test.name, err, test.want)
+	}
+
+	err := ExecuteProgram([]byte{OP_IF, OP_RETURN, OP_ENDIF, OP_1}, []byte{OP_0}, &testChecker{})
+	checkResult(t, "RETURN in a branch not taken", err, "")
+}
+
+func TestLimits(t *testing.T) {
+	nops := func(n int) []byte { return bytes.Repeat([]byte{OP_NOP}, n) }
+	pushes := func(n int) []byte { return bytes.Repeat([]byte{OP_1}, n) }
+
+	tests := []struct {
+		name  string
+		code  []byte
+		param []byte
+		want  string
+	}{
+		{"max operations", script(nops(MaxOpsPerScript), []byte{OP_1}), nil, ""},
+		{"too many operations", script(nops(MaxOpsPerScript+1), []byte{OP_1}), nil, "operation count exceeds"},
+		{"keys counted as operations", script(nops(MaxOpsPerScript-2), multiSignCode(1, 1, 2)), signatures(1),
+			"operation count exceeds"},
+		{"max stack", []byte{OP_DROP}, pushes(MaxStackSize), "999 items on the stack"},
+		{"stack overflow", []byte{OP_1}, pushes(MaxStackSize), "stack size exceeds"},
+		{"max element", script([]byte{OP_SIZE}, pushData(scriptNumBytes(MaxScriptElementSize)),
+			[]byte{OP_EQUALVERIFY, OP_DROP, OP_1}), pushData(make([]byte, MaxScriptElementSize)), ""},
+		{"element too large", []byte{OP_DROP, OP_1}, pushData(make([]byte, MaxScriptElementSize+1)), "push of 521 bytes exceeds"},
+		{"code too large", script(pushes(MaxScriptSize), []byte{OP_1}), nil, "script size"},
+		{"parameter too large", []byte{OP_1}, pushes(MaxScriptSize + 1), "script size"},
+	}
+	for _, test := range tests {
+		err := ExecuteProgram(test.code, test.param, &testChecker{})
+		checkResult(t, test.name, err, test.want)
+	}
+}
+
+func TestCheckLockTimeVerify(t *testing.T) {
+	checker := &testChecker{lockTime: 1000}
+	tests := []struct {
+		name     string
+		lockTime []byte
+		want     string
+	}{
+		{"reached", scriptNumBytes(1000), ""},
+		{"not reached", scriptNumBytes(1001), "not locked until 1001"},
+		{"negative", scriptNumBytes(-1), "invalid lock time"},
+		{"5 byte number", scriptNumBytes(0xffffffff), "not locked until 4294967295"},
+		{"6 byte number", []byte{1, 0, 0, 0, 0, 0}, "exceeds"},
+	}
+	for _, test := range tests {
+		code := script(pushData(test.lockTime), []byte{OP_CHECKLOCKTIMEVERIFY, OP_DROP, OP_1})
+		err := ExecuteProgram(code, nil, checker)
+		checkResult(t, test.name, err, test.want)
+	}
+
+	err := ExecuteProgram([]byte{OP_CHECKLOCKTIMEVERIFY, OP_1}, nil, checker)
+	checkResult(t, "no lock time", err, "stack underflow")
+}
+
+func TestPushOnlyParameter(t *testing.T) {
+	code := standardCode(1)
+	tests := []struct {
+		name  string
+		param []byte
+		want  string
+	}{
+		{"data pushes", signatures(1), ""},
+		{"NOP", script(signatures(1), []byte{OP_NOP}), "not push only"},
+		{"DUP", script(signatures(1), []byte{OP_DUP, OP_DROP}), "not push only"},
+		{"CHECKSIG", script(signatures(1), pushData(testPublicKey(1)), []byte{OP_CHECKSIG}), "not push only"},
+		{"unknown opcode", script(signatures(1), []byte{0xff}), "unknown opcode"},
+	}
+	for _, test := range tests {
+		err := ExecuteProgram(code, test.param, &testChecker{})
+		checkResult(t, test.name, err, test.want)
+	}
+
+	// numbers may be pushed
+	err := ExecuteProgram([]byte{OP_16, OP_EQUALVERIFY, OP_1NEGATE, OP_EQUAL}, []byte{OP_1NEGATE, OP_16}, &testChecker{})
+	checkResult(t, "number pushes", err, "")
+}
+
+func TestTruncatedPushData(t *testing.T) {
+	tests := []struct {
+		name   string
+		script []byte
+	}{
+		{"DATA_5 with 2 bytes", []byte{0x05, 1, 2}},
+		{"PUSHDATA1 without length", []byte{OP_PUSHDATA1}},
+		{"PUSHDATA1 with 3 of 5 bytes", []byte{OP_PUSHDATA1, 5, 1, 2, 3}},
+		{"PUSHDATA2 with 1 length byte", []byte{OP_PUSHDATA2, 1}},
+		{"PUSHDATA2 with 1 of 256 bytes", []byte{OP_PUSHDATA2, 0, 1, 1}},
+		{"PUSHDATA4 with 3 length bytes", []byte{OP_PUSHDATA4, 1, 0, 0}},
+		{"PUSHDATA4 with 0 of 2^32-1 bytes", []byte{OP_PUSHDATA4, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, test := range tests {
+		err := ExecuteProgram(test.script, nil, &testChecker{})
+		checkResult(t, test.name+" in code", err, "truncated push data")
+		err = ExecuteProgram([]byte{OP_1}, test.script, &testChecker{})
+		checkResult(t, test.name+" in parameter", err, "truncated push data")
+	}
+}
@@ -0,0 +1,35 @@
+package ledger
+
+import (
+	. "Elastos.ELA/common"
+)
+
+// HistoryDirection tells whether a transaction paid an address or spent
+// outputs of it.
+type HistoryDirection byte
+
+const (
+	HistoryReceived HistoryDirection = iota
+	HistorySent
+)
+
+func (direction HistoryDirection) String() string {
+	switch direction {
+	case HistoryReceived:
+		return "received"
+	case HistorySent:
+		return "sent"
+	}
+	return "unknown"
+}
+
+// AddressHistory is the amount of an asset a transaction received to or
+// sent from an address. A transaction spending from and paying back to the
+// same address has one entry of each direction.
+type AddressHistory struct {
+	Height    uint32
+	TxID      Uint256
+	Direction HistoryDirection
+	AssetID   Uint256
+	Amount    Fixed64
+}
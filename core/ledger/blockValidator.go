@@ -4,13 +4,11 @@ import (
 	. "Elastos.ELA/common"
 	"Elastos.ELA/common/config"
 	"Elastos.ELA/core/auxpow"
-	tx "Elastos.ELA/core/transaction"
 	"Elastos.ELA/crypto"
 	. "Elastos.ELA/errors"
 
 	"errors"
 	"fmt"
-	"math"
 	"math/big"
 	"time"
 )
@@ -21,23 +19,8 @@ const (
 
 func PowCheckBlockSanity(block *Block, powLimit *big.Int, timeSource MedianTimeSource) error {
 	header := block.Blockdata
-	if !header.AuxPow.Check(header.Hash(), auxpow.AuxPowChainID) {
-		return errors.New("[PowCheckBlockSanity] block check proof is failed")
-	}
-	if CheckProofOfWork(header, powLimit) != nil {
-		return errors.New("[PowCheckBlockSanity] block check proof is failed.")
-	}
-
-	// A block timestamp must not have a greater precision than one second.
-	tempTime := time.Unix(int64(header.Timestamp), 0)
-	if !tempTime.Equal(time.Unix(tempTime.Unix(), 0)) {
-		return errors.New("[PowCheckBlockSanity] block timestamp of has a higher precision than one second")
-	}
-
-	// Ensure the block time is not too far in the future.
-	maxTimestamp := timeSource.AdjustedTime().Add(time.Second * MaxTimeOffsetSeconds)
-	if tempTime.After(maxTimestamp) {
-		return errors.New("[PowCheckBlockSanity] block timestamp of is too far in the future")
+	if err := checkHeaderSanity(header, powLimit, timeSource); err != nil {
+		return err
 	}
 
 	// A block must have at least one transaction.
@@ -104,13 +87,33 @@ func PowCheckBlockSanity(block *Block, powLimit *big.Int, timeSource MedianTimeS
 	return nil
 }
 
-func PowCheckBlockContext(block *Block, prevNode *BlockNode, ledger *Ledger) error {
-	// The genesis block is valid by definition.
-	if prevNode == nil {
-		return nil
+// checkHeaderSanity checks the proof of work and the timestamp of a header.
+func checkHeaderSanity(header *Blockdata, powLimit *big.Int, timeSource MedianTimeSource) error {
+	if !header.AuxPow.Check(header.Hash(), auxpow.AuxPowChainID) {
+		return errors.New("[PowCheckBlockSanity] block check proof is failed")
+	}
+	if CheckProofOfWork(header, powLimit) != nil {
+		return errors.New("[PowCheckBlockSanity] block check proof is failed.")
 	}
 
-	header := block.Blockdata
+	// A block timestamp must not have a greater precision than one second.
+	tempTime := time.Unix(int64(header.Timestamp), 0)
+	if !tempTime.Equal(time.Unix(tempTime.Unix(), 0)) {
+		return errors.New("[PowCheckBlockSanity] block timestamp of has a higher precision than one second")
+	}
+
+	// Ensure the block time is not too far in the future.
+	maxTimestamp := timeSource.AdjustedTime().Add(time.Second * MaxTimeOffsetSeconds)
+	if tempTime.After(maxTimestamp) {
+		return errors.New("[PowCheckBlockSanity] block timestamp of is too far in the future")
+	}
+
+	return nil
+}
+
+// checkHeaderContext checks the difficulty and the timestamp of a header
+// against the chain it extends.
+func checkHeaderContext(header *Blockdata, prevNode *BlockNode) error {
 	expectedDifficulty, err := CalcNextRequiredDifficulty(prevNode,
 		time.Unix(int64(header.Timestamp), 0))
 	if err != nil {
@@ -130,24 +133,34 @@ func PowCheckBlockContext(block *Block, prevNode *BlockNode, ledger *Ledger) err
 		return errors.New("block timestamp is not after expected")
 	}
 
+	return nil
+}
+
+func PowCheckBlockContext(block *Block, prevNode *BlockNode, ledger *Ledger) error {
+	// The genesis block is valid by definition.
+	if prevNode == nil {
+		return nil
+	}
+
+	if err := checkHeaderContext(block.Blockdata, prevNode); err != nil {
+		return err
+	}
+
 	// The height of this block is one more than the referenced
 	// previous block.
 	blockHeight := prevNode.Height + 1
 
-	// Ensure all transactions in the block are finalized.
+	// Ensure all transactions in the block are finalized. Time based lock
+	// times are compared to the median time past of the previous block.
+	ctx := ledger.Blockchain.newLockContext(prevNode)
 	for _, txn := range block.Transactions[1:] {
-		if !IsFinalizedTransaction(txn, blockHeight) {
+		if !IsFinalizedTransaction(txn, blockHeight, ctx.medianTime, ctx.sequenceLocks) {
 			return errors.New("block contains unfinalized transaction")
 		}
 	}
 
-
-	// for _, txVerify := range block.Transactions {
-	// 	if errCode := CheckTransactionContext(txVerify, ledger); errCode != ErrNoError {
-	// 		fmt.Println("CheckTransactionContext failed when verifiy block", errCode)
-	// 		return errors.New(fmt.Sprintf("CheckTransactionContext failed when verifiy block"))
-	// 	}
-	// }
+	// The transactions are checked against the ledger by ConnectBlock, once
+	// the block extends the best chain.
 
 	return nil
 }
@@ -177,25 +190,3 @@ func CheckProofOfWork(bd *Blockdata, powLimit *big.Int) error {
 	return nil
 }
 
-func IsFinalizedTransaction(msgTx *tx.Transaction, blockHeight uint32) bool {
-	// Lock time of zero means the transaction is finalized.
-	lockTime := msgTx.LockTime
-	if lockTime == 0 {
-		return true
-	}
-
-	//FIXME only height
-	if lockTime < blockHeight {
-		return true
-	}
-
-	// At this point, the transaction's lock time hasn't occurred yet, but
-	// the transaction might still be finalized if the sequence number
-	// for all transaction inputs is maxed out.
-	for _, txIn := range msgTx.UTXOInputs {
-		if txIn.Sequence != math.MaxUint16 {
-			return false
-		}
-	}
-	return true
-}
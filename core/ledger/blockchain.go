@@ -4,6 +4,7 @@ import (
 	. "Elastos.ELA/common"
 	"Elastos.ELA/common/config"
 	"Elastos.ELA/common/log"
+	tx "Elastos.ELA/core/transaction"
 	"Elastos.ELA/events"
 	"container/list"
 	"errors"
@@ -49,6 +50,9 @@ type Blockchain struct {
 	mutex          sync.RWMutex
 	Ledger         *Ledger
 	AssetID        Uint256
+	HeaderChain    *HeaderChain
+
+	deploymentCaches map[string]thresholdCache
 }
 
 func NewBlockchain(height uint32, ledger *Ledger) *Blockchain {
@@ -65,9 +69,10 @@ func NewBlockchain(height uint32, ledger *Ledger) *Blockchain {
 		BlockCache:   make(map[Uint256]*Block),
 		TimeSource:   NewMedianTime(),
 
-		BCEvents: events.NewEvent(),
-		Ledger:   ledger,
-		AssetID:  Uint256{},
+		BCEvents:    events.NewEvent(),
+		Ledger:      ledger,
+		AssetID:     Uint256{},
+		HeaderChain: NewHeaderChain(nil),
 	}
 }
 
@@ -85,9 +90,10 @@ func NewBlockchainWithGenesisBlock() (*Blockchain, error) {
 	DefaultLedger.Blockchain.AssetID = genesisBlock.Transactions[0].Outputs[0].AssetID
 	height, err := DefaultLedger.Store.InitLedgerStoreWithGenesisBlock(genesisBlock)
 	if err != nil {
-		return nil, errors.New("[Blockchain], InitLevelDBStoreWithGenesisBlock failed.")
+		return nil, errors.New(fmt.Sprintf("[Blockchain], InitLevelDBStoreWithGenesisBlock failed: %v", err))
 	}
 	DefaultLedger.Blockchain.UpdateBestHeight(height)
+	DefaultLedger.Blockchain.HeaderChain.Reset(DefaultLedger.Blockchain.BestChain)
 
 	return blockchain, nil
 }
@@ -278,6 +284,7 @@ type BlockNode struct {
 	Timestamp   uint32
 	WorkSum     *big.Int
 	InMainChain bool
+	Status      BlockStatus
 	Parent      *BlockNode
 	Children    []*BlockNode
 }
@@ -369,6 +376,7 @@ func (bc *Blockchain) LoadBlockNode(blockHeader *Blockdata, hash *Uint256) (*Blo
 	// Create the new block node for the block and set the work.
 	node := NewBlockNode(blockHeader, hash)
 	node.InMainChain = true
+	node.Status = StatusValidHeader | StatusValidData
 
 	// Add the node to the chain.
 	// There are several possibilities here:
@@ -639,17 +647,37 @@ func (bc *Blockchain) ReorganizeChain(detachNodes, attachNodes *list.List) error
 	//	}
 	//}
 
-	// Disconnect blocks from the main chain.
+	// Make sure every block to disconnect has its undo data, so the
+	// chain is not left half disconnected.
+	detachBlocks := make([]*Block, 0, detachNodes.Len())
 	for e := detachNodes.Front(); e != nil; e = e.Next() {
 		n := e.Value.(*BlockNode)
+		if _, err := bc.Ledger.Store.GetBlockUndo(*n.Hash); err != nil {
+			return fmt.Errorf("block %x has no undo data: %v",
+				n.Hash.ToArrayReverse(), err)
+		}
 		block, err := bc.Ledger.Store.GetBlock(*n.Hash)
 		if err != nil {
 			return err
 		}
-		err = bc.DisconnectBlock(n, block)
+		detachBlocks = append(detachBlocks, block)
+	}
+
+	// Journal the blocks being disconnected, a reorganization interrupted
+	// by a crash is undone from it when the store is opened again.
+	if err := bc.Ledger.Store.BeginReorganize(detachBlocks); err != nil {
+		return err
+	}
+
+	// Disconnect blocks from the main chain.
+	i := 0
+	for e := detachNodes.Front(); e != nil; e = e.Next() {
+		n := e.Value.(*BlockNode)
+		err := bc.DisconnectBlock(n, detachBlocks[i])
 		if err != nil {
-			return err
+			return bc.restoreReorganize(detachNodes, err)
 		}
+		i++
 	}
 
 	// Connect the new best chain blocks.
@@ -658,11 +686,16 @@ func (bc *Blockchain) ReorganizeChain(detachNodes, attachNodes *list.List) error
 		block := bc.BlockCache[*n.Hash]
 		err := bc.ConnectBlock(n, block)
 		if err != nil {
-			return err
+			return bc.restoreReorganize(detachNodes, err)
 		}
 		delete(bc.BlockCache, *n.Hash)
 	}
 
+	if err := bc.Ledger.Store.EndReorganize(); err != nil {
+		return err
+	}
+	bc.notifyRollbackTransactions(detachBlocks)
+
 	// Log the point where the chain forked.
 	//firstAttachNode := attachNodes.Front().Value.(*BlockNode)
 	//forkNode, err := bc.GetPrevNodeFromNode(firstAttachNode)
@@ -679,6 +712,49 @@ func (bc *Blockchain) ReorganizeChain(detachNodes, attachNodes *list.List) error
 	return nil
 }
 
+// restoreReorganize brings the main chain back to the detached blocks after
+// a failed reorganization and returns the error which caused it.
+func (bc *Blockchain) restoreReorganize(detachNodes *list.List, cause error) error {
+	log.Errorf("REORGANIZE: failed, restore the old best chain: %v", cause)
+	detached := make(map[Uint256]bool)
+	for e := detachNodes.Front(); e != nil; e = e.Next() {
+		detached[*e.Value.(*BlockNode).Hash] = true
+	}
+
+	// Disconnect the blocks of the new chain attached so far.
+	var disconnected []*Block
+	defer func() { bc.notifyRollbackTransactions(disconnected) }()
+	fork := detachNodes.Back().Value.(*BlockNode).Parent
+	for n := bc.BestChain; n.Hash.CompareTo(*fork.Hash) != 0 && !detached[*n.Hash]; n = bc.BestChain {
+		block, err := bc.Ledger.Store.GetBlock(*n.Hash)
+		if err != nil {
+			return fmt.Errorf("%v, restore failed: %v", cause, err)
+		}
+		if err := bc.DisconnectBlock(n, block); err != nil {
+			return fmt.Errorf("%v, restore failed: %v", cause, err)
+		}
+		disconnected = append(disconnected, block)
+	}
+
+	// Connect the old chain blocks again.
+	for e := detachNodes.Back(); e != nil; e = e.Prev() {
+		n := e.Value.(*BlockNode)
+		if n.InMainChain {
+			continue
+		}
+		if err := bc.ConnectBlock(n, bc.BlockCache[*n.Hash]); err != nil {
+			return fmt.Errorf("%v, restore failed: %v", cause, err)
+		}
+		delete(bc.BlockCache, *n.Hash)
+	}
+
+	if err := bc.Ledger.Store.EndReorganize(); err != nil {
+		return fmt.Errorf("%v, restore failed: %v", cause, err)
+	}
+
+	return cause
+}
+
 //// disconnectBlock handles disconnecting the passed node/block from the end of
 //// the main (best) chain.
 func (bc *Blockchain) DisconnectBlock(node *BlockNode, block *Block) error {
@@ -716,15 +792,64 @@ func (bc *Blockchain) DisconnectBlock(node *BlockNode, block *Block) error {
 	return nil
 }
 
+// checkReferencesAvailable returns an error if a transaction spent by txn
+// can not be read from the view.
+func checkReferencesAvailable(txn *tx.Transaction, view *UTXOView) error {
+	if txn.IsCoinBaseTx() {
+		return nil
+	}
+	for _, input := range txn.UTXOInputs {
+		if _, _, err := view.GetTransaction(input.ReferTxID); err != nil {
+			txHash := txn.Hash()
+			return errors.New(fmt.Sprintf("transaction %x spends unknown transaction %x: %v",
+				txHash.ToArrayReverse(), input.ReferTxID.ToArrayReverse(), err))
+		}
+	}
+
+	return nil
+}
+
 // connectBlock handles connecting the passed node/block to the end of the main
 // (best) chain.
 func (bc *Blockchain) ConnectBlock(node *BlockNode, block *Block) error {
 
+	// Signatures of the ancestors of a checkpoint are not verified.
+	checkSignature := !bc.isCheckpointAncestor(block.Blockdata.Height, *node.Hash)
+	view := NewUTXOView(bc.Ledger.Store)
+	lockCtx := bc.newLockContext(node.Parent)
+	rules := bc.newRuleContext(node.Parent)
 	for _, txVerify := range block.Transactions {
-		if errCode := CheckTransactionContext(txVerify, bc.Ledger); errCode != Success {
-			fmt.Println("CheckTransactionContext failed when verifiy block", errCode)
-			return errors.New(fmt.Sprintf("CheckTransactionContext failed when verifiy block"))
+		// Only a block breaking the consensus rules is marked invalid, a
+		// referenced transaction which can not be read may as well be a
+		// failure of the store.
+		if err := checkReferencesAvailable(txVerify, view); err != nil {
+			return err
+		}
+		// The signatures are verified below as one batch.
+		errCode := checkTransactionContext(txVerify, view, rules, false)
+		if errCode == Success {
+			errCode = bc.checkTransactionLocks(txVerify, view, lockCtx)
 		}
+		if errCode != Success {
+			bc.setInvalid(node)
+			txHash := txVerify.Hash()
+			return errors.New(fmt.Sprintf("CheckTransactionContext failed when verifiy block, transaction %x: %s",
+				txHash.ToArrayReverse(), errCode.Message()))
+		}
+		view.AddTransaction(txVerify)
+	}
+	if checkSignature {
+		if i, err := tx.VerifySignaturesFrom(block.Transactions[1:], view, rules.scriptFlags); err != nil {
+			log.Warn("[CheckTransactionSignature],", err)
+			bc.setInvalid(node)
+			txHash := block.Transactions[i+1].Hash()
+			return errors.New(fmt.Sprintf("CheckTransactionContext failed when verifiy block, transaction %x: %s",
+				txHash.ToArrayReverse(), ErrTransactionSignature.Message()))
+		}
+	}
+	if errCode := CheckBlockReward(block, view); errCode != Success {
+		bc.setInvalid(node)
+		return errors.New(fmt.Sprintf("CheckBlockReward failed when verifiy block, %s", errCode.Message()))
 	}
 
 	// Make sure it's extending the end of the best chain.
@@ -743,6 +868,7 @@ func (bc *Blockchain) ConnectBlock(node *BlockNode, block *Block) error {
 	// Add the new node to the memory main chain indices for faster
 	// lookups.
 	node.InMainChain = true
+	node.Status |= StatusValidData
 	//bc.Index[*node.Hash] = node
 	bc.AddNodeToIndex(node)
 	bc.DepNodes[*prevHash] = append(bc.DepNodes[*prevHash], node)
@@ -793,6 +919,20 @@ func (bc *Blockchain) maybeAcceptBlock(block *Block) (bool, error) {
 		return false, fmt.Errorf("wrong block height!")
 	}
 
+	// The block must not conflict with the checkpoints or fork the chain
+	// below them. The chain lock is held by the caller, so the best height
+	// is read directly.
+	err = CheckBlockCheckpoint(blockHeight, block.Hash(), bc.BlockHeight)
+	if err != nil {
+		return false, err
+	}
+
+	// Blocks extending an invalid block are invalid as well.
+	if prevNode != nil && prevNode.Status.KnownInvalid() {
+		return false, fmt.Errorf("block at height %d extends the invalid block %x",
+			blockHeight, prevNode.Hash.ToArrayReverse())
+	}
+
 	// The block must pass all of the validation rules which depend on the
 	// position of the block within the block chain.
 	err = PowCheckBlockContext(block, prevNode, bc.Ledger)
@@ -813,6 +953,7 @@ func (bc *Blockchain) maybeAcceptBlock(block *Block) (bool, error) {
 	blockHeader := block.Blockdata
 	blockhash := block.Hash()
 	newNode := NewBlockNode(blockHeader, &blockhash)
+	newNode.Status = StatusValidHeader
 	if prevNode != nil {
 		newNode.Parent = prevNode
 		newNode.Height = blockHeight
@@ -0,0 +1,218 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/log"
+)
+
+// BlockStatus tells how far a block node has been validated.
+type BlockStatus byte
+
+const (
+	// StatusValidHeader is set once the header passed the context checks.
+	StatusValidHeader BlockStatus = 1 << iota
+	// StatusValidData is set once the block has been connected to the main
+	// chain, so its transactions are known valid.
+	StatusValidData
+	// StatusInvalid is set on a block which failed validation or was
+	// invalidated by invalidateblock.
+	StatusInvalid
+	// StatusInvalidChild is set on the descendants of an invalid block.
+	StatusInvalidChild
+)
+
+// KnownInvalid reports whether the block or one of its ancestors is invalid.
+func (status BlockStatus) KnownInvalid() bool {
+	return status&(StatusInvalid|StatusInvalidChild) != 0
+}
+
+// ChainTip is a block without children in the block index, the end of the
+// main chain or of a fork.
+type ChainTip struct {
+	Height    uint32
+	Hash      Uint256
+	BranchLen uint32
+	Status    string
+}
+
+const (
+	TipActive       = "active"
+	TipValidFork    = "valid-fork"
+	TipValidHeaders = "valid-headers"
+	TipInvalid      = "invalid"
+)
+
+// GetChainTips returns the tips of the block index, the end of the main
+// chain first.
+func (bc *Blockchain) GetChainTips() []*ChainTip {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+	bc.IndexLock.RLock()
+	defer bc.IndexLock.RUnlock()
+
+	tips := []*ChainTip{{
+		Height: bc.BestChain.Height,
+		Hash:   *bc.BestChain.Hash,
+		Status: TipActive,
+	}}
+	for _, node := range bc.Index {
+		if len(node.Children) != 0 || node == bc.BestChain {
+			continue
+		}
+
+		tip := &ChainTip{Height: node.Height, Hash: *node.Hash}
+		fork := node
+		for ; fork.Parent != nil && !fork.InMainChain; fork = fork.Parent {
+			tip.BranchLen++
+		}
+		switch {
+		case node.Status.KnownInvalid():
+			tip.Status = TipInvalid
+		case node.Status&StatusValidData != 0:
+			tip.Status = TipValidFork
+		default:
+			tip.Status = TipValidHeaders
+		}
+		tips = append(tips, tip)
+	}
+
+	return tips
+}
+
+// setInvalid marks the node invalid and its descendants invalid children.
+func (bc *Blockchain) setInvalid(node *BlockNode) {
+	node.Status |= StatusInvalid
+	descendants := append([]*BlockNode{}, node.Children...)
+	for len(descendants) > 0 {
+		child := descendants[0]
+		descendants = append(descendants[1:], child.Children...)
+		child.Status |= StatusInvalidChild
+	}
+}
+
+// InvalidateBlock marks a block invalid, together with its descendants. If
+// the block is in the main chain, the chain is reorganized to the best
+// valid tip not containing it.
+func (bc *Blockchain) InvalidateBlock(hash Uint256) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	node, ok := bc.LookupNodeInIndex(&hash)
+	if !ok {
+		return errors.New(fmt.Sprintf("[InvalidateBlock] block %x is not in the block index",
+			hash.ToArrayReverse()))
+	}
+	if node.Parent == nil {
+		return errors.New("[InvalidateBlock] can not invalidate the root block")
+	}
+	bc.setInvalid(node)
+
+	if node.InMainChain {
+		var disconnected []*Block
+		defer func() { bc.notifyRollbackTransactions(disconnected) }()
+		for bc.BestChain != node.Parent {
+			block, err := bc.Ledger.Store.GetBlock(*bc.BestChain.Hash)
+			if err != nil {
+				return err
+			}
+			if err := bc.DisconnectBlock(bc.BestChain, block); err != nil {
+				return err
+			}
+			disconnected = append(disconnected, block)
+		}
+	}
+
+	return bc.activateBestChain()
+}
+
+// ReconsiderBlock removes the invalid status of a block, its ancestors and
+// its descendants, and reorganizes to the best valid tip.
+func (bc *Blockchain) ReconsiderBlock(hash Uint256) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	node, ok := bc.LookupNodeInIndex(&hash)
+	if !ok {
+		return errors.New(fmt.Sprintf("[ReconsiderBlock] block %x is not in the block index",
+			hash.ToArrayReverse()))
+	}
+
+	for n := node; n != nil; n = n.Parent {
+		n.Status &^= StatusInvalid | StatusInvalidChild
+	}
+	descendants := append([]*BlockNode{}, node.Children...)
+	for len(descendants) > 0 {
+		child := descendants[0]
+		descendants = append(descendants[1:], child.Children...)
+		child.Status &^= StatusInvalid | StatusInvalidChild
+	}
+
+	return bc.activateBestChain()
+}
+
+// bestValidTip returns the tip with the most work which is not known
+// invalid, and has all its side chain blocks in the block cache.
+func (bc *Blockchain) bestValidTip() *BlockNode {
+	bc.IndexLock.RLock()
+	defer bc.IndexLock.RUnlock()
+
+	var best *BlockNode
+	for _, node := range bc.Index {
+		if len(node.Children) != 0 || node.Status.KnownInvalid() {
+			continue
+		}
+		if best != nil && node.WorkSum.Cmp(best.WorkSum) <= 0 {
+			continue
+		}
+		available := true
+		for n := node; n != nil && !n.InMainChain; n = n.Parent {
+			if _, ok := bc.BlockCache[*n.Hash]; !ok {
+				available = false
+				break
+			}
+		}
+		if available {
+			best = node
+		}
+	}
+
+	return best
+}
+
+// activateBestChain reorganizes the chain to the best valid tip when it has
+// more work than the main chain. A tip failing to connect is marked invalid
+// by ConnectBlock, and the next best tip is tried.
+func (bc *Blockchain) activateBestChain() error {
+	for {
+		tip := bc.bestValidTip()
+		if tip == nil || tip.WorkSum.Cmp(bc.BestChain.WorkSum) <= 0 {
+			return nil
+		}
+
+		log.Infof("Activate chain tip %x at height %d", tip.Hash.ToArrayReverse(), tip.Height)
+		detachNodes, attachNodes := bc.GetReorganizeNodes(tip)
+		var err error
+		if detachNodes.Len() == 0 {
+			for e := attachNodes.Front(); e != nil; e = e.Next() {
+				n := e.Value.(*BlockNode)
+				if err = bc.ConnectBlock(n, bc.BlockCache[*n.Hash]); err != nil {
+					break
+				}
+				delete(bc.BlockCache, *n.Hash)
+			}
+		} else {
+			err = bc.ReorganizeChain(detachNodes, attachNodes)
+		}
+		if err == nil {
+			return nil
+		}
+
+		log.Warnf("Activate chain tip %x failed: %v", tip.Hash.ToArrayReverse(), err)
+		if !tip.Status.KnownInvalid() {
+			return err
+		}
+	}
+}
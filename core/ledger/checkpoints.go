@@ -0,0 +1,93 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/config"
+)
+
+// checkpoints of the active chain, sorted by height.
+var checkpoints = sortCheckpoints(config.Parameters.ChainParam.Checkpoints)
+
+func sortCheckpoints(params []config.Checkpoint) []config.Checkpoint {
+	cps := make([]config.Checkpoint, len(params))
+	copy(cps, params)
+	sort.Slice(cps, func(i, j int) bool { return cps[i].Height < cps[j].Height })
+
+	return cps
+}
+
+// checkpointHash parses the hash of a checkpoint, the hashes are checked
+// when the config is loaded.
+func checkpointHash(cp *config.Checkpoint) Uint256 {
+	var hash Uint256
+	if bytes, err := HexStringToBytesReverse(cp.Hash); err == nil {
+		hash, _ = Uint256ParseFromBytes(bytes)
+	}
+	return hash
+}
+
+// LatestCheckpoint returns the checkpoint with the greatest height, or nil
+// if the active chain has none.
+func LatestCheckpoint() *config.Checkpoint {
+	if len(checkpoints) == 0 {
+		return nil
+	}
+	return &checkpoints[len(checkpoints)-1]
+}
+
+// isCheckpointAncestor reports whether the block is covered by the latest
+// checkpoint, such blocks can skip the signature checks. The downloaded
+// header chain has to reach the checkpoint and contain the block, the
+// headers are linked, so the block is then an ancestor of the checkpoint.
+func (bc *Blockchain) isCheckpointAncestor(height uint32, hash Uint256) bool {
+	cp := LatestCheckpoint()
+	if cp == nil || height > cp.Height {
+		return false
+	}
+
+	cpHash, ok := bc.HeaderChain.HashAt(cp.Height)
+	if !ok || cpHash != checkpointHash(cp) {
+		return false
+	}
+	headerHash, ok := bc.HeaderChain.HashAt(height)
+	return ok && headerHash == hash
+}
+
+// findPreviousCheckpoint returns the latest checkpoint which is not higher
+// than the given height.
+func findPreviousCheckpoint(height uint32) *config.Checkpoint {
+	for i := len(checkpoints) - 1; i >= 0; i-- {
+		if checkpoints[i].Height <= height {
+			return &checkpoints[i]
+		}
+	}
+	return nil
+}
+
+// CheckBlockCheckpoint rejects a block which conflicts with a checkpoint at
+// its height, or which forks the chain below the latest checkpoint already
+// passed by the best chain.
+func CheckBlockCheckpoint(height uint32, hash Uint256, bestHeight uint32) error {
+	for i := range checkpoints {
+		cp := &checkpoints[i]
+		if cp.Height != height {
+			continue
+		}
+		if cpHash := checkpointHash(cp); cpHash != hash {
+			return errors.New(fmt.Sprintf("[CheckBlockCheckpoint] block %x at height %d does not match checkpoint %x",
+				hash.ToArrayReverse(), height, cpHash.ToArrayReverse()))
+		}
+	}
+
+	cp := findPreviousCheckpoint(bestHeight)
+	if cp != nil && height < cp.Height {
+		return errors.New(fmt.Sprintf("[CheckBlockCheckpoint] block %x at height %d forks the chain before checkpoint at height %d",
+			hash.ToArrayReverse(), height, cp.Height))
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/config"
+)
+
+// HeaderChain holds the headers downloaded ahead of their blocks during the
+// initial block download. It grows from a node of the best chain and tells
+// which blocks to fetch next, the blocks themselves are still validated in
+// full by ProcessBlock.
+type HeaderChain struct {
+	sync.RWMutex
+	base   *BlockNode
+	tip    *BlockNode
+	nodes  map[Uint256]*BlockNode
+	hashes map[uint32]Uint256
+}
+
+func NewHeaderChain(base *BlockNode) *HeaderChain {
+	hc := new(HeaderChain)
+	hc.Reset(base)
+	return hc
+}
+
+// Reset drops the downloaded headers and starts again from base.
+func (hc *HeaderChain) Reset(base *BlockNode) {
+	hc.Lock()
+	defer hc.Unlock()
+
+	hc.base = base
+	hc.tip = base
+	hc.nodes = make(map[Uint256]*BlockNode)
+	hc.hashes = make(map[uint32]Uint256)
+}
+
+// Height returns the height of the last validated header.
+func (hc *HeaderChain) Height() uint32 {
+	hc.RLock()
+	defer hc.RUnlock()
+
+	if hc.tip == nil {
+		return 0
+	}
+	return hc.tip.Height
+}
+
+// HashAt returns the hash of the downloaded header at height.
+func (hc *HeaderChain) HashAt(height uint32) (Uint256, bool) {
+	hc.RLock()
+	defer hc.RUnlock()
+
+	hash, ok := hc.hashes[height]
+	return hash, ok
+}
+
+// ProcessHeaders validates the headers and appends them to the chain, the
+// first one must extend the current tip.
+func (hc *HeaderChain) ProcessHeaders(headers []*Blockdata, timeSource MedianTimeSource) error {
+	hc.Lock()
+	defer hc.Unlock()
+
+	if hc.tip == nil {
+		return errors.New("[ProcessHeaders] header chain has no base")
+	}
+
+	for _, header := range headers {
+		hash := header.Hash()
+		if _, ok := hc.nodes[hash]; ok {
+			continue
+		}
+		if err := PowCheckHeader(header, hc.tip, timeSource); err != nil {
+			return err
+		}
+
+		node := NewBlockNode(header, &hash)
+		node.Status = StatusValidHeader
+		node.Parent = hc.tip
+		node.WorkSum.Add(hc.tip.WorkSum, node.WorkSum)
+		hc.nodes[hash] = node
+		hc.hashes[node.Height] = hash
+		hc.tip = node
+	}
+
+	return nil
+}
+
+// HeaderLocator returns a block locator from the tip of the header chain,
+// continuing in the main chain below the header chain.
+func (bc *Blockchain) HeaderLocator() BlockLocator {
+	hc := bc.HeaderChain
+	hc.RLock()
+	defer hc.RUnlock()
+
+	if hc.tip == nil {
+		return bc.blockLocatorFromHash(&bc.GenesisHash)
+	}
+
+	locator := make(BlockLocator, 0)
+	locator = append(locator, *hc.tip.Hash)
+	blockHeight := int32(hc.tip.Height)
+	increment := int32(1)
+	for len(locator) < MaxBlockLocatorsPerMsg-1 {
+		if len(locator) > 10 {
+			increment *= 2
+		}
+		blockHeight -= increment
+		if blockHeight < 1 {
+			break
+		}
+
+		if hash, ok := hc.hashes[uint32(blockHeight)]; ok {
+			locator = append(locator, hash)
+			continue
+		}
+		h, err := bc.Ledger.Store.GetBlockHash(uint32(blockHeight))
+		if err != nil {
+			continue
+		}
+		locator = append(locator, h)
+	}
+	locator = append(locator, bc.GenesisHash)
+
+	return locator
+}
+
+// PowCheckHeader checks a header against the header it extends, without
+// the block body.
+func PowCheckHeader(header *Blockdata, prevNode *BlockNode, timeSource MedianTimeSource) error {
+	hash := header.Hash()
+	if header.PrevBlockHash.CompareTo(*prevNode.Hash) != 0 {
+		return errors.New(fmt.Sprintf("[PowCheckHeader] header %x does not connect to %x",
+			hash.ToArrayReverse(), prevNode.Hash.ToArrayReverse()))
+	}
+	if header.Height != prevNode.Height+1 {
+		return errors.New("[PowCheckHeader] wrong header height")
+	}
+
+	if err := checkHeaderSanity(header, config.Parameters.ChainParam.PowLimit, timeSource); err != nil {
+		return err
+	}
+
+	if err := checkHeaderContext(header, prevNode); err != nil {
+		return err
+	}
+
+	return CheckBlockCheckpoint(header.Height, hash, prevNode.Height)
+}
@@ -5,6 +5,7 @@ import (
 	"Elastos.ELA/core/asset"
 	tx "Elastos.ELA/core/transaction"
 	"errors"
+	"fmt"
 )
 
 const (
@@ -40,7 +41,7 @@ func (l *Ledger) GetAsset(assetId Uint256) (*asset.Asset, error) {
 func (l *Ledger) GetBlockWithHeight(height uint32) (*Block, error) {
 	temp, err := l.Store.GetBlockHash(height)
 	if err != nil {
-		return nil, errors.New("[Ledger],GetBlockWithHeight failed with height=" + string(height))
+		return nil, errors.New(fmt.Sprintf("[Ledger],GetBlockWithHeight failed with height=%d", height))
 	}
 	bk, err := DefaultLedger.Store.GetBlock(temp)
 	if err != nil {
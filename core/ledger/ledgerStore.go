@@ -1,11 +1,18 @@
 package ledger
 
 import (
+	"errors"
+
 	. "Elastos.ELA/common"
 	. "Elastos.ELA/core/asset"
 	tx "Elastos.ELA/core/transaction"
+	"Elastos.ELA/core/transaction/payload"
 )
 
+// ErrBlockPruned is returned for a block whose transactions have been
+// discarded by a pruned node.
+var ErrBlockPruned = errors.New("block data has been pruned")
+
 // ILedgerStore provides func with store package.
 type ILedgerStore interface {
 	//TODO: define the state store func
@@ -14,6 +21,7 @@ type ILedgerStore interface {
 	GetBlock(hash Uint256) (*Block, error)
 	BlockInCache(hash Uint256) bool
 	GetBlockHash(height uint32) (Uint256, error)
+	GetPruneHeight() uint32
 	InitLedgerStore(ledger *Ledger) error
 	IsDoubleSpend(tx *tx.Transaction) bool
 
@@ -21,11 +29,17 @@ type ILedgerStore interface {
 	GetHeader(hash Uint256) (*Header, error)
 
 	RollbackBlock(blockHash Uint256) error
+	GetBlockUndo(hash Uint256) (*BlockUndo, error)
+	BeginReorganize(detachBlocks []*Block) error
+	EndReorganize() error
 
 	GetTransaction(hash Uint256) (*tx.Transaction, uint32, error)
 
 	PersistAsset(assetid Uint256, asset *Asset) error
 	GetAsset(hash Uint256) (*Asset, error)
+	GetQuantityIssued(assetID Uint256) (Fixed64, error)
+	GetBalance(programHash Uint168, assetID Uint256) (Fixed64, error)
+	GetContract(codeHash Uint168) (*payload.DeployCode, error)
 
 	GetCurrentBlockHash() Uint256
 	GetHeight() uint32
@@ -36,9 +50,14 @@ type ILedgerStore interface {
 
 	GetUnspent(txid Uint256, index uint16) (*tx.TxOutput, error)
 	ContainsUnspent(txid Uint256, index uint16) (bool, error)
+	GetSpendingTransaction(txid Uint256, index uint16) (*SpentBy, error)
 	GetUnspentFromProgramHash(programHash Uint168, assetid Uint256) ([]*tx.UTXOUnspent, error)
 	GetUnspentsFromProgramHash(programHash Uint168) (map[Uint256][]*tx.UTXOUnspent, error)
 	GetAssets() map[Uint256]*Asset
+	GetAddressHistory(programHash Uint168, skip, count int) ([]*AddressHistory, error)
+	GetUTXOSetInfo() (*UTXOSetInfo, error)
+	DumpUTXOSet(path string) (*UTXOSetInfo, error)
+	VerifyChain(depth uint32) ([]*ChainInconsistency, error)
 
 	IsTxHashDuplicate(txhash Uint256) bool
 	IsBlockInStore(hash Uint256) bool
@@ -0,0 +1,200 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"Elastos.ELA/common/config"
+	"Elastos.ELA/common/log"
+	tx "Elastos.ELA/core/transaction"
+	. "Elastos.ELA/errors"
+)
+
+const (
+	// SequenceLockTimeDisabled is the flag of a sequence which is not a
+	// relative lock time.
+	SequenceLockTimeDisabled = 1 << 31
+
+	// SequenceLockTimeIsSeconds is the flag of a relative lock time given
+	// in units of 2^SequenceLockTimeGranularity seconds instead of blocks.
+	SequenceLockTimeIsSeconds   = 1 << 22
+	SequenceLockTimeMask        = 0x0000ffff
+	SequenceLockTimeGranularity = 9
+)
+
+// lockContext is the block transaction lock times are checked against.
+type lockContext struct {
+	prevNode *BlockNode
+	height   uint32
+
+	// medianTime is the median time past of the previous block, time
+	// based lock times are not final before sequence locks are active.
+	medianTime int64
+
+	sequenceLocks bool
+}
+
+func (bc *Blockchain) newLockContext(prevNode *BlockNode) *lockContext {
+	ctx := &lockContext{prevNode: prevNode}
+	if prevNode == nil {
+		return ctx
+	}
+	ctx.height = prevNode.Height + 1
+	active, err := bc.IsDeploymentActive(config.DeploymentSequenceLocks, prevNode)
+	if err == nil && active {
+		ctx.sequenceLocks = true
+		ctx.medianTime = bc.medianTimePast(prevNode)
+	}
+
+	return ctx
+}
+
+// IsFinalizedTransaction reports whether the lock time of the transaction
+// has passed at the block height and time. The lock time is ignored when
+// every input has the final sequence, see tx.FinalSequence.
+func IsFinalizedTransaction(msgTx *tx.Transaction, blockHeight uint32, blockTime int64, sequenceLocks bool) bool {
+	// Lock time of zero means the transaction is finalized.
+	lockTime := msgTx.LockTime
+	if lockTime == 0 {
+		return true
+	}
+
+	blockTimeOrHeight := int64(blockHeight)
+	if lockTime >= tx.LockTimeThreshold {
+		blockTimeOrHeight = blockTime
+	}
+	if int64(lockTime) < blockTimeOrHeight {
+		return true
+	}
+
+	// At this point, the transaction's lock time hasn't occurred yet, but
+	// the transaction might still be finalized if the sequence number
+	// for all transaction inputs is maxed out.
+	finalSequence := tx.FinalSequence(sequenceLocks)
+	for _, txIn := range msgTx.UTXOInputs {
+		if txIn.Sequence != finalSequence {
+			return false
+		}
+	}
+	return true
+}
+
+// checkTransactionUTXOLock checks the outputs spent by the transaction are
+// not locked beyond its lock time. Once sequence locks are active any
+// input which is not final enables the lock time, and an output locked
+// until a time must be spent by a transaction locked until a time.
+func checkTransactionUTXOLock(txn *tx.Transaction, store tx.ILedgerStore, sequenceLocks bool) error {
+	if txn.IsCoinBaseTx() {
+		return nil
+	}
+	if len(txn.UTXOInputs) <= 0 {
+		return errors.New("Transaction has no inputs")
+	}
+	referenceWithUTXO_Output, err := txn.GetReferenceFrom(store)
+	if err != nil {
+		return errors.New(fmt.Sprintf("GetReference failed: %x", txn.Hash()))
+	}
+	for input, output := range referenceWithUTXO_Output {
+
+		if output.OutputLock == 0 {
+			//check next utxo
+			continue
+		}
+		if sequenceLocks {
+			if input.Sequence == tx.SequenceFinal {
+				return errors.New("Invalid input sequence")
+			}
+			if (output.OutputLock < tx.LockTimeThreshold) != (txn.LockTime < tx.LockTimeThreshold) {
+				return errors.New("UTXO output lock type mismatch")
+			}
+		} else if input.Sequence != math.MaxUint32-1 {
+			return errors.New("Invalid input sequence")
+		}
+		if txn.LockTime < output.OutputLock {
+			return errors.New("UTXO output locked")
+		}
+	}
+	return nil
+}
+
+// checkSequenceLocks checks the relative lock times of the transaction
+// inputs have passed, counted from the block of each spent output. The
+// transactions have no version, the sequences are only read as relative
+// lock times once the sequence locks deployment is active.
+func (bc *Blockchain) checkSequenceLocks(txn *tx.Transaction, view *UTXOView, ctx *lockContext) error {
+	if !ctx.sequenceLocks || txn.IsCoinBaseTx() {
+		return nil
+	}
+
+	// The latest height and time at which the transaction is still locked.
+	minHeight, minTime := int64(-1), int64(-1)
+	for _, input := range txn.UTXOInputs {
+		if input.Sequence&SequenceLockTimeDisabled != 0 {
+			continue
+		}
+		_, inputHeight, err := view.GetTransaction(input.ReferTxID)
+		if err != nil {
+			return err
+		}
+
+		relativeLock := int64(input.Sequence & SequenceLockTimeMask)
+		if input.Sequence&SequenceLockTimeIsSeconds == 0 {
+			if height := int64(inputHeight) + relativeLock - 1; height > minHeight {
+				minHeight = height
+			}
+			continue
+		}
+
+		// A time lock starts at the median time past of the block before
+		// the one holding the spent output.
+		prevInputHeight := uint32(0)
+		if inputHeight > 0 {
+			prevInputHeight = inputHeight - 1
+		}
+		node := bc.ancestorNode(ctx.prevNode, prevInputHeight)
+		if node == nil {
+			return errors.New(fmt.Sprintf("no block at height %d", prevInputHeight))
+		}
+		lockTime := bc.medianTimePast(node) + relativeLock<<SequenceLockTimeGranularity - 1
+		if lockTime > minTime {
+			minTime = lockTime
+		}
+	}
+
+	if minHeight >= int64(ctx.height) || minTime >= ctx.medianTime {
+		return errors.New(fmt.Sprintf("sequence locks of transaction %x not satisfied", txn.Hash()))
+	}
+	return nil
+}
+
+// checkTransactionLocks checks the absolute and relative lock times of the
+// outputs spent by the transaction in the block after ctx.prevNode.
+func (bc *Blockchain) checkTransactionLocks(txn *tx.Transaction, view *UTXOView, ctx *lockContext) ErrCode {
+	if err := checkTransactionUTXOLock(txn, view, ctx.sequenceLocks); err != nil {
+		log.Warn("[CheckTransactionUTXOLock],", err)
+		return ErrUTXOLocked
+	}
+
+	if err := bc.checkSequenceLocks(txn, view, ctx); err != nil {
+		log.Warn("[CheckSequenceLocks],", err)
+		return ErrSequenceLocked
+	}
+
+	return Success
+}
+
+// CheckTransactionFinality checks a transaction can be included in the
+// block after the best block, as a transaction pool entry or by the miner.
+func (bc *Blockchain) CheckTransactionFinality(txn *tx.Transaction) ErrCode {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	ctx := bc.newLockContext(bc.BestChain)
+	if !IsFinalizedTransaction(txn, ctx.height, ctx.medianTime, ctx.sequenceLocks) {
+		log.Warn("[CheckTransactionFinality],", errors.New("transaction is not finalized"))
+		return ErrTxNotFinalized
+	}
+
+	return bc.checkTransactionLocks(txn, NewUTXOView(bc.Ledger.Store), ctx)
+}
@@ -0,0 +1,200 @@
+package ledger
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	. "Elastos.ELA/common"
+	tx "Elastos.ELA/core/transaction"
+	"Elastos.ELA/core/transaction/payload"
+)
+
+// testLedgerStore holds the transactions spent by the tests, the other
+// methods of ILedgerStore are not called.
+type testLedgerStore struct {
+	ILedgerStore
+	txns    map[Uint256]*tx.Transaction
+	heights map[Uint256]uint32
+	height  uint32
+}
+
+func newTestLedgerStore(height uint32) *testLedgerStore {
+	return &testLedgerStore{
+		txns:    make(map[Uint256]*tx.Transaction),
+		heights: make(map[Uint256]uint32),
+		height:  height,
+	}
+}
+
+// addOutput stores a transaction at height with one output locked until
+// outputLock, and returns its hash.
+func (s *testLedgerStore) addOutput(height, outputLock uint32) Uint256 {
+	txn := &tx.Transaction{
+		TxType:  tx.TransferAsset,
+		Payload: &payload.TransferAsset{},
+		Outputs: []*tx.TxOutput{{Value: 1, OutputLock: outputLock}},
+		// a distinct hash for each output
+		LockTime: uint32(len(s.txns)),
+	}
+	hash := txn.Hash()
+	s.txns[hash] = txn
+	s.heights[hash] = height
+
+	return hash
+}
+
+func (s *testLedgerStore) GetTransaction(hash Uint256) (*tx.Transaction, uint32, error) {
+	txn, ok := s.txns[hash]
+	if !ok {
+		return nil, 0, errors.New("transaction not found")
+	}
+	return txn, s.heights[hash], nil
+}
+
+func (s *testLedgerStore) GetHeight() uint32 {
+	return s.height
+}
+
+// newLockedTransaction returns a transaction with the lock time spending
+// the outputs, with the sequences.
+func newLockedTransaction(lockTime uint32, refers []Uint256, sequences ...uint32) *tx.Transaction {
+	txn := &tx.Transaction{
+		TxType:   tx.TransferAsset,
+		Payload:  &payload.TransferAsset{},
+		LockTime: lockTime,
+	}
+	for i, sequence := range sequences {
+		input := &tx.UTXOTxInput{Sequence: sequence}
+		if i < len(refers) {
+			input.ReferTxID = refers[i]
+		}
+		txn.UTXOInputs = append(txn.UTXOInputs, input)
+	}
+	return txn
+}
+
+func TestIsFinalizedTransaction(t *testing.T) {
+	const (
+		height    = 1000
+		blockTime = tx.LockTimeThreshold + 1000
+		notFinal  = tx.SequenceFinal - 1
+	)
+	tests := []struct {
+		name          string
+		txn           *tx.Transaction
+		sequenceLocks bool
+		final         bool
+	}{
+		{"no lock time", newLockedTransaction(0, nil, notFinal), false, true},
+		{"height passed", newLockedTransaction(height-1, nil, notFinal), false, true},
+		{"height of the block", newLockedTransaction(height, nil, notFinal), false, false},
+		{"time passed", newLockedTransaction(blockTime-1, nil, notFinal), true, true},
+		{"time of the block", newLockedTransaction(blockTime, nil, notFinal), true, false},
+
+		// the lock time is ignored when every sequence is final
+		{"old final sequences", newLockedTransaction(height, nil, math.MaxUint16, math.MaxUint16), false, true},
+		{"one old final sequence", newLockedTransaction(height, nil, math.MaxUint16, notFinal), false, false},
+		{"old final sequences with sequence locks", newLockedTransaction(height, nil, math.MaxUint16), true, false},
+		{"final sequences", newLockedTransaction(height, nil, tx.SequenceFinal, tx.SequenceFinal), true, true},
+		{"final sequences without sequence locks", newLockedTransaction(height, nil, tx.SequenceFinal), false, false},
+	}
+	for _, test := range tests {
+		if final := IsFinalizedTransaction(test.txn, height, blockTime, test.sequenceLocks); final != test.final {
+			t.Errorf("%s: finalized %v, want %v", test.name, final, test.final)
+		}
+	}
+}
+
+func TestCheckTransactionUTXOLock(t *testing.T) {
+	const (
+		height   = 1000
+		lockTime = tx.LockTimeThreshold + 1000
+	)
+	store := newTestLedgerStore(height)
+	unlocked := store.addOutput(1, 0)
+	heightLocked := store.addOutput(1, height)
+	timeLocked := store.addOutput(1, lockTime)
+
+	tests := []struct {
+		name          string
+		txn           *tx.Transaction
+		sequenceLocks bool
+		valid         bool
+	}{
+		{"unlocked output", newLockedTransaction(0, []Uint256{unlocked}, tx.SequenceFinal), false, true},
+		{"lock reached", newLockedTransaction(height, []Uint256{heightLocked}, math.MaxUint32-1), false, true},
+		{"lock not reached", newLockedTransaction(height-1, []Uint256{heightLocked}, math.MaxUint32-1), false, false},
+		{"old final sequence", newLockedTransaction(height, []Uint256{heightLocked}, tx.SequenceFinal), false, false},
+		{"other sequence", newLockedTransaction(height, []Uint256{heightLocked}, 0), false, false},
+
+		// once sequence locks are active, any sequence which is not final
+		// enables the lock time, which has the type of the output lock
+		{"not final sequence", newLockedTransaction(height, []Uint256{heightLocked}, 0), true, true},
+		{"final sequence", newLockedTransaction(height, []Uint256{heightLocked}, tx.SequenceFinal), true, false},
+		{"time lock reached", newLockedTransaction(lockTime, []Uint256{timeLocked}, 0), true, true},
+		{"time lock not reached", newLockedTransaction(lockTime-1, []Uint256{timeLocked}, 0), true, false},
+		{"time lock against a height", newLockedTransaction(lockTime, []Uint256{heightLocked}, 0), true, false},
+		{"height lock against a time", newLockedTransaction(height, []Uint256{timeLocked}, 0), true, false},
+		{"unknown output", newLockedTransaction(height, []Uint256{{0xff}}, 0), true, false},
+		{"no input", newLockedTransaction(height, nil), true, false},
+	}
+	for _, test := range tests {
+		err := checkTransactionUTXOLock(test.txn, store, test.sequenceLocks)
+		if test.valid && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
+
+func TestCheckSequenceLocks(t *testing.T) {
+	// The outputs are spent from block 10, block h has the timestamp
+	// 1000+100*h and the median time past 1000+100*(h-5) from block 10.
+	versions := make([]uint32, 21)
+	nodes := newTestNodes(versions)
+	store := newTestLedgerStore(20)
+	refer := store.addOutput(10, 0)
+
+	// 5 blocks, the output is spendable from block 10+5
+	byHeight := uint32(5)
+	// 1 unit of 512 seconds from the median time past of block 9, 1500,
+	// the output is spendable once the median time past exceeds 2011
+	byTime := uint32(SequenceLockTimeIsSeconds | 1)
+
+	tests := []struct {
+		name          string
+		prevHeight    int
+		sequence      uint32
+		sequenceLocks bool
+		valid         bool
+	}{
+		{"height lock passed", 14, byHeight, true, true},
+		{"height lock not passed", 13, byHeight, true, false},
+		{"time lock passed", 16, byTime, true, true},
+		{"time lock not passed", 15, byTime, true, false},
+		{"lock disabled", 10, SequenceLockTimeDisabled | byHeight, true, true},
+		{"final sequence", 10, tx.SequenceFinal, true, true},
+		{"sequence locks inactive", 10, byHeight, false, true},
+	}
+	bc := &Blockchain{}
+	for _, test := range tests {
+		prevNode := nodes[test.prevHeight]
+		ctx := &lockContext{
+			prevNode:      prevNode,
+			height:        prevNode.Height + 1,
+			medianTime:    bc.medianTimePast(prevNode),
+			sequenceLocks: test.sequenceLocks,
+		}
+		txn := newLockedTransaction(0, []Uint256{refer}, test.sequence)
+		err := bc.checkSequenceLocks(txn, NewUTXOView(store), ctx)
+		if test.valid && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
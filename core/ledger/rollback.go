@@ -0,0 +1,76 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/log"
+	"Elastos.ELA/events"
+)
+
+// RollbackTo disconnects the blocks of the main chain above height, the
+// genesis block is never disconnected. Once the rollback is done the
+// disconnected blocks are notified with one EventRollbackTransaction, so a
+// running node returns their transactions to its transaction pool.
+func (bc *Blockchain) RollbackTo(height uint32) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	return bc.rollbackTo(height)
+}
+
+// RollbackToBlock disconnects the blocks of the main chain above the block
+// of hash.
+func (bc *Blockchain) RollbackToBlock(hash Uint256) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	node, ok := bc.LookupNodeInIndex(&hash)
+	if !ok || !node.InMainChain {
+		return errors.New(fmt.Sprintf("[RollbackToBlock] block %x is not in the main chain",
+			hash.ToArrayReverse()))
+	}
+
+	return bc.rollbackTo(node.Height)
+}
+
+func (bc *Blockchain) rollbackTo(height uint32) error {
+	if height >= bc.BestChain.Height {
+		return errors.New(fmt.Sprintf("[RollbackTo] current height is %d, can not roll back to height %d",
+			bc.BestChain.Height, height))
+	}
+
+	var disconnected []*Block
+	defer func() { bc.notifyRollbackTransactions(disconnected) }()
+	for bc.BestChain.Height > height && bc.BestChain.Parent != nil {
+		node := bc.BestChain
+		block, err := bc.Ledger.Store.GetBlock(*node.Hash)
+		if err != nil {
+			return errors.New(fmt.Sprintf("[RollbackTo] get block %x at height %d failed: %v",
+				node.Hash.ToArrayReverse(), node.Height, err))
+		}
+		if err := bc.DisconnectBlock(node, block); err != nil {
+			return err
+		}
+		disconnected = append(disconnected, block)
+		log.Infof("Rolled back block %x at height %d", node.Hash.ToArrayReverse(), node.Height)
+	}
+
+	return nil
+}
+
+// notifyRollbackTransactions notifies the blocks disconnected from the main
+// chain, given tip first, with one EventRollbackTransaction. The blocks are
+// notified in ascending height, so the transactions are returned to the
+// transaction pool after the transactions they spend.
+func (bc *Blockchain) notifyRollbackTransactions(disconnected []*Block) {
+	if len(disconnected) == 0 {
+		return
+	}
+	blocks := make([]*Block, len(disconnected))
+	for i, block := range disconnected {
+		blocks[len(disconnected)-1-i] = block
+	}
+	bc.BCEvents.Notify(events.EventRollbackTransaction, blocks)
+}
@@ -0,0 +1,43 @@
+package ledger
+
+import (
+	"io"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/serialization"
+)
+
+// SpentBy is the input which spent an output, given by the hash of the
+// spending transaction, the index of the input and the block height.
+type SpentBy struct {
+	TxID   Uint256
+	Index  uint16
+	Height uint32
+}
+
+func (sb *SpentBy) Serialize(w io.Writer) error {
+	if _, err := sb.TxID.Serialize(w); err != nil {
+		return err
+	}
+	if err := serialization.WriteUint16(w, sb.Index); err != nil {
+		return err
+	}
+	return serialization.WriteUint32(w, sb.Height)
+}
+
+func (sb *SpentBy) Deserialize(r io.Reader) error {
+	if err := sb.TxID.Deserialize(r); err != nil {
+		return err
+	}
+	index, err := serialization.ReadUint16(r)
+	if err != nil {
+		return err
+	}
+	sb.Index = index
+	height, err := serialization.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+	sb.Height = height
+	return nil
+}
@@ -0,0 +1,93 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/config"
+	"Elastos.ELA/common/log"
+	. "Elastos.ELA/errors"
+)
+
+var (
+	OriginAmountOfEla = int64(3300 * 10000 * 100000000)
+	SubsidyInterval   = 365 * 24 * 60 * 60 / int64(config.Parameters.ChainParam.TargetTimePerBlock/time.Second)
+	RetargetPercent   = 25
+
+	// FoundationRewardRatio is the part of the block reward, subsidy and
+	// fees, which goes to the foundation address.
+	FoundationRewardRatio = 0.3
+)
+
+// CalcBlockSubsidy returns the subsidy of the block at height. The total
+// amount of ELA grows by 1/RetargetPercent each SubsidyInterval, spread
+// evenly over the blocks of the interval.
+func CalcBlockSubsidy(height uint32) Fixed64 {
+	totalAmountOfEla := OriginAmountOfEla
+	for i := uint32(0); i < (height / uint32(SubsidyInterval)); i++ {
+		incr := float64(totalAmountOfEla) / float64(RetargetPercent)
+		subsidyPerBlock := int64(float64(incr) / float64(SubsidyInterval))
+		totalAmountOfEla += subsidyPerBlock * SubsidyInterval
+	}
+	incr := float64(totalAmountOfEla) / float64(RetargetPercent)
+	subsidyPerBlock := int64(float64(incr) / float64(SubsidyInterval))
+
+	return Fixed64(subsidyPerBlock)
+}
+
+// CalcFoundationReward returns the least amount of a block reward which
+// must be paid to the foundation address.
+func CalcFoundationReward(reward Fixed64) Fixed64 {
+	return Fixed64(float64(reward) * FoundationRewardRatio)
+}
+
+// CheckBlockReward verifies the coinbase of a block against the subsidy
+// schedule, the coinbase may not pay more than the subsidy plus the fees of
+// the block, of which the foundation gets at least its share. The fees are
+// computed from the outputs in view.
+func CheckBlockReward(block *Block, view *UTXOView) ErrCode {
+	if len(block.Transactions) == 0 || !block.Transactions[0].IsCoinBaseTx() {
+		log.Warn("[CheckBlockReward],", errors.New("first transaction in block is not a coinbase"))
+		return ErrIneffectiveCoinbase
+	}
+
+	assetID := DefaultLedger.Blockchain.AssetID
+	var totalFee Fixed64
+	for _, txn := range block.Transactions[1:] {
+		results, err := txn.GetTransactionResultsFrom(view)
+		if err != nil {
+			log.Warn("[CheckBlockReward],", err)
+			return ErrUnknownReferedTxn
+		}
+		totalFee += results[assetID]
+	}
+
+	var reward, foundationReward Fixed64
+	foundationProgramHash, err := Uint68FromAddress(FoundationAddress)
+	if err != nil {
+		log.Warn("[CheckBlockReward],", err)
+		return ErrInvalidOutput
+	}
+	for _, output := range block.Transactions[0].Outputs {
+		reward += output.Value
+		if output.ProgramHash == foundationProgramHash {
+			foundationReward += output.Value
+		}
+	}
+
+	maxReward := CalcBlockSubsidy(block.Blockdata.Height) + totalFee
+	if reward > maxReward {
+		log.Warn("[CheckBlockReward],", errors.New(fmt.Sprintf("coinbase pays %d, more than subsidy plus fees %d",
+			reward, maxReward)))
+		return ErrCoinbaseOverpay
+	}
+	if minFoundationReward := CalcFoundationReward(reward); foundationReward < minFoundationReward {
+		log.Warn("[CheckBlockReward],", errors.New(fmt.Sprintf("coinbase pays %d to foundation, less than %d",
+			foundationReward, minFoundationReward)))
+		return ErrFoundationReward
+	}
+
+	return Success
+}
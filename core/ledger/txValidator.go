@@ -9,6 +9,8 @@ import (
 	"Elastos.ELA/common/config"
 	"Elastos.ELA/common/log"
 	"Elastos.ELA/core/asset"
+	"Elastos.ELA/core/contract"
+	"Elastos.ELA/core/signature"
 	tx "Elastos.ELA/core/transaction"
 	"Elastos.ELA/core/transaction/payload"
 	. "Elastos.ELA/errors"
@@ -32,11 +34,6 @@ func CheckTransactionSanity(txn *tx.Transaction) ErrCode {
 		return ErrInvalidOutput
 	}
 
-	if err := CheckAssetPrecision(txn); err != nil {
-		log.Warn("[CheckAssetPrecesion],", err)
-		return ErrAssetPrecision
-	}
-
 	if err := CheckAttributeProgram(txn); err != nil {
 		log.Warn("[CheckTransactionAttribute],", err)
 		return ErrAttributeProgram
@@ -57,8 +54,16 @@ func CheckTransactionSanity(txn *tx.Transaction) ErrCode {
 
 // CheckTransactionContext verifys a transaction with history transaction in ledger
 func CheckTransactionContext(txn *tx.Transaction, ledger *Ledger) ErrCode {
+	rules := ledger.Blockchain.bestRuleContext()
+	return checkTransactionContext(txn, NewUTXOView(ledger.Store), rules, true)
+}
+
+// checkTransactionContext verifys a transaction with the ledger as seen
+// through view, which also holds the transactions preceding it in a block,
+// under the rules of the block.
+func checkTransactionContext(txn *tx.Transaction, view *UTXOView, rules *ruleContext, checkSignature bool) ErrCode {
 	// check if duplicated with transaction in ledger
-	if exist := ledger.Store.IsTxHashDuplicate(txn.Hash()); exist {
+	if exist := view.IsTxHashDuplicate(txn.Hash()); exist {
 		log.Info("[CheckTransactionContext] duplicate transaction check faild.")
 		return ErrTxHashDuplicate
 	}
@@ -68,30 +73,51 @@ func CheckTransactionContext(txn *tx.Transaction, ledger *Ledger) ErrCode {
 	}
 
 	// check double spent transaction
-	if IsDoubleSpend(txn, ledger) {
+	if view.IsDoubleSpend(txn) {
 		log.Info("[CheckTransactionContext] IsDoubleSpend check faild.")
 		return ErrDoubleSpend
 	}
 
-	if err := CheckTransactionUTXOLock(txn); err != nil {
-		log.Warn("[CheckTransactionUTXOLock],", err)
-		return ErrUTXOLocked
+	if err := checkOutputAssets(txn, view, rules); err != nil {
+		log.Warn("[CheckOutputAssets],", err)
+		return ErrInvalidOutput
+	}
+
+	if err := checkAssetPrecision(txn, view); err != nil {
+		log.Warn("[CheckAssetPrecesion],", err)
+		return ErrAssetPrecision
+	}
+
+	if err := checkAssetIssuance(txn, view, rules); err != nil {
+		log.Warn("[CheckAssetIssuance],", err)
+		return ErrAssetIssuance
+	}
+
+	if errCode := checkDeployCode(txn, view); errCode != Success {
+		return errCode
 	}
 
-	if err := CheckTransactionBalance(txn); err != nil {
+	if err := checkBalanceInputs(txn, view, rules); err != nil {
+		log.Warn("[CheckBalanceInputs],", err)
+		return ErrBalanceInput
+	}
+
+	if err := checkTransactionBalance(txn, view); err != nil {
 		log.Warn("[CheckTransactionBalance],", err)
 		return ErrTransactionBalance
 	}
 
-	if err := CheckTransactionSignature(txn); err != nil {
-		log.Warn("[CheckTransactionSignature],", err)
-		return ErrTransactionSignature
+	if checkSignature {
+		if err := checkTransactionSignature(txn, view, rules.scriptFlags); err != nil {
+			log.Warn("[CheckTransactionSignature],", err)
+			return ErrTransactionSignature
+		}
 	}
 	// check referenced Output value
 	for _, input := range txn.UTXOInputs {
 		referHash := input.ReferTxID
 		referTxnOutIndex := input.ReferTxOutputIndex
-		referTxn, _, err := ledger.Store.GetTransaction(referHash)
+		referTxn, _, err := view.GetTransaction(referHash)
 		if err != nil {
 			log.Warn("Referenced transaction can not be found", common.BytesToHexString(referHash.ToArray()))
 			return ErrUnknownReferedTxn
@@ -104,8 +130,8 @@ func CheckTransactionContext(txn *tx.Transaction, ledger *Ledger) ErrCode {
 		// coinbase transaction only can be spent after got SpendCoinbaseSpan times confirmations
 		if referTxn.IsCoinBaseTx() {
 			lockHeight := referTxn.LockTime
-			currentHeight := ledger.Store.GetHeight()
-			if currentHeight-lockHeight < config.Parameters.ChainParam.SpendCoinbaseSpan {
+			currentHeight := view.store.GetHeight()
+			if lockHeight > currentHeight || currentHeight-lockHeight < config.Parameters.ChainParam.SpendCoinbaseSpan {
 				return ErrIneffectiveCoinbase
 			}
 		}
@@ -121,6 +147,9 @@ func CheckTransactionInput(txn *tx.Transaction) error {
 		if len(txn.UTXOInputs) != 1 {
 			return errors.New("coinbase must has only one input")
 		}
+		if len(txn.BalanceInputs) != 0 {
+			return errors.New("coinbase can not have balance inputs")
+		}
 		coinbaseInputHash := txn.UTXOInputs[0].ReferTxID
 		coinbaseInputIndex := txn.UTXOInputs[0].ReferTxOutputIndex
 		//TODO :check sequence
@@ -131,7 +160,7 @@ func CheckTransactionInput(txn *tx.Transaction) error {
 		return nil
 	}
 
-	if len(txn.UTXOInputs) <= 0 {
+	if len(txn.UTXOInputs)+len(txn.BalanceInputs) <= 0 {
 		return errors.New("transaction has no inputs")
 	}
 	for i, utxoin := range txn.UTXOInputs {
@@ -146,6 +175,133 @@ func CheckTransactionInput(txn *tx.Transaction) error {
 			}
 		}
 	}
+	for i, balancein := range txn.BalanceInputs {
+		if balancein.Value <= 0 {
+			return errors.New("invalid balance input value")
+		}
+		for j := 0; j < i; j++ {
+			if balancein.ProgramHash == txn.BalanceInputs[j].ProgramHash && balancein.AssetID == txn.BalanceInputs[j].AssetID {
+				return errors.New("duplicated balance inputs")
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkAssetIssuance checks that an IssueAsset transaction only issues
+// registered assets other than ELA, within the amount they were registered
+// with. The amount is compared to the quantity issued, the amount of the
+// asset in unspent outputs and balances, so an amount burned by the
+// transactions spending more of it than they output can be issued again.
+// The controllers signing it are checked with the signatures.
+func checkAssetIssuance(txn *tx.Transaction, view *UTXOView, rules *ruleContext) error {
+	if txn.TxType != tx.IssueAsset {
+		return nil
+	}
+	if !rules.assetIssuance {
+		return errors.New("asset issuance is not active")
+	}
+	results, err := txn.GetTransactionResultsFrom(view)
+	if err != nil {
+		return err
+	}
+	issues := false
+	for assetID, amount := range results {
+		if amount >= 0 {
+			continue
+		}
+		if assetID == DefaultLedger.Blockchain.AssetID {
+			return errors.New("ELA can not be issued")
+		}
+		registerTx, _, err := view.GetTransaction(assetID)
+		if err != nil {
+			return errors.New(fmt.Sprintf("asset %x is not registered", assetID.ToArrayReverse()))
+		}
+		registerPayload, ok := registerTx.Payload.(*payload.RegisterAsset)
+		if !ok {
+			return errors.New(fmt.Sprintf("asset %x is not registered", assetID.ToArrayReverse()))
+		}
+		issued, err := view.GetQuantityIssued(assetID)
+		if err != nil {
+			return err
+		}
+		if issued-amount > registerPayload.Amount {
+			return errors.New(fmt.Sprintf("issue %v of asset %x exceeds the amount %v, %v already issued",
+				-amount, assetID.ToArrayReverse(), registerPayload.Amount, issued))
+		}
+		issues = true
+	}
+	if !issues {
+		return errors.New("issue transaction issues no asset")
+	}
+
+	return nil
+}
+
+// checkDeployCode checks that the code of a Deploy transaction is not
+// deployed yet.
+func checkDeployCode(txn *tx.Transaction, view *UTXOView) ErrCode {
+	dc, ok := txn.Payload.(*payload.DeployCode)
+	if !ok {
+		return Success
+	}
+	codeHash, err := signature.ToProgramHash(dc.Code.Code)
+	if err != nil {
+		log.Warn("[CheckDeployCode],", err)
+		return ErrInvalidContract
+	}
+	if _, err := view.GetContract(codeHash); err == nil {
+		log.Warnf("[CheckDeployCode], contract %x is already deployed", codeHash.ToArrayReverse())
+		return ErrDuplicateContract
+	}
+
+	return Success
+}
+
+// checkBalanceInputs checks that every balance input spends a Balance record
+// type asset and does not exceed the balance of its program hash.
+func checkBalanceInputs(txn *tx.Transaction, view *UTXOView, rules *ruleContext) error {
+	if len(txn.BalanceInputs) > 0 && !rules.balanceInputs {
+		return errors.New("balance inputs are not active")
+	}
+	for _, input := range txn.BalanceInputs {
+		a, err := view.GetAsset(input.AssetID)
+		if err != nil {
+			return errors.New(fmt.Sprintf("asset %x of balance input not found", input.AssetID.ToArrayReverse()))
+		}
+		if a.RecordType != asset.Balance {
+			return errors.New(fmt.Sprintf("asset %x is not a balance record asset", input.AssetID.ToArrayReverse()))
+		}
+		balance, err := view.GetBalance(input.ProgramHash, input.AssetID)
+		if err != nil {
+			return err
+		}
+		if input.Value > balance {
+			return errors.New(fmt.Sprintf("balance input %v of asset %x exceeds the balance %v",
+				input.Value, input.AssetID.ToArrayReverse(), balance))
+		}
+	}
+
+	return nil
+}
+
+// checkOutputAssets checks that the outputs are of ELA, of the Balance
+// record type assets once balance inputs are active, or of every registered
+// asset once asset issuance is.
+func checkOutputAssets(txn *tx.Transaction, view *UTXOView, rules *ruleContext) error {
+	for _, output := range txn.Outputs {
+		if output.AssetID == DefaultLedger.Blockchain.AssetID {
+			continue
+		}
+		a, err := view.GetAsset(output.AssetID)
+		if err != nil {
+			return errors.New(fmt.Sprintf("asset %x of output is not registered", output.AssetID.ToArrayReverse()))
+		}
+		if !rules.assetIssuance && !(rules.balanceInputs && a.RecordType == asset.Balance) {
+			return errors.New(fmt.Sprintf("asset %x of output is not accepted", output.AssetID.ToArrayReverse()))
+		}
+	}
 
 	return nil
 }
@@ -179,41 +335,6 @@ func CheckTransactionOutput(txn *tx.Transaction) error {
 		return errors.New("transaction has no outputs")
 	}
 
-	// check if output address is valid
-	for _, output := range txn.Outputs {
-		if output.AssetID != DefaultLedger.Blockchain.AssetID {
-			return errors.New("asset ID in coinbase is invalid")
-		}
-
-	}
-
-	return nil
-}
-
-func CheckTransactionUTXOLock(txn *tx.Transaction) error {
-	if txn.IsCoinBaseTx() {
-		return nil
-	}
-	if len(txn.UTXOInputs) <= 0 {
-		return errors.New("Transaction has no inputs")
-	}
-	referenceWithUTXO_Output, err := txn.GetReference()
-	if err != nil {
-		return errors.New(fmt.Sprintf("GetReference failed: %x", txn.Hash()))
-	}
-	for input, output := range referenceWithUTXO_Output {
-
-		if output.OutputLock == 0 {
-			//check next utxo
-			continue
-		}
-		if input.Sequence != math.MaxUint32-1 {
-			return errors.New("Invalid input sequence")
-		}
-		if txn.LockTime < output.OutputLock {
-			return errors.New("UTXO output locked")
-		}
-	}
 	return nil
 }
 
@@ -230,7 +351,13 @@ func IsDoubleSpend(tx *tx.Transaction, ledger *Ledger) bool {
 	return ledger.IsDoubleSpend(tx)
 }
 
+// CheckAssetPrecision checks that the outputs are of registered assets and
+// match their precision.
 func CheckAssetPrecision(Tx *tx.Transaction) error {
+	return checkAssetPrecision(Tx, NewUTXOView(DefaultLedger.Store))
+}
+
+func checkAssetPrecision(Tx *tx.Transaction, view *UTXOView) error {
 	if len(Tx.Outputs) == 0 {
 		return nil
 	}
@@ -240,7 +367,7 @@ func CheckAssetPrecision(Tx *tx.Transaction) error {
 		assetOutputs[v.AssetID] = append(assetOutputs[v.AssetID], v)
 	}
 	for k, outputs := range assetOutputs {
-		asset, err := DefaultLedger.GetAsset(k)
+		asset, err := view.GetAsset(k)
 		if err != nil {
 			return errors.New("The asset not exist in local blockchain.")
 		}
@@ -254,24 +381,34 @@ func CheckAssetPrecision(Tx *tx.Transaction) error {
 	return nil
 }
 
+// CheckTransactionBalance checks the fee of a transaction, the reward paid
+// by a coinbase is checked with its block by CheckBlockReward.
 func CheckTransactionBalance(Tx *tx.Transaction) error {
-	// TODO: check coinbase balance 30%-70%
+	return checkTransactionBalance(Tx, tx.TxStore)
+}
+
+func checkTransactionBalance(Tx *tx.Transaction, store tx.ILedgerStore) error {
 	for _, v := range Tx.Outputs {
 		if v.Value <= common.Fixed64(0) {
 			return errors.New("Invalide transaction UTXO output.")
 		}
 	}
-	results, err := Tx.GetTransactionResults()
+	results, err := Tx.GetTransactionResultsFrom(store)
 	if err != nil {
 		return err
 	}
 	for k, v := range results {
 
-		if v < common.Fixed64(config.Parameters.PowConfiguration.MinTxFee) {
+		// an issue transaction creates the assets other than ELA
+		if v < 0 && (Tx.TxType != tx.IssueAsset || k == DefaultLedger.Blockchain.AssetID) {
 			log.Debug(fmt.Sprintf("AssetID %x in Transfer transactions %x , input < output .\n", k, Tx.Hash()))
 			return errors.New(fmt.Sprintf("AssetID %x in Transfer transactions %x , input < output .\n", k, Tx.Hash()))
 		}
 	}
+	// the fee is paid in ELA, the other assets only need to balance
+	if results[DefaultLedger.Blockchain.AssetID] < common.Fixed64(config.Parameters.PowConfiguration.MinTxFee) {
+		return errors.New(fmt.Sprintf("transaction %x pays less than the minimum fee", Tx.Hash()))
+	}
 	return nil
 }
 
@@ -306,7 +443,12 @@ func InAddressList(address string) bool {
 }
 
 func CheckTransactionSignature(txn *tx.Transaction) error {
-	flag, err := tx.VerifySignature(txn)
+	rules := DefaultLedger.Blockchain.bestRuleContext()
+	return checkTransactionSignature(txn, tx.TxStore, rules.scriptFlags)
+}
+
+func checkTransactionSignature(txn *tx.Transaction, store tx.ILedgerStore, flags tx.VerifyFlags) error {
+	flag, err := tx.VerifySignatureFrom(txn, store, flags)
 	if flag && err == nil {
 		return nil
 	} else {
@@ -328,11 +470,40 @@ func CheckTransactionPayload(Tx *tx.Transaction) error {
 		if checkAmountPrecise(pld.Amount, pld.Asset.Precision) {
 			return errors.New("Invalide asset value,out of precise.")
 		}
+		if pld.Amount <= 0 {
+			return errors.New("Invalide asset amount.")
+		}
+		if pld.Asset.AssetType != asset.Token && pld.Asset.AssetType != asset.Share {
+			return errors.New("Invalide asset type.")
+		}
+		if pld.Asset.RecordType != asset.UTXO && pld.Asset.RecordType != asset.Balance {
+			return errors.New("Invalide asset record type.")
+		}
 	case *payload.TransferAsset:
 	case *payload.Record:
 	case *payload.DeployCode:
+		if pld.Code == nil || len(pld.Code.Code) == 0 {
+			return errors.New("Invalide deploy code.")
+		}
+		if len(pld.Code.Code) > payload.MaxDeployCodeSize {
+			return errors.New("Invalide deploy code size.")
+		}
+		if len(pld.Code.ParameterTypes) > payload.MaxDeployParameterCount {
+			return errors.New("Invalide deploy code parameter count.")
+		}
+		for _, t := range pld.Code.ParameterTypes {
+			if t > contract.ByteArray {
+				return errors.New("Invalide deploy code parameter type.")
+			}
+		}
+		for _, text := range []string{pld.Name, pld.CodeVersion, pld.Author, pld.Email, pld.Description} {
+			if len(text) > payload.MaxDeployInfoSize {
+				return errors.New("Invalide deploy code info size.")
+			}
+		}
 	case *payload.CoinBase:
 	case *payload.SideMining:
+	case *payload.IssueAsset:
 	default:
 		return errors.New("[txValidator],invalidate transaction payload type.")
 	}
@@ -0,0 +1,195 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/core/asset"
+	tx "Elastos.ELA/core/transaction"
+	"Elastos.ELA/core/transaction/payload"
+)
+
+// testAssetStore holds the registered assets with their quantities issued
+// and one balance of every program hash, the other methods of ILedgerStore
+// are not called.
+type testAssetStore struct {
+	ILedgerStore
+	txns    map[Uint256]*tx.Transaction
+	assets  map[Uint256]*asset.Asset
+	issued  map[Uint256]Fixed64
+	balance Fixed64
+}
+
+func (s *testAssetStore) GetTransaction(hash Uint256) (*tx.Transaction, uint32, error) {
+	txn, ok := s.txns[hash]
+	if !ok {
+		return nil, 0, errors.New("transaction not found")
+	}
+	return txn, 0, nil
+}
+
+func (s *testAssetStore) GetQuantityIssued(assetID Uint256) (Fixed64, error) {
+	issued, ok := s.issued[assetID]
+	if !ok {
+		return 0, errors.New("asset not found")
+	}
+	return issued, nil
+}
+
+func (s *testAssetStore) GetAsset(assetID Uint256) (*asset.Asset, error) {
+	a, ok := s.assets[assetID]
+	if !ok {
+		return nil, errors.New("asset not found")
+	}
+	return a, nil
+}
+
+func (s *testAssetStore) GetBalance(programHash Uint168, assetID Uint256) (Fixed64, error) {
+	return s.balance, nil
+}
+
+func TestBalanceInputsDeployment(t *testing.T) {
+	ela := Uint256{1}
+	balanceAsset := Uint256{2}
+	utxoAsset := Uint256{3}
+	defaultLedger := DefaultLedger
+	DefaultLedger = &Ledger{Blockchain: &Blockchain{AssetID: ela}}
+	defer func() { DefaultLedger = defaultLedger }()
+
+	view := NewUTXOView(&testAssetStore{
+		assets: map[Uint256]*asset.Asset{
+			balanceAsset: {Name: "balance", RecordType: asset.Balance},
+			utxoAsset:    {Name: "utxo", RecordType: asset.UTXO},
+		},
+		balance: 100,
+	})
+	newTransfer := func(outputAsset Uint256, balanceInputs ...*tx.BalanceTxInput) *tx.Transaction {
+		return &tx.Transaction{
+			TxType:        tx.TransferAsset,
+			Payload:       &payload.TransferAsset{},
+			BalanceInputs: balanceInputs,
+			Outputs:       []*tx.TxOutput{{AssetID: outputAsset, Value: 10, ProgramHash: Uint168{1}}},
+		}
+	}
+	spend := &tx.BalanceTxInput{AssetID: balanceAsset, Value: 10, ProgramHash: Uint168{2}}
+
+	tests := []struct {
+		name   string
+		txn    *tx.Transaction
+		before bool
+		after  bool
+	}{
+		{"ELA output", newTransfer(ela), true, true},
+		{"Balance asset output", newTransfer(balanceAsset), false, true},
+		{"UTXO asset output", newTransfer(utxoAsset), false, false},
+		{"unregistered asset output", newTransfer(Uint256{4}), false, false},
+		{"balance input", newTransfer(ela, spend), false, true},
+	}
+	for _, test := range tests {
+		for _, rules := range []*ruleContext{{}, {balanceInputs: true}} {
+			err := checkOutputAssets(test.txn, view, rules)
+			if err == nil {
+				err = checkBalanceInputs(test.txn, view, rules)
+			}
+			want := test.before
+			if rules.balanceInputs {
+				want = test.after
+			}
+			if (err == nil) != want {
+				t.Errorf("%s with balance inputs active %v: accepted %v, want %v (%v)",
+					test.name, rules.balanceInputs, err == nil, want, err)
+			}
+		}
+	}
+}
+
+func TestAssetIssuance(t *testing.T) {
+	ela := Uint256{1}
+	defaultLedger := DefaultLedger
+	DefaultLedger = &Ledger{Blockchain: &Blockchain{AssetID: ela}}
+	defer func() { DefaultLedger = defaultLedger }()
+
+	a := &asset.Asset{Name: "utxo", RecordType: asset.UTXO}
+	register := &tx.Transaction{
+		TxType:  tx.RegisterAsset,
+		Payload: &payload.RegisterAsset{Asset: a, Amount: 100, Controller: Uint168{1}},
+	}
+	assetID := register.Hash()
+	store := &testAssetStore{
+		txns:   map[Uint256]*tx.Transaction{assetID: register},
+		assets: map[Uint256]*asset.Asset{assetID: a},
+		issued: map[Uint256]Fixed64{assetID: 0},
+	}
+	newIssue := func(value Fixed64) *tx.Transaction {
+		return &tx.Transaction{
+			TxType:  tx.IssueAsset,
+			Payload: &payload.IssueAsset{},
+			Outputs: []*tx.TxOutput{{AssetID: assetID, Value: value, ProgramHash: Uint168{2}}},
+		}
+	}
+	active := &ruleContext{assetIssuance: true}
+
+	// IssueAsset transactions and the outputs of UTXO assets are accepted
+	// once the deployment is active.
+	for _, rules := range []*ruleContext{{}, {balanceInputs: true}} {
+		if err := checkAssetIssuance(newIssue(10), NewUTXOView(store), rules); err == nil {
+			t.Errorf("issue accepted with %+v", rules)
+		}
+		if err := checkOutputAssets(newIssue(10), NewUTXOView(store), rules); err == nil {
+			t.Errorf("UTXO asset output accepted with %+v", rules)
+		}
+	}
+	if err := checkOutputAssets(newIssue(10), NewUTXOView(store), active); err != nil {
+		t.Errorf("UTXO asset output rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		issued Fixed64
+		issue  Fixed64
+		valid  bool
+	}{
+		{"first issue", 0, 100, true},
+		{"issue over the amount", 0, 101, false},
+		{"issue of the rest", 60, 40, true},
+		{"issue over the rest", 60, 41, false},
+		{"issue over the issued amount", 100, 1, false},
+	}
+	for _, test := range tests {
+		store.issued[assetID] = test.issued
+		err := checkAssetIssuance(newIssue(test.issue), NewUTXOView(store), active)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: accepted %v, want %v (%v)", test.name, err == nil, test.valid, err)
+		}
+	}
+
+	// The cap is on the amount in unspent outputs, 40 of the 100 issued
+	// burned by a transaction spending more of the asset than it outputs
+	// can be issued again.
+	issue := newIssue(100)
+	store.txns[issue.Hash()] = issue
+	store.issued[assetID] = 100
+	view := NewUTXOView(store)
+	view.AddTransaction(&tx.Transaction{
+		TxType:     tx.TransferAsset,
+		Payload:    &payload.TransferAsset{},
+		UTXOInputs: []*tx.UTXOTxInput{{ReferTxID: issue.Hash()}},
+		Outputs:    []*tx.TxOutput{{AssetID: assetID, Value: 60, ProgramHash: Uint168{3}}},
+	})
+	if err := checkAssetIssuance(newIssue(40), view, active); err != nil {
+		t.Errorf("issue of the burned amount rejected: %v", err)
+	}
+	if err := checkAssetIssuance(newIssue(41), view, active); err == nil {
+		t.Error("issue over the burned amount accepted")
+	}
+
+	// ELA is not issued.
+	if err := checkAssetIssuance(&tx.Transaction{
+		TxType:  tx.IssueAsset,
+		Payload: &payload.IssueAsset{},
+		Outputs: []*tx.TxOutput{{AssetID: ela, Value: 1, ProgramHash: Uint168{2}}},
+	}, NewUTXOView(store), active); err == nil {
+		t.Error("issue of ELA accepted")
+	}
+}
@@ -0,0 +1,83 @@
+package ledger
+
+import (
+	"io"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/serialization"
+	tx "Elastos.ELA/core/transaction"
+)
+
+// SpentOutput is an output consumed by a block, kept together with the
+// height of the transaction that created it. The output and the height
+// identify the IX_Unspent_UTXO bucket the output was removed from.
+type SpentOutput struct {
+	ReferTxID          Uint256
+	ReferTxOutputIndex uint16
+	Height             uint32
+	Output             tx.TxOutput
+}
+
+func (so *SpentOutput) Serialize(w io.Writer) error {
+	if _, err := so.ReferTxID.Serialize(w); err != nil {
+		return err
+	}
+	if err := serialization.WriteUint16(w, so.ReferTxOutputIndex); err != nil {
+		return err
+	}
+	if err := serialization.WriteUint32(w, so.Height); err != nil {
+		return err
+	}
+	return so.Output.Serialize(w)
+}
+
+func (so *SpentOutput) Deserialize(r io.Reader) error {
+	if err := so.ReferTxID.Deserialize(r); err != nil {
+		return err
+	}
+	index, err := serialization.ReadUint16(r)
+	if err != nil {
+		return err
+	}
+	so.ReferTxOutputIndex = index
+	height, err := serialization.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+	so.Height = height
+	return so.Output.Deserialize(r)
+}
+
+// BlockUndo holds everything needed to disconnect a block from the UTXO
+// indexes without reading the transactions it spent from.
+type BlockUndo struct {
+	SpentOutputs []*SpentOutput
+}
+
+func (bu *BlockUndo) Serialize(w io.Writer) error {
+	if err := serialization.WriteVarUint(w, uint64(len(bu.SpentOutputs))); err != nil {
+		return err
+	}
+	for _, so := range bu.SpentOutputs {
+		if err := so.Serialize(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (bu *BlockUndo) Deserialize(r io.Reader) error {
+	count, err := serialization.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	bu.SpentOutputs = make([]*SpentOutput, 0, count)
+	for i := uint64(0); i < count; i++ {
+		so := new(SpentOutput)
+		if err := so.Deserialize(r); err != nil {
+			return err
+		}
+		bu.SpentOutputs = append(bu.SpentOutputs, so)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package ledger
+
+import (
+	. "Elastos.ELA/common"
+)
+
+// UTXOSetInfo describes the UTXO set at a block. Hash is the sha256 of the
+// snapshot of the set: the height and hash of the block, the headers up to
+// it, then the asset records, unspent transactions and balances in the order
+// of their keys, so nodes at the same block get the same hash.
+type UTXOSetInfo struct {
+	Height       uint32
+	BlockHash    Uint256
+	Transactions uint64
+	TxOuts       uint64
+	Balances     uint64
+	Amounts      map[Uint256]Fixed64
+	Hash         Uint256
+}
@@ -0,0 +1,166 @@
+package ledger
+
+import (
+	. "Elastos.ELA/common"
+	. "Elastos.ELA/core/asset"
+	"Elastos.ELA/core/signature"
+	tx "Elastos.ELA/core/transaction"
+	"Elastos.ELA/core/transaction/payload"
+)
+
+type outPoint struct {
+	txID  Uint256
+	index uint16
+}
+
+type balanceKey struct {
+	programHash Uint168
+	assetID     Uint256
+}
+
+// UTXOView is the ledger as seen by a transaction of a block being
+// connected. Transactions added to the view have their outputs available
+// and their inputs spent, so a block can spend outputs created earlier in
+// the same block. The same holds for the balances they credit and debit,
+// the amounts of the assets they issue and the contracts they deploy.
+type UTXOView struct {
+	store     ILedgerStore
+	txns      map[Uint256]*tx.Transaction
+	spent     map[outPoint]struct{}
+	balances  map[balanceKey]Fixed64
+	issued    map[Uint256]Fixed64
+	contracts map[Uint168]*payload.DeployCode
+}
+
+func NewUTXOView(store ILedgerStore) *UTXOView {
+	return &UTXOView{
+		store:     store,
+		txns:      make(map[Uint256]*tx.Transaction),
+		spent:     make(map[outPoint]struct{}),
+		balances:  make(map[balanceKey]Fixed64),
+		issued:    make(map[Uint256]Fixed64),
+		contracts: make(map[Uint168]*payload.DeployCode),
+	}
+}
+
+// GetTransaction returns a transaction added to the view or else stored in
+// the ledger, it implements transaction.ILedgerStore.
+func (v *UTXOView) GetTransaction(hash Uint256) (*tx.Transaction, uint32, error) {
+	if txn, ok := v.txns[hash]; ok {
+		return txn, v.store.GetHeight() + 1, nil
+	}
+	return v.store.GetTransaction(hash)
+}
+
+// GetQuantityIssued returns the issued amount of the asset in the ledger
+// with the changes of the transactions of the view.
+func (v *UTXOView) GetQuantityIssued(assetID Uint256) (Fixed64, error) {
+	change, ok := v.issued[assetID]
+	issued, err := v.store.GetQuantityIssued(assetID)
+	if err != nil {
+		if !ok {
+			return 0, err
+		}
+		// registered by a transaction of the view
+		issued = 0
+	}
+	return issued + change, nil
+}
+
+// GetAsset returns an asset registered by a transaction of the view or else
+// stored in the ledger.
+func (v *UTXOView) GetAsset(assetID Uint256) (*Asset, error) {
+	if txn, ok := v.txns[assetID]; ok && txn.TxType == tx.RegisterAsset {
+		return txn.Payload.(*payload.RegisterAsset).Asset, nil
+	}
+	return v.store.GetAsset(assetID)
+}
+
+// GetBalance returns the balance of the program hash in the ledger with the
+// changes of the transactions of the view.
+func (v *UTXOView) GetBalance(programHash Uint168, assetID Uint256) (Fixed64, error) {
+	balance, err := v.store.GetBalance(programHash, assetID)
+	if err != nil {
+		return 0, err
+	}
+	return balance + v.balances[balanceKey{programHash, assetID}], nil
+}
+
+// GetContract returns a contract deployed by a transaction of the view or
+// else stored in the ledger.
+func (v *UTXOView) GetContract(codeHash Uint168) (*payload.DeployCode, error) {
+	if dc, ok := v.contracts[codeHash]; ok {
+		return dc, nil
+	}
+	return v.store.GetContract(codeHash)
+}
+
+// IsTxHashDuplicate reports whether a transaction with the hash is already
+// in the view or in the ledger.
+func (v *UTXOView) IsTxHashDuplicate(hash Uint256) bool {
+	if _, ok := v.txns[hash]; ok {
+		return true
+	}
+	return v.store.IsTxHashDuplicate(hash)
+}
+
+// IsDoubleSpend reports whether an input of the transaction refers to an
+// output which is spent or does not exist.
+func (v *UTXOView) IsDoubleSpend(txn *tx.Transaction) bool {
+	stored := make([]*tx.UTXOTxInput, 0, len(txn.UTXOInputs))
+	for _, input := range txn.UTXOInputs {
+		if _, ok := v.spent[outPoint{input.ReferTxID, input.ReferTxOutputIndex}]; ok {
+			return true
+		}
+		if referTxn, ok := v.txns[input.ReferTxID]; ok {
+			if int(input.ReferTxOutputIndex) >= len(referTxn.Outputs) {
+				return true
+			}
+			continue
+		}
+		stored = append(stored, input)
+	}
+	if len(stored) == 0 {
+		return false
+	}
+
+	return v.store.IsDoubleSpend(&tx.Transaction{UTXOInputs: stored})
+}
+
+// AddTransaction makes the outputs of the transaction available to the
+// transactions added after it, marks its inputs spent and applies its
+// balance and issued amount changes, and deploys its contract.
+func (v *UTXOView) AddTransaction(txn *tx.Transaction) {
+	v.txns[txn.Hash()] = txn
+	if txn.TxType == tx.RegisterAsset {
+		if _, ok := v.issued[txn.Hash()]; !ok {
+			v.issued[txn.Hash()] = 0
+		}
+	}
+	if dc, ok := txn.Payload.(*payload.DeployCode); ok {
+		if codeHash, err := signature.ToProgramHash(dc.Code.Code); err == nil {
+			v.contracts[codeHash] = dc
+		}
+	}
+	for _, output := range txn.Outputs {
+		if asset, err := v.GetAsset(output.AssetID); err == nil && asset.RecordType == Balance {
+			v.balances[balanceKey{output.ProgramHash, output.AssetID}] += output.Value
+		}
+		v.issued[output.AssetID] += output.Value
+	}
+	if txn.IsCoinBaseTx() {
+		return
+	}
+	if reference, err := txn.GetReferenceFrom(v); err == nil {
+		for _, output := range reference {
+			v.issued[output.AssetID] -= output.Value
+		}
+	}
+	for _, input := range txn.UTXOInputs {
+		v.spent[outPoint{input.ReferTxID, input.ReferTxOutputIndex}] = struct{}{}
+	}
+	for _, input := range txn.BalanceInputs {
+		v.balances[balanceKey{input.ProgramHash, input.AssetID}] -= input.Value
+		v.issued[input.AssetID] -= input.Value
+	}
+}
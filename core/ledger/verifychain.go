@@ -0,0 +1,16 @@
+package ledger
+
+import (
+	"fmt"
+)
+
+// ChainInconsistency is a disagreement between the records of the ledger
+// store, Key is the database key of the faulty record.
+type ChainInconsistency struct {
+	Key     []byte
+	Message string
+}
+
+func (c *ChainInconsistency) String() string {
+	return fmt.Sprintf("%x: %s", c.Key, c.Message)
+}
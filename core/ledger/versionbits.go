@@ -0,0 +1,295 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/config"
+	tx "Elastos.ELA/core/transaction"
+)
+
+const (
+	// VersionBitsTopBits are the top bits of a block version signalling
+	// deployments, the remaining VersionBitsNumBits bits are the signals.
+	VersionBitsTopBits uint32 = 0x20000000
+	VersionBitsTopMask uint32 = 0xe0000000
+	VersionBitsNumBits        = 29
+)
+
+// ThresholdState is the state of a deployment, it changes only at the
+// boundaries of the miner confirmation windows.
+type ThresholdState byte
+
+const (
+	ThresholdDefined ThresholdState = iota
+	ThresholdStarted
+	ThresholdLockedIn
+	ThresholdActive
+	ThresholdFailed
+)
+
+var thresholdStateStrings = map[ThresholdState]string{
+	ThresholdDefined:  "defined",
+	ThresholdStarted:  "started",
+	ThresholdLockedIn: "locked_in",
+	ThresholdActive:   "active",
+	ThresholdFailed:   "failed",
+}
+
+func (state ThresholdState) String() string {
+	if s, ok := thresholdStateStrings[state]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown(%d)", byte(state))
+}
+
+// thresholdCache holds the state of a deployment for the blocks after a
+// window, keyed on the hash of the node ending the window.
+type thresholdCache map[Uint256]ThresholdState
+
+// DeploymentInfo is the state of a deployment for the next block.
+type DeploymentInfo struct {
+	Name      string
+	Bit       uint8
+	StartTime int64
+	Timeout   int64
+	State     ThresholdState
+}
+
+func findDeployment(name string) (*config.Deployment, error) {
+	for i, d := range config.Parameters.ChainParam.Deployments {
+		if d.Name == name {
+			return &config.Parameters.ChainParam.Deployments[i], nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("unknown deployment %s", name))
+}
+
+// ancestorNode returns the ancestor of node at height. Main chain blocks
+// below the nodes kept in memory are read from the store, without adding
+// them to the block index.
+func (bc *Blockchain) ancestorNode(node *BlockNode, height uint32) *BlockNode {
+	if node == nil || height > node.Height {
+		return nil
+	}
+	for node != nil && node.Height > height {
+		if node.Parent != nil {
+			node = node.Parent
+			continue
+		}
+		if !node.InMainChain {
+			return nil
+		}
+		hash, err := bc.Ledger.Store.GetBlockHash(height)
+		if err != nil {
+			return nil
+		}
+		header, err := bc.Ledger.Store.GetHeader(hash)
+		if err != nil {
+			return nil
+		}
+		node = NewBlockNode(header.Blockdata, &hash)
+		node.InMainChain = true
+	}
+
+	return node
+}
+
+// medianTimePast is CalcPastMedianTime reading ancestors below the nodes
+// kept in memory from the store.
+func (bc *Blockchain) medianTimePast(node *BlockNode) int64 {
+	timestamps := make([]int64, 0, medianTimeBlocks)
+	for i := 0; i < medianTimeBlocks && node != nil; i++ {
+		timestamps = append(timestamps, int64(node.Timestamp))
+		if node.Height == 0 {
+			break
+		}
+		node = bc.ancestorNode(node, node.Height-1)
+	}
+	sort.Sort(timeSorter(timestamps))
+
+	return timestamps[len(timestamps)/2]
+}
+
+// deploymentState returns the state of the deployment for the block after
+// prevNode. It walks back one window at a time until a cached state or a
+// block before the start time, and replays the state changes from there.
+func (bc *Blockchain) deploymentState(prevNode *BlockNode, d *config.Deployment) ThresholdState {
+	if bc.deploymentCaches == nil {
+		bc.deploymentCaches = make(map[string]thresholdCache)
+	}
+	cache, ok := bc.deploymentCaches[d.Name]
+	if !ok {
+		cache = make(thresholdCache)
+		bc.deploymentCaches[d.Name] = cache
+	}
+
+	window := config.Parameters.ChainParam.MinerConfirmationWindow
+	threshold := config.Parameters.ChainParam.RuleChangeActivationThreshold
+
+	// The state is the one of the last block of the previous window.
+	if prevNode == nil || prevNode.Height+1 < window {
+		return ThresholdDefined
+	}
+	prevNode = bc.ancestorNode(prevNode, prevNode.Height-(prevNode.Height+1)%window)
+
+	var neededNodes []*BlockNode
+	state := ThresholdDefined
+	for prevNode != nil {
+		if cached, ok := cache[*prevNode.Hash]; ok {
+			state = cached
+			break
+		}
+		if bc.medianTimePast(prevNode) < d.StartTime {
+			cache[*prevNode.Hash] = ThresholdDefined
+			break
+		}
+		neededNodes = append(neededNodes, prevNode)
+		if prevNode.Height < window {
+			break
+		}
+		prevNode = bc.ancestorNode(prevNode, prevNode.Height-window)
+	}
+
+	for i := len(neededNodes) - 1; i >= 0; i-- {
+		node := neededNodes[i]
+		switch state {
+		case ThresholdDefined:
+			medianTime := bc.medianTimePast(node)
+			if medianTime >= d.Timeout {
+				state = ThresholdFailed
+			} else if medianTime >= d.StartTime {
+				state = ThresholdStarted
+			}
+
+		case ThresholdStarted:
+			if bc.medianTimePast(node) >= d.Timeout {
+				state = ThresholdFailed
+				break
+			}
+			count := uint32(0)
+			n := node
+			for j := uint32(0); j < window && n != nil; j++ {
+				if n.Version&VersionBitsTopMask == VersionBitsTopBits &&
+					n.Version&(uint32(1)<<d.Bit) != 0 {
+					count++
+				}
+				if n.Height == 0 {
+					break
+				}
+				n = bc.ancestorNode(n, n.Height-1)
+			}
+			if count >= threshold {
+				state = ThresholdLockedIn
+			}
+
+		case ThresholdLockedIn:
+			state = ThresholdActive
+		}
+		cache[*node.Hash] = state
+	}
+
+	return state
+}
+
+// IsDeploymentActive reports whether the rules of the deployment apply to
+// the block after prevNode. It is called with the chain lock held, as by
+// the block validators.
+func (bc *Blockchain) IsDeploymentActive(name string, prevNode *BlockNode) (bool, error) {
+	d, err := findDeployment(name)
+	if err != nil {
+		return false, err
+	}
+	return bc.deploymentState(prevNode, d) == ThresholdActive, nil
+}
+
+// deploymentActive is IsDeploymentActive for a deployment defined on every
+// network, an unknown deployment is not active.
+func (bc *Blockchain) deploymentActive(name string, prevNode *BlockNode) bool {
+	active, err := bc.IsDeploymentActive(name, prevNode)
+	return err == nil && active
+}
+
+// ruleContext holds the rule changes of the deployments active in the
+// block after a node, the block transactions are checked for.
+type ruleContext struct {
+	// balanceInputs accepts balance inputs, and outputs of the Balance
+	// record type assets
+	balanceInputs bool
+	// assetIssuance accepts IssueAsset transactions, and outputs of every
+	// registered asset
+	assetIssuance bool
+	// scriptFlags are the rules the programs of transactions are verified
+	// with
+	scriptFlags tx.VerifyFlags
+}
+
+// newRuleContext returns the rules of the block after prevNode, it is
+// called with the chain lock held.
+func (bc *Blockchain) newRuleContext(prevNode *BlockNode) *ruleContext {
+	rules := &ruleContext{
+		balanceInputs: bc.deploymentActive(config.DeploymentBalanceInputs, prevNode),
+		assetIssuance: bc.deploymentActive(config.DeploymentAssetIssuance, prevNode),
+	}
+	if bc.deploymentActive(config.DeploymentScripts, prevNode) {
+		rules.scriptFlags |= tx.VerifyScripts
+	}
+	if bc.deploymentActive(config.DeploymentSigHashTypes, prevNode) {
+		rules.scriptFlags |= tx.VerifySigHashTypes
+	}
+	if bc.deploymentActive(config.DeploymentSequenceLocks, prevNode) {
+		rules.scriptFlags |= tx.VerifySequenceLocks
+	}
+
+	return rules
+}
+
+// bestRuleContext returns the rules of the block after the best chain tip,
+// the transactions of the transaction pool are checked for.
+func (bc *Blockchain) bestRuleContext() *ruleContext {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	return bc.newRuleContext(bc.BestChain)
+}
+
+// ComputeBlockVersion returns the version of the block after prevNode,
+// signalling the deployments which are started or locked in.
+func (bc *Blockchain) ComputeBlockVersion(prevNode *BlockNode) uint32 {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	version := VersionBitsTopBits
+	for i := range config.Parameters.ChainParam.Deployments {
+		d := &config.Parameters.ChainParam.Deployments[i]
+		state := bc.deploymentState(prevNode, d)
+		if state == ThresholdStarted || state == ThresholdLockedIn {
+			version |= uint32(1) << d.Bit
+		}
+	}
+
+	return version
+}
+
+// GetDeploymentInfo returns the state of every deployment for the block
+// after the best block.
+func (bc *Blockchain) GetDeploymentInfo() []DeploymentInfo {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	infos := make([]DeploymentInfo, 0, len(config.Parameters.ChainParam.Deployments))
+	for i := range config.Parameters.ChainParam.Deployments {
+		d := &config.Parameters.ChainParam.Deployments[i]
+		infos = append(infos, DeploymentInfo{
+			Name:      d.Name,
+			Bit:       d.Bit,
+			StartTime: d.StartTime,
+			Timeout:   d.Timeout,
+			State:     bc.deploymentState(bc.BestChain, d),
+		})
+	}
+
+	return infos
+}
@@ -0,0 +1,187 @@
+package ledger
+
+import (
+	"io/ioutil"
+	"math"
+	"testing"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/config"
+	"Elastos.ELA/common/log"
+	tx "Elastos.ELA/core/transaction"
+)
+
+// The tests run on the RegNet parameters of the config.json next to this
+// file, a retarget window of 10 blocks with a threshold of 8.
+
+func init() {
+	log.Log = log.New(ioutil.Discard, "", 0, math.MaxInt32, nil)
+}
+
+// newTestNode returns a block node after parent, the genesis node if
+// parent is nil. The nodes are kept in memory, the store is not read.
+func newTestNode(parent *BlockNode, version, timestamp uint32) *BlockNode {
+	height := uint32(0)
+	if parent != nil {
+		height = parent.Height + 1
+	}
+	hash := Uint256{byte(height), byte(height >> 8), byte(timestamp), byte(timestamp >> 8), 0xbb}
+	node := NewBlockNode(&Blockdata{Version: version, Timestamp: timestamp, Height: height}, &hash)
+	node.Parent = parent
+	node.InMainChain = true
+
+	return node
+}
+
+// newTestNodes returns a chain of len(versions) nodes with the versions,
+// block h has the timestamp 1000+100*h. The median time past of the last
+// block of window i is 1500 for i = 0, and 1000+100*(10*i+4) after.
+func newTestNodes(versions []uint32) []*BlockNode {
+	nodes := make([]*BlockNode, 0, len(versions))
+	var parent *BlockNode
+	for h, version := range versions {
+		parent = newTestNode(parent, version, uint32(1000+100*h))
+		nodes = append(nodes, parent)
+	}
+
+	return nodes
+}
+
+func TestDeploymentState(t *testing.T) {
+	const (
+		bit    = 1
+		window = 10
+	)
+	signal := VersionBitsTopBits | 1<<bit
+
+	tests := []struct {
+		name      string
+		startTime int64
+		timeout   int64
+		// the number of blocks signalling in each window
+		signalling []int
+		version    uint32
+		// the state of the blocks after each window
+		states []ThresholdState
+	}{
+		{"activation", 2000, math.MaxInt64, []int{0, 0, 8, 0, 0}, signal,
+			[]ThresholdState{ThresholdDefined, ThresholdStarted, ThresholdLockedIn, ThresholdActive, ThresholdActive}},
+		{"signalling before the start", 2000, math.MaxInt64, []int{10, 0, 0, 0}, signal,
+			[]ThresholdState{ThresholdDefined, ThresholdStarted, ThresholdStarted, ThresholdStarted}},
+		{"threshold missed", 2000, math.MaxInt64, []int{0, 0, 7, 8, 0}, signal,
+			[]ThresholdState{ThresholdDefined, ThresholdStarted, ThresholdStarted, ThresholdLockedIn, ThresholdActive}},
+		{"top bits missing", 2000, math.MaxInt64, []int{0, 0, 10, 0}, 1 << bit,
+			[]ThresholdState{ThresholdDefined, ThresholdStarted, ThresholdStarted, ThresholdStarted}},
+		{"other bit", 2000, math.MaxInt64, []int{0, 0, 10, 0}, VersionBitsTopBits | 1<<(bit+1),
+			[]ThresholdState{ThresholdDefined, ThresholdStarted, ThresholdStarted, ThresholdStarted}},
+		{"timeout", 2000, 4000, []int{0, 0, 7, 10, 0}, signal,
+			[]ThresholdState{ThresholdDefined, ThresholdStarted, ThresholdStarted, ThresholdFailed, ThresholdFailed}},
+		{"timeout before lock in", 2000, 3000, []int{0, 0, 8, 0}, signal,
+			[]ThresholdState{ThresholdDefined, ThresholdStarted, ThresholdFailed, ThresholdFailed}},
+		{"timeout after lock in", 2000, 3500, []int{0, 0, 8, 0, 0}, signal,
+			[]ThresholdState{ThresholdDefined, ThresholdStarted, ThresholdLockedIn, ThresholdActive, ThresholdActive}},
+		{"timeout before the start", 2000, 2000, []int{0, 10, 0}, signal,
+			[]ThresholdState{ThresholdDefined, ThresholdFailed, ThresholdFailed}},
+	}
+	for _, test := range tests {
+		var versions []uint32
+		for _, count := range test.signalling {
+			for i := 0; i < window; i++ {
+				if i < count {
+					versions = append(versions, test.version)
+				} else {
+					versions = append(versions, VersionBitsTopBits)
+				}
+			}
+		}
+		nodes := newTestNodes(versions)
+		d := &config.Deployment{Name: "test", Bit: bit, StartTime: test.startTime, Timeout: test.timeout}
+
+		// The state is the same for every block of a window, the blocks of
+		// the first window are always defined. The states are asked from
+		// the tip down, so they are not all found in the cache.
+		bc := &Blockchain{}
+		for h := len(nodes) - 1; h >= 0; h-- {
+			want := ThresholdDefined
+			if h+1 >= window {
+				want = test.states[(h+1)/window-1]
+			}
+			if got := bc.deploymentState(nodes[h], d); got != want {
+				t.Errorf("%s: state after block %d is %v, want %v", test.name, h, got, want)
+			}
+		}
+
+		// A cache filled from the genesis block up gives the same states.
+		bc = &Blockchain{}
+		for h, node := range nodes {
+			want := (&Blockchain{}).deploymentState(node, d)
+			if got := bc.deploymentState(node, d); got != want {
+				t.Errorf("%s: cached state after block %d is %v, want %v", test.name, h, got, want)
+			}
+		}
+	}
+
+	if state := (&Blockchain{}).deploymentState(nil, &config.Deployment{Name: "test"}); state != ThresholdDefined {
+		t.Errorf("state of the genesis block is %v, want %v", state, ThresholdDefined)
+	}
+}
+
+func TestComputeBlockVersion(t *testing.T) {
+	// Every RegNet deployment starts at time 0, they are started from the
+	// second window on.
+	started := VersionBitsTopBits
+	for _, d := range config.Parameters.ChainParam.Deployments {
+		started |= 1 << d.Bit
+	}
+
+	versions := make([]uint32, 40)
+	for h := range versions {
+		versions[h] = VersionBitsTopBits
+		if h >= 10 && h < 20 {
+			versions[h] = started
+		}
+	}
+	nodes := newTestNodes(versions)
+
+	bc := &Blockchain{}
+	tests := []struct {
+		height  int
+		version uint32
+		active  bool
+	}{
+		{0, VersionBitsTopBits, false},
+		{8, VersionBitsTopBits, false},
+		{9, started, false},
+		{19, started, false},
+		{29, VersionBitsTopBits, true},
+		{39, VersionBitsTopBits, true},
+	}
+	for _, test := range tests {
+		if version := bc.ComputeBlockVersion(nodes[test.height]); version != test.version {
+			t.Errorf("version after block %d is %x, want %x", test.height, version, test.version)
+		}
+		active, err := bc.IsDeploymentActive(config.DeploymentSequenceLocks, nodes[test.height])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if active != test.active {
+			t.Errorf("sequence locks active after block %d is %v, want %v", test.height, active, test.active)
+		}
+		rules := bc.newRuleContext(nodes[test.height])
+		want := ruleContext{}
+		if test.active {
+			want = ruleContext{
+				balanceInputs: true,
+				assetIssuance: true,
+				scriptFlags:   tx.VerifyScripts | tx.VerifySigHashTypes | tx.VerifySequenceLocks,
+			}
+		}
+		if *rules != want {
+			t.Errorf("rules after block %d are %+v, want %+v", test.height, *rules, want)
+		}
+	}
+
+	if _, err := bc.IsDeploymentActive("unknown", nodes[0]); err == nil {
+		t.Error("unknown deployment is reported")
+	}
+}
@@ -0,0 +1,183 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/serialization"
+	"Elastos.ELA/crypto"
+)
+
+// The hash types of a signature, appended to the signature as one byte.
+// A signature without a hash type commits to the whole unsigned
+// transaction data.
+const (
+	// SIGHASH_ALL commits to all the inputs and all the outputs.
+	SIGHASH_ALL = 0x01
+
+	// SIGHASH_NONE commits to all the inputs but to none of the outputs,
+	// the sequences of the other inputs may change.
+	SIGHASH_NONE = 0x02
+
+	// SIGHASH_SINGLE commits to all the inputs and to the output with the
+	// index of the signed input, the sequences of the other inputs may
+	// change.
+	SIGHASH_SINGLE = 0x03
+
+	// SIGHASH_ANYONECANPAY is combined with one of the above to commit to
+	// the signed input only, others may add inputs.
+	SIGHASH_ANYONECANPAY = 0x80
+)
+
+// SighashData is the transaction data the signatures with a hash type
+// commit to.
+type SighashData interface {
+	// SerializeSighashCommon writes the data committed to by every hash
+	// type, the whole unsigned transaction but its inputs, outputs and
+	// lock time.
+	SerializeSighashCommon(w io.Writer) error
+
+	GetInputCount() int
+
+	// SerializeInputReference writes the outpoint spent by the input, the
+	// hash of the referenced transaction and the output index.
+	SerializeInputReference(w io.Writer, index int) error
+
+	// SerializeSpentOutput writes the output spent by the input as it is
+	// serialized in the referenced transaction.
+	SerializeSpentOutput(w io.Writer, index int) error
+
+	GetInputSequence(index int) uint32
+
+	GetOutputCount() int
+
+	SerializeOutput(w io.Writer, index int) error
+
+	GetLockTime() uint32
+}
+
+// SignatureHash returns the digest signed by a signature with the hash
+// type for the input of the transaction at inputIndex. The signer of a
+// program spending no input signs with inputIndex -1, which is only valid
+// with SIGHASH_ALL and SIGHASH_NONE. The outputs spent by the inputs
+// committed to are committed to with them, so the value a signer spends
+// and the fee it pays can not be changed.
+func SignatureHash(data SighashData, inputIndex int, hashType byte) (Uint256, error) {
+	baseType := hashType &^ SIGHASH_ANYONECANPAY
+	if baseType < SIGHASH_ALL || baseType > SIGHASH_SINGLE {
+		return Uint256{}, errors.New(fmt.Sprintf("invalid signature hash type 0x%02x", hashType))
+	}
+	anyoneCanPay := hashType&SIGHASH_ANYONECANPAY != 0
+
+	hasInput := inputIndex >= 0 && inputIndex < data.GetInputCount()
+	if !hasInput && (inputIndex != -1 || anyoneCanPay || baseType == SIGHASH_SINGLE) {
+		return Uint256{}, errors.New(fmt.Sprintf("invalid input index %d for signature hash type 0x%02x",
+			inputIndex, hashType))
+	}
+	if baseType == SIGHASH_SINGLE && inputIndex >= data.GetOutputCount() {
+		return Uint256{}, errors.New(fmt.Sprintf("no output %d for SIGHASH_SINGLE", inputIndex))
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(hashType)
+	if err := data.SerializeSighashCommon(buf); err != nil {
+		return Uint256{}, err
+	}
+
+	// The hashes of the parts not committed to are left zero.
+	var inputsHash, sequencesHash, outputsHash Uint256
+	var err error
+	if !anyoneCanPay {
+		inputsHash, err = doubleHash(func(w io.Writer) error {
+			for i := 0; i < data.GetInputCount(); i++ {
+				if err := data.SerializeInputReference(w, i); err != nil {
+					return err
+				}
+				if err := data.SerializeSpentOutput(w, i); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return Uint256{}, err
+		}
+		if baseType == SIGHASH_ALL {
+			sequencesHash, err = doubleHash(func(w io.Writer) error {
+				for i := 0; i < data.GetInputCount(); i++ {
+					if err := serialization.WriteUint32(w, data.GetInputSequence(i)); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return Uint256{}, err
+			}
+		}
+	}
+	switch baseType {
+	case SIGHASH_ALL:
+		outputsHash, err = doubleHash(func(w io.Writer) error {
+			for i := 0; i < data.GetOutputCount(); i++ {
+				if err := data.SerializeOutput(w, i); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case SIGHASH_SINGLE:
+		outputsHash, err = doubleHash(func(w io.Writer) error {
+			return data.SerializeOutput(w, inputIndex)
+		})
+	}
+	if err != nil {
+		return Uint256{}, err
+	}
+
+	buf.Write(inputsHash[:])
+	buf.Write(sequencesHash[:])
+	serialization.WriteUint32(buf, uint32(inputIndex))
+	if hasInput {
+		if err := data.SerializeInputReference(buf, inputIndex); err != nil {
+			return Uint256{}, err
+		}
+		if err := data.SerializeSpentOutput(buf, inputIndex); err != nil {
+			return Uint256{}, err
+		}
+		serialization.WriteUint32(buf, data.GetInputSequence(inputIndex))
+	}
+	buf.Write(outputsHash[:])
+	serialization.WriteUint32(buf, data.GetLockTime())
+
+	return doubleHash(func(w io.Writer) error {
+		_, err := w.Write(buf.Bytes())
+		return err
+	})
+}
+
+// SplitSignature splits the hash type from a signature. A signature of
+// crypto.SIGNATURELEN bytes has no hash type, returned as 0, and signs the
+// whole unsigned transaction data.
+func SplitSignature(signature []byte) ([]byte, byte, error) {
+	switch len(signature) {
+	case crypto.SIGNATURELEN:
+		return signature, 0, nil
+	case crypto.SIGNATURELEN + 1:
+		return signature[:crypto.SIGNATURELEN], signature[crypto.SIGNATURELEN], nil
+	default:
+		return nil, 0, errors.New(fmt.Sprintf("invalid signature length %d", len(signature)))
+	}
+}
+
+func doubleHash(write func(w io.Writer) error) (Uint256, error) {
+	h := sha256.New()
+	if err := write(h); err != nil {
+		return Uint256{}, err
+	}
+	return Uint256(sha256.Sum256(h.Sum(nil))), nil
+}
@@ -48,13 +48,16 @@ func ToProgramHash(code []byte) (Uint168, error) {
 	io.WriteString(md, string(temp[:]))
 	f := md.Sum(nil)
 
-	signType := code[len(code)-1]
-	if signType == STANDARD {
+	if len(code) == 0 {
+		return Uint168{}, errors.New("empty program code")
+	}
+	// The prefixes of the codes ending with STANDARD or MULTISIG are kept,
+	// so no existing address changes. Any other script, which used to be
+	// rejected, is hashed under the multi sign prefix.
+	if code[len(code)-1] == STANDARD {
 		f = append([]byte{33}, f...)
-	} else if signType == MULTISIG {
-		f = append([]byte{18}, f...)
 	} else {
-		return Uint168{}, errors.New("unknown code signature type")
+		f = append([]byte{18}, f...)
 	}
 
 	return Uint168FromBytes(f)
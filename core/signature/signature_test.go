@@ -0,0 +1,55 @@
+package signature
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func testPublicKey(i byte) []byte {
+	return append([]byte{0x02}, bytes.Repeat([]byte{i}, 32)...)
+}
+
+// The program hashes of the codes accepted before the script engine are
+// pinned, the addresses holding funds must not change.
+func TestToProgramHash(t *testing.T) {
+	standard := append(append([]byte{0x21}, testPublicKey(1)...), STANDARD)
+
+	multiSign := []byte{PUSH1 + 1}
+	for i := byte(1); i <= 3; i++ {
+		multiSign = append(append(multiSign, 0x21), testPublicKey(i)...)
+	}
+	multiSign = append(multiSign, PUSH1+2, MULTISIG)
+
+	uncompressed := append([]byte{0x41, 0x04}, bytes.Repeat([]byte{1}, 64)...)
+	uncompressed = append(uncompressed, STANDARD)
+
+	tests := []struct {
+		name    string
+		code    []byte
+		hash    string
+		address string
+	}{
+		{"standard", standard, "210c701e4334097750e484ffba434346f19b0c04ba", "EJHfuzjNiBkDfxrjXehSZmu1RekpKyGcfj"},
+		{"2 of 3 multi sign", multiSign, "1217ca31119c62e6c6d8436705e29b7c56a9ec6719", "8HFeUtemYi68JHZDBbWLEdLAooFZXnLvL1"},
+		{"uncompressed key", uncompressed, "219eb2e21c264196e2c245f698ad1da4a2baebe354", "EXd2YvFdgfTzuPcgH4XgxZtkFm8B11yJXv"},
+		{"other script", []byte{PUSH1}, "12da1745e9b549bd0bfa1a569971c77eba30cd5a4b", "8ay1upNaH66D3arK2LFNTrEDbN8bmkffdG"},
+	}
+	for _, test := range tests {
+		hash, err := ToProgramHash(test.code)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got := hex.EncodeToString(hash[:]); got != test.hash {
+			t.Errorf("%s: program hash %s, want %s", test.name, got, test.hash)
+		}
+		if address, _ := hash.ToAddress(); address != test.address {
+			t.Errorf("%s: address %s, want %s", test.name, address, test.address)
+		}
+	}
+
+	if _, err := ToProgramHash(nil); err == nil {
+		t.Error("empty code has a program hash")
+	}
+}
@@ -2,10 +2,12 @@ package ChainStore
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
 
 	. "Elastos.ELA/common"
+	"Elastos.ELA/common/config"
 	"Elastos.ELA/common/serialization"
 	. "Elastos.ELA/core/ledger"
 	tx "Elastos.ELA/core/transaction"
@@ -122,86 +124,110 @@ func (db *ChainStore) RollbackCurrentBlock(b *Block) error {
 	return nil
 }
 
-func (db *ChainStore) PersistUnspendUTXOs(b *Block) error {
+// key: DATA_BlockUndo || block hash
+// value: outputs spent by the block
+func (db *ChainStore) PersistBlockUndo(b *Block, undo *BlockUndo) error {
+	key := bytes.NewBuffer(nil)
+	key.WriteByte(byte(DATA_BlockUndo))
+	blockHash := b.Hash()
+	blockHash.Serialize(key)
+
+	value := bytes.NewBuffer(nil)
+	if err := undo.Serialize(value); err != nil {
+		return err
+	}
+
+	if err := db.BatchPut(key.Bytes(), value.Bytes()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (db *ChainStore) RollbackBlockUndo(b *Block) error {
+	key := bytes.NewBuffer(nil)
+	key.WriteByte(byte(DATA_BlockUndo))
+	blockHash := b.Hash()
+	blockHash.Serialize(key)
+
+	if err := db.BatchDelete(key.Bytes()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (db *ChainStore) getUnspentBucket(unspendUTXOs map[Uint168]map[Uint256]map[uint32][]*tx.UTXOUnspent,
+	programHash Uint168, assetID Uint256, height uint32) ([]*tx.UTXOUnspent, error) {
+	if _, ok := unspendUTXOs[programHash]; !ok {
+		unspendUTXOs[programHash] = make(map[Uint256]map[uint32][]*tx.UTXOUnspent)
+	}
+	if _, ok := unspendUTXOs[programHash][assetID]; !ok {
+		unspendUTXOs[programHash][assetID] = make(map[uint32][]*tx.UTXOUnspent)
+	}
+	if _, ok := unspendUTXOs[programHash][assetID][height]; !ok {
+		unspents, err := db.GetUnspentElementFromProgramHash(programHash, assetID, height)
+		if err != nil {
+			return nil, err
+		}
+		unspendUTXOs[programHash][assetID][height] = unspents
+	}
+
+	return unspendUTXOs[programHash][assetID][height], nil
+}
+
+func (db *ChainStore) PersistUnspendUTXOs(b *Block, undo *BlockUndo) error {
 	unspendUTXOs := make(map[Uint168]map[Uint256]map[uint32][]*tx.UTXOUnspent)
 	curHeight := b.Blockdata.Height
+	balanceAssets := db.balanceAssets(b)
 
 	for _, txn := range b.Transactions {
-		if txn.TxType == tx.RegisterAsset {
-			continue
-		}
-
 		for index, output := range txn.Outputs {
+			if balanceAssets[output.AssetID] {
+				continue
+			}
 			programHash := output.ProgramHash
 			assetID := output.AssetID
-			value := output.Value
-
-			if _, ok := unspendUTXOs[programHash]; !ok {
-				unspendUTXOs[programHash] = make(map[Uint256]map[uint32][]*tx.UTXOUnspent)
-			}
-
-			if _, ok := unspendUTXOs[programHash][assetID]; !ok {
-				unspendUTXOs[programHash][assetID] = make(map[uint32][]*tx.UTXOUnspent, 0)
-			}
-
-			if _, ok := unspendUTXOs[programHash][assetID][curHeight]; !ok {
-				var err error
-				unspendUTXOs[programHash][assetID][curHeight], err = db.GetUnspentElementFromProgramHash(programHash, assetID, curHeight)
-				if err != nil {
-					unspendUTXOs[programHash][assetID][curHeight] = make([]*tx.UTXOUnspent, 0)
-				}
 
+			unspents, err := db.getUnspentBucket(unspendUTXOs, programHash, assetID, curHeight)
+			if err != nil {
+				unspents = make([]*tx.UTXOUnspent, 0)
 			}
 
 			u := tx.UTXOUnspent{
 				Txid:  txn.Hash(),
 				Index: uint32(index),
-				Value: value,
+				Value: output.Value,
 			}
-			unspendUTXOs[programHash][assetID][curHeight] = append(unspendUTXOs[programHash][assetID][curHeight], &u)
+			unspendUTXOs[programHash][assetID][curHeight] = append(unspents, &u)
 		}
+	}
 
-		if !txn.IsCoinBaseTx() {
-			for _, input := range txn.UTXOInputs {
-				referTxn, height, err := db.GetTransaction(input.ReferTxID)
-				if err != nil {
-					return err
-				}
-				index := input.ReferTxOutputIndex
-				referTxnOutput := referTxn.Outputs[index]
-				programHash := referTxnOutput.ProgramHash
-				assetID := referTxnOutput.AssetID
-
-				if _, ok := unspendUTXOs[programHash]; !ok {
-					unspendUTXOs[programHash] = make(map[Uint256]map[uint32][]*tx.UTXOUnspent)
-				}
-				if _, ok := unspendUTXOs[programHash][assetID]; !ok {
-					unspendUTXOs[programHash][assetID] = make(map[uint32][]*tx.UTXOUnspent)
-				}
-
-				if _, ok := unspendUTXOs[programHash][assetID][height]; !ok {
-					unspendUTXOs[programHash][assetID][height], err = db.GetUnspentElementFromProgramHash(programHash, assetID, height)
+	// outputs created in this block are already in the buckets above,
+	// so they can be spent by later transactions of the same block.
+	for _, spent := range undo.SpentOutputs {
+		programHash := spent.Output.ProgramHash
+		assetID := spent.Output.AssetID
+		height := spent.Height
 
-					if err != nil {
-						return errors.New(fmt.Sprintf("[persist] utxoUnspents programHash:%v, assetId:%v height:%v has no unspent UTXO.", programHash, assetID, height))
-					}
-				}
+		unspents, err := db.getUnspentBucket(unspendUTXOs, programHash, assetID, height)
+		if err != nil {
+			return errors.New(fmt.Sprintf("[persist] utxoUnspents programHash:%v, assetId:%v height:%v has no unspent UTXO.", programHash, assetID, height))
+		}
 
-				flag := false
-				listnum := len(unspendUTXOs[programHash][assetID][height])
-				for i := 0; i < listnum; i++ {
-					if unspendUTXOs[programHash][assetID][height][i].Txid.CompareTo(referTxn.Hash()) == 0 && unspendUTXOs[programHash][assetID][height][i].Index == uint32(index) {
-						unspendUTXOs[programHash][assetID][height][i] = unspendUTXOs[programHash][assetID][height][listnum-1]
-						unspendUTXOs[programHash][assetID][height] = unspendUTXOs[programHash][assetID][height][:listnum-1]
-						flag = true
-						break
-					}
-				}
-				if !flag {
-					return errors.New(fmt.Sprintf("[persist] utxoUnspents NOT find UTXO by txid: %x, index: %d.", referTxn.Hash(), index))
-				}
+		flag := false
+		listnum := len(unspents)
+		for i := 0; i < listnum; i++ {
+			if unspents[i].Txid.CompareTo(spent.ReferTxID) == 0 && unspents[i].Index == uint32(spent.ReferTxOutputIndex) {
+				unspents[i] = unspents[listnum-1]
+				unspendUTXOs[programHash][assetID][height] = unspents[:listnum-1]
+				flag = true
+				break
 			}
 		}
+		if !flag {
+			return errors.New(fmt.Sprintf("[persist] utxoUnspents NOT find UTXO by txid: %x, index: %d.", spent.ReferTxID, spent.ReferTxOutputIndex))
+		}
 	}
 
 	// batch put the utxoUnspents
@@ -220,75 +246,54 @@ func (db *ChainStore) PersistUnspendUTXOs(b *Block) error {
 	return nil
 }
 
-func (db *ChainStore) RollbackUnspendUTXOs(b *Block) error {
+func (db *ChainStore) RollbackUnspendUTXOs(b *Block, undo *BlockUndo) error {
 	unspendUTXOs := make(map[Uint168]map[Uint256]map[uint32][]*tx.UTXOUnspent)
 	height := b.Blockdata.Height
+	balanceAssets := db.balanceAssets(b)
+	blockTxns := make(map[Uint256]bool)
 	for _, txn := range b.Transactions {
-		if txn.TxType == tx.RegisterAsset {
-			continue
-		}
+		blockTxns[txn.Hash()] = true
 		for index, output := range txn.Outputs {
+			if balanceAssets[output.AssetID] {
+				continue
+			}
 			programHash := output.ProgramHash
 			assetID := output.AssetID
-			value := output.Value
-			if _, ok := unspendUTXOs[programHash]; !ok {
-				unspendUTXOs[programHash] = make(map[Uint256]map[uint32][]*tx.UTXOUnspent)
-			}
-			if _, ok := unspendUTXOs[programHash][assetID]; !ok {
-				unspendUTXOs[programHash][assetID] = make(map[uint32][]*tx.UTXOUnspent)
-			}
-			if _, ok := unspendUTXOs[programHash][assetID][height]; !ok {
-				var err error
-				unspendUTXOs[programHash][assetID][height], err = db.GetUnspentElementFromProgramHash(programHash, assetID, height)
-				if err != nil {
-					return errors.New(fmt.Sprintf("[persist] utxoUnspents programHash:%v, assetId:%v has no unspent UTXO.", programHash, assetID))
-				}
-			}
-			u := tx.UTXOUnspent{
-				Txid:  txn.Hash(),
-				Index: uint32(index),
-				Value: value,
+
+			unspents, err := db.getUnspentBucket(unspendUTXOs, programHash, assetID, height)
+			if err != nil {
+				// every output of this block was spent within the block
+				unspents = make([]*tx.UTXOUnspent, 0)
+				unspendUTXOs[programHash][assetID][height] = unspents
 			}
-			var position int
-			for i, unspend := range unspendUTXOs[programHash][assetID][height] {
-				if unspend.Txid == u.Txid && unspend.Index == u.Index {
-					position = i
+			for i, unspend := range unspents {
+				if unspend.Txid == txn.Hash() && unspend.Index == uint32(index) {
+					unspendUTXOs[programHash][assetID][height] = append(unspents[:i], unspents[i+1:]...)
 					break
 				}
 			}
-			unspendUTXOs[programHash][assetID][height] = append(unspendUTXOs[programHash][assetID][height][:position], unspendUTXOs[programHash][assetID][height][position+1:]...)
 		}
+	}
 
-		if !txn.IsCoinBaseTx() {
-			for _, input := range txn.UTXOInputs {
-				referTxn, hh, err := db.GetTransaction(input.ReferTxID)
-				if err != nil {
-					return err
-				}
-				index := input.ReferTxOutputIndex
-				referTxnOutput := referTxn.Outputs[index]
-				programHash := referTxnOutput.ProgramHash
-				assetID := referTxnOutput.AssetID
-				if _, ok := unspendUTXOs[programHash]; !ok {
-					unspendUTXOs[programHash] = make(map[Uint256]map[uint32][]*tx.UTXOUnspent)
-				}
-				if _, ok := unspendUTXOs[programHash][assetID]; !ok {
-					unspendUTXOs[programHash][assetID] = make(map[uint32][]*tx.UTXOUnspent)
-				}
-				if _, ok := unspendUTXOs[programHash][assetID][hh]; !ok {
-					unspendUTXOs[programHash][assetID][hh], err = db.GetUnspentElementFromProgramHash(programHash, assetID, hh)
-					if err != nil {
-						unspendUTXOs[programHash][assetID][hh] = make([]*tx.UTXOUnspent, 0)
-					}
-				}
-				u := tx.UTXOUnspent{
-					Txid:  referTxn.Hash(),
-					Index: uint32(index),
-					Value: referTxnOutput.Value,
-				}
-				unspendUTXOs[programHash][assetID][hh] = append(unspendUTXOs[programHash][assetID][hh], &u)
-			}
+	for _, spent := range undo.SpentOutputs {
+		// outputs created and spent within this block are gone with it
+		if blockTxns[spent.ReferTxID] {
+			continue
 		}
+		programHash := spent.Output.ProgramHash
+		assetID := spent.Output.AssetID
+		hh := spent.Height
+
+		unspents, err := db.getUnspentBucket(unspendUTXOs, programHash, assetID, hh)
+		if err != nil {
+			unspents = make([]*tx.UTXOUnspent, 0)
+		}
+		u := tx.UTXOUnspent{
+			Txid:  spent.ReferTxID,
+			Index: uint32(spent.ReferTxOutputIndex),
+			Value: spent.Output.Value,
+		}
+		unspendUTXOs[programHash][assetID][hh] = append(unspents, &u)
 	}
 
 	for programHash, programHash_value := range unspendUTXOs {
@@ -306,6 +311,215 @@ func (db *ChainStore) RollbackUnspendUTXOs(b *Block) error {
 	return nil
 }
 
+// addressHistoryKey is the key of an address history entry, the height is
+// big endian so the entries of an address are ordered by height.
+// key: IX_AddressHistory || program hash || height || txid || direction || asset id
+func addressHistoryKey(programHash Uint168, h *AddressHistory) []byte {
+	key := bytes.NewBuffer(nil)
+	key.WriteByte(byte(IX_AddressHistory))
+	key.Write(programHash.ToArray())
+	binary.Write(key, binary.BigEndian, h.Height)
+	h.TxID.Serialize(key)
+	key.WriteByte(byte(h.Direction))
+	h.AssetID.Serialize(key)
+
+	return key.Bytes()
+}
+
+type historyKey struct {
+	programHash Uint168
+	direction   HistoryDirection
+	assetID     Uint256
+}
+
+// blockAddressHistory sums the amounts each transaction of the block
+// received to and sent from every address.
+func blockAddressHistory(b *Block, undo *BlockUndo) map[Uint168][]*AddressHistory {
+	type outPoint struct {
+		txID  Uint256
+		index uint16
+	}
+	spentOutputs := make(map[outPoint]*tx.TxOutput)
+	for _, spent := range undo.SpentOutputs {
+		spentOutputs[outPoint{spent.ReferTxID, spent.ReferTxOutputIndex}] = &spent.Output
+	}
+
+	histories := make(map[Uint168][]*AddressHistory)
+	for _, txn := range b.Transactions {
+		txHash := txn.Hash()
+		amounts := make(map[historyKey]Fixed64)
+		var keys []historyKey
+		add := func(output *tx.TxOutput, direction HistoryDirection) {
+			k := historyKey{output.ProgramHash, direction, output.AssetID}
+			if _, ok := amounts[k]; !ok {
+				keys = append(keys, k)
+			}
+			amounts[k] += output.Value
+		}
+		if !txn.IsCoinBaseTx() {
+			for _, input := range txn.UTXOInputs {
+				if output, ok := spentOutputs[outPoint{input.ReferTxID, input.ReferTxOutputIndex}]; ok {
+					add(output, HistorySent)
+				}
+			}
+			for _, input := range txn.BalanceInputs {
+				add(&tx.TxOutput{AssetID: input.AssetID, Value: input.Value, ProgramHash: input.ProgramHash}, HistorySent)
+			}
+		}
+		for _, output := range txn.Outputs {
+			add(output, HistoryReceived)
+		}
+
+		for _, k := range keys {
+			histories[k.programHash] = append(histories[k.programHash], &AddressHistory{
+				Height:    b.Blockdata.Height,
+				TxID:      txHash,
+				Direction: k.direction,
+				AssetID:   k.assetID,
+				Amount:    amounts[k],
+			})
+		}
+	}
+
+	return histories
+}
+
+// key: addressHistoryKey
+// value: amount
+func (db *ChainStore) PersistAddressHistory(b *Block, undo *BlockUndo) error {
+	for programHash, histories := range blockAddressHistory(b, undo) {
+		for _, h := range histories {
+			value := bytes.NewBuffer(nil)
+			if err := h.Amount.Serialize(value); err != nil {
+				return err
+			}
+			if err := db.BatchPut(addressHistoryKey(programHash, h), value.Bytes()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (db *ChainStore) RollbackAddressHistory(b *Block, undo *BlockUndo) error {
+	for programHash, histories := range blockAddressHistory(b, undo) {
+		for _, h := range histories {
+			if err := db.BatchDelete(addressHistoryKey(programHash, h)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// isAddressIndexed reports whether the store holds the address history of
+// every block, it was persisted with AddressIndex from the genesis block or
+// from a full reindex on.
+func (db *ChainStore) isAddressIndexed() bool {
+	data, err := db.Get([]byte{byte(SYS_AddressIndex)})
+	return err == nil && bytes.Equal(data, []byte{1})
+}
+
+// key: SYS_AddressIndex
+// value: 1, only stored while the address history is complete
+func (db *ChainStore) batchPutAddressIndexed(indexed bool) error {
+	if !indexed {
+		return db.BatchDelete([]byte{byte(SYS_AddressIndex)})
+	}
+	return db.BatchPut([]byte{byte(SYS_AddressIndex)}, []byte{1})
+}
+
+func (db *ChainStore) setAddressIndexed(indexed bool) error {
+	if err := db.BatchInit(); err != nil {
+		return err
+	}
+	if err := db.batchPutAddressIndexed(indexed); err != nil {
+		return err
+	}
+	return db.BatchFinish()
+}
+
+// blockIssuedAmounts returns the change of the issued amount of the assets
+// by the block: the outputs it creates less the outputs and the balances it
+// spends. The fees cancel out, so the coinbase only adds its subsidy. Assets
+// registered by the block are included even without change: the asset id is
+// the hash of the RegisterAsset transaction, which its own outputs can not
+// hold, so an asset starts at zero and its outputs are counted with the
+// transactions issuing it, in the same block or later.
+func blockIssuedAmounts(b *Block, undo *BlockUndo) map[Uint256]Fixed64 {
+	amounts := make(map[Uint256]Fixed64)
+	for _, txn := range b.Transactions {
+		if txn.TxType == tx.RegisterAsset {
+			if _, ok := amounts[txn.Hash()]; !ok {
+				amounts[txn.Hash()] = 0
+			}
+		}
+		for _, input := range txn.BalanceInputs {
+			amounts[input.AssetID] -= input.Value
+		}
+		for _, output := range txn.Outputs {
+			amounts[output.AssetID] += output.Value
+		}
+	}
+	for _, spent := range undo.SpentOutputs {
+		amounts[spent.Output.AssetID] -= spent.Output.Value
+	}
+
+	return amounts
+}
+
+// key: ST_QuantityIssued || asset id
+// value: amount of the asset in unspent outputs and balances
+func (db *ChainStore) batchPutQuantityIssued(assetID Uint256, amount Fixed64) error {
+	w := bytes.NewBuffer(nil)
+	amount.Serialize(w)
+
+	return db.BatchPut(append([]byte{byte(ST_QuantityIssued)}, assetID.ToArray()...), w.Bytes())
+}
+
+func (db *ChainStore) PersistQuantityIssued(b *Block, undo *BlockUndo) error {
+	for assetID, amount := range blockIssuedAmounts(b, undo) {
+		issued, err := db.GetQuantityIssued(assetID)
+		if err != nil {
+			issued = 0
+		}
+		if err := db.batchPutQuantityIssued(assetID, issued+amount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *ChainStore) RollbackQuantityIssued(b *Block, undo *BlockUndo) error {
+	registered := make(map[Uint256]bool)
+	for _, txn := range b.Transactions {
+		if txn.TxType == tx.RegisterAsset {
+			registered[txn.Hash()] = true
+		}
+	}
+
+	for assetID, amount := range blockIssuedAmounts(b, undo) {
+		if registered[assetID] {
+			if err := db.BatchDelete(append([]byte{byte(ST_QuantityIssued)}, assetID.ToArray()...)); err != nil {
+				return err
+			}
+			continue
+		}
+		issued, err := db.GetQuantityIssued(assetID)
+		if err != nil {
+			return err
+		}
+		if err := db.batchPutQuantityIssued(assetID, issued-amount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (db *ChainStore) PersistTransactions(b *Block) error {
 
 	for _, txn := range b.Transactions {
@@ -318,6 +532,11 @@ func (db *ChainStore) PersistTransactions(b *Block) error {
 				return err
 			}
 		}
+		if txn.TxType == tx.Deploy {
+			if err := db.PersistContract(txn.Payload.(*payload.DeployCode)); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -332,6 +551,11 @@ func (db *ChainStore) RollbackTransactions(b *Block) error {
 				return err
 			}
 		}
+		if txn.TxType == tx.Deploy {
+			if err := db.RollbackContract(txn.Payload.(*payload.DeployCode)); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -363,20 +587,59 @@ func (bd *ChainStore) RollbackAsset(assetId Uint256) error {
 	return nil
 }
 
+func spentByKey(input *tx.UTXOTxInput) []byte {
+	key := bytes.NewBuffer(nil)
+	key.WriteByte(byte(IX_SpentBy))
+	input.ReferTxID.Serialize(key)
+	serialization.WriteUint16(key, input.ReferTxOutputIndex)
+
+	return key.Bytes()
+}
+
+// key: IX_SpentBy || txid || output index
+// value: spending txid || input index || height
+func (db *ChainStore) PersistSpentBy(input *tx.UTXOTxInput, spentBy *SpentBy) error {
+	value := bytes.NewBuffer(nil)
+	if err := spentBy.Serialize(value); err != nil {
+		return err
+	}
+
+	if err := db.BatchPut(spentByKey(input), value.Bytes()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (db *ChainStore) RollbackSpentBy(input *tx.UTXOTxInput) error {
+	if err := db.BatchDelete(spentByKey(input)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (db *ChainStore) PersistUnspend(b *Block) error {
 	unspentPrefix := []byte{byte(IX_Unspent)}
 	unspents := make(map[Uint256][]uint16)
+	balanceAssets := db.balanceAssets(b)
 	for _, txn := range b.Transactions {
-		if txn.TxType == tx.RegisterAsset {
-			continue
-		}
 		txnHash := txn.Hash()
-		for index := range txn.Outputs {
+		for index, output := range txn.Outputs {
+			if balanceAssets[output.AssetID] {
+				continue
+			}
 			unspents[txnHash] = append(unspents[txnHash], uint16(index))
 		}
 		if !txn.IsCoinBaseTx() {
 			for index, input := range txn.UTXOInputs {
 				referTxnHash := input.ReferTxID
+				if config.Parameters.SpentIndex {
+					spentBy := &SpentBy{TxID: txnHash, Index: uint16(index), Height: b.Blockdata.Height}
+					if err := db.PersistSpentBy(input, spentBy); err != nil {
+						return err
+					}
+				}
 				if _, ok := unspents[referTxnHash]; !ok {
 					unspentValue, err := db.Get(append(unspentPrefix, referTxnHash.ToArray()...))
 					if err != nil {
@@ -406,10 +669,17 @@ func (db *ChainStore) PersistUnspend(b *Block) error {
 		txhash.Serialize(key)
 
 		if len(value) == 0 {
-			db.BatchDelete(key.Bytes())
+			if err := db.BatchDelete(key.Bytes()); err != nil {
+				return err
+			}
+			if err := db.PersistPruneQueue(b.Blockdata.Height, txhash); err != nil {
+				return err
+			}
 		} else {
 			unspentArray := ToByteArray(value)
-			db.BatchPut(key.Bytes(), unspentArray)
+			if err := db.BatchPut(key.Bytes(), unspentArray); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -419,10 +689,11 @@ func (db *ChainStore) PersistUnspend(b *Block) error {
 func (db *ChainStore) RollbackUnspend(b *Block) error {
 	unspentPrefix := []byte{byte(IX_Unspent)}
 	unspents := make(map[Uint256][]uint16)
+	blockTxns := make(map[Uint256]bool)
+	for _, txn := range b.Transactions {
+		blockTxns[txn.Hash()] = true
+	}
 	for _, txn := range b.Transactions {
-		if txn.TxType == tx.RegisterAsset {
-			continue
-		}
 		// remove all utxos created by this transaction
 		txnHash := txn.Hash()
 		if err := db.BatchDelete(append(unspentPrefix, txnHash.ToArray()...)); err != nil {
@@ -433,6 +704,15 @@ func (db *ChainStore) RollbackUnspend(b *Block) error {
 			for _, input := range txn.UTXOInputs {
 				referTxnHash := input.ReferTxID
 				referTxnOutIndex := input.ReferTxOutputIndex
+				if err := db.RollbackSpentBy(input); err != nil {
+					return err
+				}
+				if err := db.RollbackPruneQueue(b.Blockdata.Height, referTxnHash); err != nil {
+					return err
+				}
+				if blockTxns[referTxnHash] {
+					continue
+				}
 				if _, ok := unspents[referTxnHash]; !ok {
 					var err error
 					unspentValue, _ := db.Get(append(unspentPrefix, referTxnHash.ToArray()...))
@@ -454,10 +734,14 @@ func (db *ChainStore) RollbackUnspend(b *Block) error {
 		txhash.Serialize(key)
 
 		if len(value) == 0 {
-			db.BatchDelete(key.Bytes())
+			if err := db.BatchDelete(key.Bytes()); err != nil {
+				return err
+			}
 		} else {
 			unspentArray := ToByteArray(value)
-			db.BatchPut(key.Bytes(), unspentArray)
+			if err := db.BatchPut(key.Bytes(), unspentArray); err != nil {
+				return err
+			}
 		}
 	}
 
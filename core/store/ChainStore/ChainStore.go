@@ -7,9 +7,12 @@ import (
 	"bytes"
 	"errors"
 	"container/list"
+	"encoding/binary"
+	"path/filepath"
 
 	"Elastos.ELA/events"
 	. "Elastos.ELA/common"
+	"Elastos.ELA/common/config"
 	"Elastos.ELA/common/log"
 	. "Elastos.ELA/core/asset"
 	. "Elastos.ELA/core/store"
@@ -17,7 +20,8 @@ import (
 	tx "Elastos.ELA/core/transaction"
 	"Elastos.ELA/common/serialization"
 	"Elastos.ELA/core/contract/program"
-	. "Elastos.ELA/core/store/LevelDBStore"
+	_ "Elastos.ELA/core/store/LevelDBStore"
+	_ "Elastos.ELA/core/store/MemoryStore"
 )
 
 const TaskChanCap = 4
@@ -36,7 +40,7 @@ type rollbackBlockTask struct {
 type persistBlockTask struct {
 	block  *Block
 	ledger *Ledger
-	reply  chan bool
+	reply  chan error
 }
 
 type ChainStore struct {
@@ -54,15 +58,32 @@ type ChainStore struct {
 	currentBlockHeight uint32
 	storedHeaderCount  uint32
 	ledger             *Ledger
+
+	// pruneHeight is the lowest height of the blocks with transactions,
+	// keptSize the size of the transactions from it to the best block.
+	pruneHeight uint32
+	keptSize    uint64
+
+	// reorgJournal is the journal of the reorganization begun, written in
+	// the batch disconnecting its first block. It is set before the
+	// rollback task is sent and cleared by the task loop.
+	reorgJournal []byte
 }
 
+// NewLedgerStore opens the chain store with the backend and in the data
+// directory given by the config file.
 func NewLedgerStore() (ILedgerStore, error) {
-	// TODO: read config file decide which db to use.
-	st, err := NewLevelDBStore("Chain")
+	path := filepath.Join(config.Parameters.DataDir, "Chain")
+	st, err := NewStore(config.Parameters.StoreBackend, path)
 	if err != nil {
 		return nil, err
 	}
 
+	return NewChainStore(st), nil
+}
+
+// NewChainStore returns a chain store persisting to st.
+func NewChainStore(st IStore) *ChainStore {
 	chain := &ChainStore{
 		IStore:      st,
 		headerIndex: map[uint32]Uint256{},
@@ -77,7 +98,7 @@ func NewLedgerStore() (ILedgerStore, error) {
 
 	go chain.loop()
 
-	return chain, nil
+	return chain
 }
 
 func (self *ChainStore) Close() {
@@ -85,7 +106,7 @@ func (self *ChainStore) Close() {
 	self.quit <- closed
 	<-closed
 
-	self.Close()
+	self.IStore.Close()
 }
 
 func (self *ChainStore) loop() {
@@ -95,15 +116,23 @@ func (self *ChainStore) loop() {
 			now := time.Now()
 			switch task := t.(type) {
 			case *persistBlockTask:
-				self.handlePersistBlockTask(task.block, task.ledger)
-				task.reply <- true
+				task.reply <- self.handlePersistBlockTask(task.block, task.ledger)
 				tcall := float64(time.Now().Sub(now)) / float64(time.Second)
 				log.Debugf("handle block exetime: %g num transactions:%d \n", tcall, len(task.block.Transactions))
 			case *rollbackBlockTask:
-				self.handleRollbackBlockTask(task.blockHash)
-				task.reply <- true
+				task.reply <- self.handleRollbackBlockTask(task.blockHash)
 				tcall := float64(time.Now().Sub(now)) / float64(time.Second)
 				log.Debugf("handle block rollback exetime: %g \n", tcall)
+			case *dumpUTXOSetTask:
+				task.reply <- self.handleDumpUTXOSetTask(task.w, task.info)
+				tcall := float64(time.Now().Sub(now)) / float64(time.Second)
+				log.Debugf("handle dump utxo set exetime: %g \n", tcall)
+			case *verifyChainTask:
+				var err error
+				*task.issues, err = self.handleVerifyChainTask(task.depth)
+				task.reply <- err
+				tcall := float64(time.Now().Sub(now)) / float64(time.Second)
+				log.Debugf("handle verify chain exetime: %g \n", tcall)
 			}
 
 		case closed := <-self.quit:
@@ -128,13 +157,12 @@ func (self *ChainStore) clearCache(b *Block) {
 }
 
 func (bd *ChainStore) InitLedgerStoreWithGenesisBlock(genesisBlock *Block) (uint32, error) {
-	prefix := []byte{byte(CFG_Version)}
-	version, err := bd.Get(prefix)
+	version, err := bd.getSchemaVersion()
 	if err != nil {
-		version = []byte{0x00}
+		return 0, err
 	}
 
-	if version[0] == 0x00 {
+	if version == 0x00 {
 		// batch delete old data
 		bd.NewBatch()
 		iter := bd.NewIterator(nil)
@@ -150,13 +178,41 @@ func (bd *ChainStore) InitLedgerStoreWithGenesisBlock(genesisBlock *Block) (uint
 
 		// persist genesis block
 		bd.persist(genesisBlock)
+		if err := bd.setAddressIndexed(config.Parameters.AddressIndex); err != nil {
+			return 0, err
+		}
 
 		// put version to db
-		err = bd.Put(prefix, []byte{0x01})
+		err = bd.putSchemaVersion(CurrentSchemaVersion)
 		if err != nil {
 			return 0, err
 		}
 
+	} else if err := bd.upgradeSchema(version); err != nil {
+		return 0, err
+	}
+
+	// An interrupted reorganization is recovered once the schema is known
+	// to be current, the blocks are persisted with the current layout.
+	if err := bd.recoverReorganize(); err != nil {
+		return 0, err
+	}
+
+	// The blocks persisted without the address index leave its history
+	// incomplete, it is only built again by a full reindex.
+	if !config.Parameters.AddressIndex && bd.isAddressIndexed() {
+		if err := bd.setAddressIndexed(false); err != nil {
+			return 0, err
+		}
+	}
+	if err := bd.reindex(); err != nil {
+		return 0, err
+	}
+	if config.Parameters.AddressIndex && !bd.isAddressIndexed() {
+		return 0, errors.New("the address index is enabled for a chain stored without it, restart with -reindex to build it")
+	}
+	if err := bd.loadPruneState(); err != nil {
+		return 0, err
 	}
 
 	// GenesisBlock should exist in chain
@@ -205,17 +261,128 @@ func (bd *ChainStore) InitLedgerStoreWithGenesisBlock(genesisBlock *Block) (uint
 
 	}
 	//bd.ledger.Blockchain.DumpState()
+	bd.prune()
 
 	return bd.currentBlockHeight, nil
 
 }
 
 func (bd *ChainStore) InitLedgerStore(l *Ledger) error {
-	// TODO: InitLedgerStore
 	bd.ledger = l
+
 	return nil
 }
 
+// BeginReorganize journals the blocks a reorganization is going to
+// disconnect, tip first. The journal is written in the batch disconnecting
+// the first of them, so the store holds either the old chain without a
+// journal or a disconnected block with it.
+func (bd *ChainStore) BeginReorganize(detachBlocks []*Block) error {
+	w := bytes.NewBuffer(nil)
+	if err := serialization.WriteVarUint(w, uint64(len(detachBlocks))); err != nil {
+		return err
+	}
+	for _, b := range detachBlocks {
+		if err := b.Serialize(w); err != nil {
+			return err
+		}
+	}
+	bd.reorgJournal = w.Bytes()
+
+	return nil
+}
+
+// EndReorganize drops the journal once the reorganization is done.
+func (bd *ChainStore) EndReorganize() error {
+	bd.reorgJournal = nil
+	return bd.Delete([]byte{byte(SYS_ReorgJournal)})
+}
+
+// recoverReorganize undoes a reorganization interrupted by a crash. Blocks
+// of the new branch are disconnected down to the fork point and the
+// journaled blocks of the old branch are connected again.
+func (bd *ChainStore) recoverReorganize() error {
+	data, err := bd.Get([]byte{byte(SYS_ReorgJournal)})
+	if err != nil {
+		// no reorganization in progress
+		return nil
+	}
+
+	r := bytes.NewReader(data)
+	count, err := serialization.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	detachBlocks := make([]*Block, 0, count)
+	detached := make(map[Uint256]bool)
+	for i := uint64(0); i < count; i++ {
+		b := new(Block)
+		if err := b.Deserialize(r); err != nil {
+			return err
+		}
+		detachBlocks = append(detachBlocks, b)
+		detached[b.Hash()] = true
+	}
+	if len(detachBlocks) == 0 {
+		return bd.EndReorganize()
+	}
+
+	fork := detachBlocks[len(detachBlocks)-1].Blockdata
+	forkHash, forkHeight := fork.PrevBlockHash, fork.Height-1
+	log.Warnf("[recoverReorganize] interrupted reorganize found, restore chain from fork at height %d", forkHeight)
+
+	tipHash, tipHeight, err := bd.getCurrentBlock()
+	if err != nil {
+		return err
+	}
+	for tipHash != forkHash && !detached[tipHash] {
+		if tipHeight <= forkHeight {
+			return errors.New(fmt.Sprintf("[recoverReorganize] block %x is not on a branch of the fork", tipHash.ToArrayReverse()))
+		}
+		b, err := bd.GetBlock(tipHash)
+		if err != nil {
+			return err
+		}
+		if err := bd.rollbackBlock(b); err != nil {
+			return err
+		}
+		tipHash, tipHeight = b.Blockdata.PrevBlockHash, tipHeight-1
+	}
+
+	for i := len(detachBlocks) - 1; i >= 0; i-- {
+		b := detachBlocks[i]
+		if b.Blockdata.PrevBlockHash != tipHash {
+			continue
+		}
+		if err := bd.persist(b); err != nil {
+			return err
+		}
+		tipHash = b.Hash()
+	}
+	log.Infof("[recoverReorganize] chain restored to block %x", tipHash.ToArrayReverse())
+
+	return bd.EndReorganize()
+}
+
+func (bd *ChainStore) getCurrentBlock() (Uint256, uint32, error) {
+	data, err := bd.Get([]byte{byte(SYS_CurrentBlock)})
+	if err != nil {
+		return Uint256{}, 0, err
+	}
+
+	r := bytes.NewReader(data)
+	var blockHash Uint256
+	if err := blockHash.Deserialize(r); err != nil {
+		return Uint256{}, 0, err
+	}
+	height, err := serialization.ReadUint32(r)
+	if err != nil {
+		return Uint256{}, 0, err
+	}
+
+	return blockHash, height, nil
+}
+
 func (bd *ChainStore) IsTxHashDuplicate(txhash Uint256) bool {
 	prefix := []byte{byte(DATA_Transaction)}
 	_, err_get := bd.Get(append(prefix, txhash.ToArray()...))
@@ -304,7 +471,9 @@ func (db *ChainStore) RollbackBlock(blockHash Uint256) error {
 
 	reply := make(chan bool)
 	db.taskCh <- &rollbackBlockTask{blockHash: blockHash, reply: reply}
-	<-reply
+	if !<-reply {
+		return errors.New(fmt.Sprintf("[RollbackBlock] failed to rollback block %x", BytesToHexString(blockHash.ToArray())))
+	}
 
 	return nil
 }
@@ -383,6 +552,23 @@ func (bd *ChainStore) GetAsset(hash Uint256) (*Asset, error) {
 	return asset, nil
 }
 
+// GetQuantityIssued returns the amount of the asset in unspent outputs and
+// balances, every amount created by the blocks less the amount burned by
+// them.
+func (bd *ChainStore) GetQuantityIssued(assetID Uint256) (Fixed64, error) {
+	data, err := bd.Get(append([]byte{byte(ST_QuantityIssued)}, assetID.ToArray()...))
+	if err != nil {
+		return 0, err
+	}
+
+	var amount Fixed64
+	if err := amount.Deserialize(bytes.NewReader(data)); err != nil {
+		return 0, err
+	}
+
+	return amount, nil
+}
+
 func (bd *ChainStore) GetTransaction(hash Uint256) (*tx.Transaction, uint32, error) {
 	key := append([]byte{byte(DATA_Transaction)}, hash.ToArray()...)
 	value, err := bd.Get(key)
@@ -454,6 +640,9 @@ func (bd *ChainStore) GetBlock(hash Uint256) (*Block, error) {
 	if err := b.FromTrimmedData(r); err != nil {
 		return nil, err
 	}
+	if b.Blockdata.Height < bd.GetPruneHeight() {
+		return nil, ErrBlockPruned
+	}
 
 	// Deserialize transaction
 	for i, txn := range b.Transactions {
@@ -467,22 +656,130 @@ func (bd *ChainStore) GetBlock(hash Uint256) (*Block, error) {
 	return b, nil
 }
 
+// GetBlockUndo returns the outputs spent by the block. Blocks persisted
+// before undo data was recorded get it rebuilt from the spent transactions.
+func (bd *ChainStore) GetBlockUndo(hash Uint256) (*BlockUndo, error) {
+	prefix := []byte{byte(DATA_BlockUndo)}
+	data, err := bd.Get(append(prefix, hash.ToArray()...))
+	if err != nil {
+		b, err := bd.GetBlock(hash)
+		if err != nil {
+			return nil, err
+		}
+		return bd.buildBlockUndo(b)
+	}
+
+	undo := new(BlockUndo)
+	if err := undo.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	return undo, nil
+}
+
+// buildBlockUndo collects the outputs spent by the block, outputs created
+// earlier in the same block are taken from the block itself.
+func (bd *ChainStore) buildBlockUndo(b *Block) (*BlockUndo, error) {
+	undo := new(BlockUndo)
+	blockTxns := make(map[Uint256]*tx.Transaction)
+	for _, txn := range b.Transactions {
+		if !txn.IsCoinBaseTx() {
+			for _, input := range txn.UTXOInputs {
+				referTxn, height := blockTxns[input.ReferTxID], b.Blockdata.Height
+				if referTxn == nil {
+					var err error
+					referTxn, height, err = bd.GetTransaction(input.ReferTxID)
+					if err != nil {
+						return nil, err
+					}
+				}
+				index := input.ReferTxOutputIndex
+				if int(index) >= len(referTxn.Outputs) {
+					return nil, errors.New(fmt.Sprintf("[buildBlockUndo] txid: %x has no output index: %d.", input.ReferTxID, index))
+				}
+				undo.SpentOutputs = append(undo.SpentOutputs, &SpentOutput{
+					ReferTxID:          input.ReferTxID,
+					ReferTxOutputIndex: index,
+					Height:             height,
+					Output:             *referTxn.Outputs[index],
+				})
+			}
+		}
+		blockTxns[txn.Hash()] = txn
+	}
+
+	return undo, nil
+}
+
 func (db *ChainStore) rollback(b *Block) error {
-	db.BatchInit()
-	db.RollbackTrimemedBlock(b)
-	db.RollbackBlockHash(b)
-	db.RollbackTransactions(b)
-	db.RollbackUnspendUTXOs(b)
-	db.RollbackUnspend(b)
-	db.RollbackCurrentBlock(b)
-	db.BatchFinish()
+	if err := db.rollbackBlock(b); err != nil {
+		return err
+	}
 
 	db.ledger.Blockchain.UpdateBestHeight(b.Blockdata.Height - 1)
 	db.mu.Lock()
 	db.currentBlockHeight = b.Blockdata.Height - 1
+	if size := blockSize(b); db.keptSize >= size {
+		db.keptSize -= size
+	}
 	db.mu.Unlock()
 
-	db.ledger.Blockchain.BCEvents.Notify(events.EventRollbackTransaction, b)
+	return nil
+}
+
+// rollbackBlock removes the block from the store in one batch, with the
+// journal of the reorganization it begins if any.
+func (db *ChainStore) rollbackBlock(b *Block) error {
+	undo, err := db.GetBlockUndo(b.Hash())
+	if err != nil {
+		return err
+	}
+
+	if err := db.BatchInit(); err != nil {
+		return err
+	}
+	if db.reorgJournal != nil {
+		if err := db.BatchPut([]byte{byte(SYS_ReorgJournal)}, db.reorgJournal); err != nil {
+			return err
+		}
+	}
+	if err := db.RollbackTrimemedBlock(b); err != nil {
+		return err
+	}
+	if err := db.RollbackBlockHash(b); err != nil {
+		return err
+	}
+	if err := db.RollbackTransactions(b); err != nil {
+		return err
+	}
+	if err := db.RollbackUnspendUTXOs(b, undo); err != nil {
+		return err
+	}
+	if err := db.RollbackUnspend(b); err != nil {
+		return err
+	}
+	if err := db.RollbackAddressHistory(b, undo); err != nil {
+		return err
+	}
+	if err := db.RollbackQuantityIssued(b, undo); err != nil {
+		return err
+	}
+	if err := db.RollbackBalances(b); err != nil {
+		return err
+	}
+	if err := db.RollbackBlockUndo(b); err != nil {
+		return err
+	}
+	if err := db.RollbackBlockSize(b); err != nil {
+		return err
+	}
+	if err := db.RollbackCurrentBlock(b); err != nil {
+		return err
+	}
+	if err := db.BatchFinish(); err != nil {
+		return err
+	}
+	db.reorgJournal = nil
 
 	return nil
 }
@@ -490,16 +787,57 @@ func (db *ChainStore) rollback(b *Block) error {
 func (db *ChainStore) persist(b *Block) error {
 	//unspents := make(map[Uint256][]uint16)
 
-	db.BatchInit()
-	db.PersistTrimmedBlock(b)
-	db.PersistBlockHash(b)
-	db.PersistTransactions(b)
-	db.PersistUnspendUTXOs(b)
-	db.PersistUnspend(b)
-	db.PersistCurrentBlock(b)
-	db.BatchFinish()
+	undo, err := db.buildBlockUndo(b)
+	if err != nil {
+		return err
+	}
+
+	if err := db.BatchInit(); err != nil {
+		return err
+	}
+	if err := db.batchPersist(b, undo); err != nil {
+		return err
+	}
 
-	return nil
+	return db.BatchFinish()
+}
+
+// batchPersist adds the block to the current batch.
+func (db *ChainStore) batchPersist(b *Block, undo *BlockUndo) error {
+	if err := db.PersistTrimmedBlock(b); err != nil {
+		return err
+	}
+	if err := db.PersistBlockHash(b); err != nil {
+		return err
+	}
+	if err := db.PersistTransactions(b); err != nil {
+		return err
+	}
+	if err := db.PersistUnspendUTXOs(b, undo); err != nil {
+		return err
+	}
+	if err := db.PersistUnspend(b); err != nil {
+		return err
+	}
+	if config.Parameters.AddressIndex {
+		if err := db.PersistAddressHistory(b, undo); err != nil {
+			return err
+		}
+	}
+	if err := db.PersistQuantityIssued(b, undo); err != nil {
+		return err
+	}
+	if err := db.PersistBalances(b); err != nil {
+		return err
+	}
+	if err := db.PersistBlockUndo(b, undo); err != nil {
+		return err
+	}
+	if err := db.PersistBlockSize(b); err != nil {
+		return err
+	}
+
+	return db.PersistCurrentBlock(b)
 }
 
 // can only be invoked by backend write goroutine
@@ -528,26 +866,29 @@ func (self *ChainStore) SaveBlock(b *Block, ledger *Ledger) error {
 	//}
 	//log.Trace("validation.PowVerifyBlock(b, ledger, false)222222")
 
-	reply := make(chan bool)
+	reply := make(chan error)
 	self.taskCh <- &persistBlockTask{block: b, ledger: ledger, reply: reply}
-	<-reply
 
-	return nil
+	return <-reply
 }
 
-func (db *ChainStore) handleRollbackBlockTask(blockHash Uint256) {
+func (db *ChainStore) handleRollbackBlockTask(blockHash Uint256) bool {
 	block, err := db.GetBlock(blockHash)
 	if err != nil {
 		log.Errorf("block %x can't be found", BytesToHexString(blockHash.ToArray()))
-		return
+		return false
+	}
+	if err := db.rollback(block); err != nil {
+		log.Errorf("block %x rollback failed: %v", BytesToHexString(blockHash.ToArray()), err)
+		return false
 	}
-	db.rollback(block)
+	return true
 }
 
-func (self *ChainStore) handlePersistBlockTask(b *Block, ledger *Ledger) {
+func (self *ChainStore) handlePersistBlockTask(b *Block, ledger *Ledger) error {
 
 	if b.Blockdata.Height <= self.currentBlockHeight {
-		return
+		return nil
 	}
 
 	//	self.mu.Lock()
@@ -558,7 +899,9 @@ func (self *ChainStore) handlePersistBlockTask(b *Block, ledger *Ledger) {
 	//log.Trace(b.Blockdata)
 	//log.Trace(b.Transactions[0])
 	//if b.Blockdata.Height < uint32(len(self.headerIndex)) {
-	self.persistBlocks(b, ledger)
+	if err := self.persistBlocks(b, ledger); err != nil {
+		return err
+	}
 
 	//self.NewBatch()
 	//storedHeaderCount := self.storedHeaderCount
@@ -592,9 +935,11 @@ func (self *ChainStore) handlePersistBlockTask(b *Block, ledger *Ledger) {
 	//self.mu.Unlock()
 	self.clearCache(b)
 	//}
+
+	return nil
 }
 
-func (bd *ChainStore) persistBlocks(block *Block, ledger *Ledger) {
+func (bd *ChainStore) persistBlocks(block *Block, ledger *Ledger) error {
 	//stopHeight := uint32(len(bd.headerIndex))
 	//for h := bd.currentBlockHeight + 1; h <= stopHeight; h++ {
 	//hash := bd.headerIndex[h]
@@ -606,8 +951,8 @@ func (bd *ChainStore) persistBlocks(block *Block, ledger *Ledger) {
 	//log.Trace(block.Transactions[0])
 	err := bd.persist(block)
 	if err != nil {
-		log.Fatal("[persistBlocks]: error to persist block:", err.Error())
-		return
+		log.Error("[persistBlocks]: error to persist block:", err.Error())
+		return err
 	}
 
 	// PersistCompleted event
@@ -615,12 +960,15 @@ func (bd *ChainStore) persistBlocks(block *Block, ledger *Ledger) {
 	ledger.Blockchain.UpdateBestHeight(block.Blockdata.Height)
 	bd.mu.Lock()
 	bd.currentBlockHeight = block.Blockdata.Height
+	bd.keptSize += blockSize(block)
 	bd.mu.Unlock()
+	bd.prune()
 
 	ledger.Blockchain.BCEvents.Notify(events.EventBlockPersistCompleted, block)
 	//log.Tracef("The latest block height:%d, block hash: %x", block.Blockdata.Height, hash)
 	//}
 
+	return nil
 }
 
 func (bd *ChainStore) BlockInCache(hash Uint256) bool {
@@ -665,6 +1013,26 @@ func (bd *ChainStore) ContainsUnspent(txid Uint256, index uint16) (bool, error)
 	return false, nil
 }
 
+// GetSpendingTransaction returns the input of the main chain which spent
+// the output, or an error if the output is unspent or unknown.
+func (bd *ChainStore) GetSpendingTransaction(txid Uint256, index uint16) (*SpentBy, error) {
+	if !config.Parameters.SpentIndex {
+		return nil, errors.New("spent index is disabled, set SpentIndex in the config file")
+	}
+
+	data, err := bd.Get(spentByKey(&tx.UTXOTxInput{ReferTxID: txid, ReferTxOutputIndex: index}))
+	if err != nil {
+		return nil, err
+	}
+
+	spentBy := new(SpentBy)
+	if err := spentBy.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	return spentBy, nil
+}
+
 func (bd *ChainStore) RemoveHeaderListElement(hash Uint256) {
 	for e := bd.headerIdx.Front(); e != nil; e = e.Next() {
 		n := e.Value.(Header)
@@ -843,6 +1211,51 @@ func (bd *ChainStore) PersistUnspentWithProgramHash(programHash Uint168, assetid
 	return nil
 }
 
+// GetAddressHistory returns the history of an address, newest first,
+// skipping the skip newest entries and returning at most count entries.
+func (bd *ChainStore) GetAddressHistory(programHash Uint168, skip, count int) ([]*AddressHistory, error) {
+	if !config.Parameters.AddressIndex {
+		return nil, errors.New("address index is disabled, set AddressIndex in the config file")
+	}
+
+	prefix := []byte{byte(IX_AddressHistory)}
+	prefix = append(prefix, programHash.ToArray()...)
+	iter := bd.NewIterator(prefix)
+	defer iter.Release()
+
+	histories := make([]*AddressHistory, 0)
+	for ok := iter.Last(); ok && len(histories) < count; ok = iter.Prev() {
+		if skip > 0 {
+			skip--
+			continue
+		}
+
+		rk := bytes.NewReader(iter.Key()[len(prefix):])
+		h := new(AddressHistory)
+		if err := binary.Read(rk, binary.BigEndian, &h.Height); err != nil {
+			return nil, err
+		}
+		if err := h.TxID.Deserialize(rk); err != nil {
+			return nil, err
+		}
+		direction, err := rk.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		h.Direction = HistoryDirection(direction)
+		if err := h.AssetID.Deserialize(rk); err != nil {
+			return nil, err
+		}
+		if err := h.Amount.Deserialize(bytes.NewReader(iter.Value())); err != nil {
+			return nil, err
+		}
+
+		histories = append(histories, h)
+	}
+
+	return histories, nil
+}
+
 func (bd *ChainStore) GetAssets() map[Uint256]*Asset {
 	assets := make(map[Uint256]*Asset)
 
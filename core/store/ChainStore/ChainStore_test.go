@@ -0,0 +1,602 @@
+package ChainStore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/config"
+	"Elastos.ELA/common/log"
+	"Elastos.ELA/common/serialization"
+	. "Elastos.ELA/core/asset"
+	. "Elastos.ELA/core/ledger"
+	"Elastos.ELA/core/store/MemoryStore"
+	tx "Elastos.ELA/core/transaction"
+	"Elastos.ELA/core/transaction/payload"
+	"Elastos.ELA/events"
+)
+
+// The tests run on the RegNet parameters of the config.json next to this
+// file, with a chain kept in a MemoryStore.
+
+func init() {
+	log.Log = log.New(ioutil.Discard, "", 0, math.MaxInt32, nil)
+}
+
+// newTestChain returns a chain store holding the genesis block, it is the
+// store of DefaultLedger.
+func newTestChain(t *testing.T) (*ChainStore, *Blockchain) {
+	store := NewChainStore(MemoryStore.NewMemoryStore())
+	DefaultLedger = &Ledger{Store: store}
+	if err := store.InitLedgerStore(DefaultLedger); err != nil {
+		t.Fatal(err)
+	}
+	bc, err := NewBlockchainWithGenesisBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, bc
+}
+
+// newTestCoinbase returns a coinbase paying the block subsidy at height
+// to programHash, the foundation if programHash is nil.
+func newTestCoinbase(t *testing.T, height uint32, programHash *Uint168) *tx.Transaction {
+	foundation, err := Uint68FromAddress(FoundationAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if programHash == nil {
+		programHash = &foundation
+	}
+
+	coinbase, err := tx.NewCoinBaseTransaction(&payload.CoinBase{}, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coinbase.UTXOInputs[0].ReferTxOutputIndex = math.MaxUint16
+	coinbase.UTXOInputs[0].Sequence = math.MaxUint32
+	subsidy := CalcBlockSubsidy(height)
+	foundationReward := CalcFoundationReward(subsidy)
+	coinbase.Outputs = []*tx.TxOutput{
+		{
+			AssetID:     DefaultLedger.Blockchain.AssetID,
+			Value:       foundationReward,
+			ProgramHash: foundation,
+		},
+		{
+			AssetID:     DefaultLedger.Blockchain.AssetID,
+			Value:       subsidy - foundationReward,
+			ProgramHash: *programHash,
+		},
+	}
+	nonce := make([]byte, 8)
+	binary.BigEndian.PutUint64(nonce, uint64(height))
+	attr := tx.NewTxAttribute(tx.Nonce, nonce)
+	coinbase.Attributes = append(coinbase.Attributes, &attr)
+
+	return coinbase
+}
+
+// newTestBlock returns a block extending the best chain with the coinbase
+// and the transactions.
+func newTestBlock(t *testing.T, bc *Blockchain, coinbase *tx.Transaction, txns ...*tx.Transaction) *Block {
+	parent := bc.BestChain
+	block := &Block{
+		Blockdata: &Blockdata{
+			Version:       BlockVersion,
+			PrevBlockHash: *parent.Hash,
+			Timestamp:     parent.Timestamp + 1,
+			Bits:          parent.Bits,
+			Height:        parent.Height + 1,
+		},
+		Transactions: append([]*tx.Transaction{coinbase}, txns...),
+	}
+	if err := block.RebuildMerkleRoot(); err != nil {
+		t.Fatal(err)
+	}
+
+	return block
+}
+
+// connectTestBlock connects the block to the end of the best chain.
+func connectTestBlock(bc *Blockchain, block *Block) error {
+	hash := block.Hash()
+	node := NewBlockNode(block.Blockdata, &hash)
+	node.Parent = bc.BestChain
+	if err := bc.ConnectBlock(node, block); err != nil {
+		return err
+	}
+	bc.HeaderChain.Reset(bc.BestChain)
+
+	return nil
+}
+
+// extendTestChain connects n blocks holding only a coinbase.
+func extendTestChain(t *testing.T, bc *Blockchain, n int) []*Block {
+	var blocks []*Block
+	for i := 0; i < n; i++ {
+		block := newTestBlock(t, bc, newTestCoinbase(t, bc.BestChain.Height+1, nil))
+		if err := connectTestBlock(bc, block); err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, block)
+	}
+
+	return blocks
+}
+
+// newTestTransaction returns a transaction spending the inputs to the
+// outputs.
+func newTestTransaction(txType tx.TransactionType, p tx.Payload, inputs []*tx.UTXOTxInput, outputs ...*tx.TxOutput) *tx.Transaction {
+	return &tx.Transaction{
+		TxType:     txType,
+		Payload:    p,
+		UTXOInputs: inputs,
+		Outputs:    outputs,
+	}
+}
+
+// newTestInput returns an input spending output index of txn.
+func newTestInput(txn *tx.Transaction, index uint16) *tx.UTXOTxInput {
+	return &tx.UTXOTxInput{
+		ReferTxID:          txn.Hash(),
+		ReferTxOutputIndex: index,
+		Sequence:           math.MaxUint32,
+	}
+}
+
+// newTestRegisterAsset returns a transaction registering an asset with the
+// record type.
+func newTestRegisterAsset(name string, recordType AssetRecordType) *tx.Transaction {
+	return newTestTransaction(tx.RegisterAsset, &payload.RegisterAsset{
+		Asset:  &Asset{Name: name, Precision: 8, RecordType: recordType},
+		Amount: 1000,
+	}, nil)
+}
+
+// persistTestBlock persists a block with the transactions after the best
+// block of the store, without validating it as connectTestBlock does.
+func persistTestBlock(t *testing.T, store *ChainStore, bc *Blockchain, txns ...*tx.Transaction) *Block {
+	block := newTestBlock(t, bc, newTestCoinbase(t, bc.BestChain.Height+1, nil), txns...)
+	if err := store.persist(block); err != nil {
+		t.Fatal(err)
+	}
+	hash := block.Hash()
+	node := NewBlockNode(block.Blockdata, &hash)
+	node.Parent = bc.BestChain
+	node.InMainChain = true
+	bc.BestChain = node
+	store.mu.Lock()
+	store.currentBlockHeight = block.Blockdata.Height
+	store.keptSize += blockSize(block)
+	store.mu.Unlock()
+
+	return block
+}
+
+// rollbackTestBlock removes the best block from the store.
+func rollbackTestBlock(t *testing.T, store *ChainStore, bc *Blockchain, block *Block) {
+	if err := store.rollback(block); err != nil {
+		t.Fatal(err)
+	}
+	bc.BestChain = bc.BestChain.Parent
+}
+
+// storeContent returns the entries of the store with the prefixes, every
+// entry if none is given. The unspent outputs of a transaction or of a
+// program hash are sorted, spending and restoring an output changes their
+// order.
+func storeContent(t *testing.T, store *ChainStore, prefixes ...DataEntryPrefix) map[string][]byte {
+	if len(prefixes) == 0 {
+		prefixes = []DataEntryPrefix{}
+		for prefix := 0; prefix <= 0xff; prefix++ {
+			prefixes = append(prefixes, DataEntryPrefix(prefix))
+		}
+	}
+
+	content := make(map[string][]byte)
+	for _, prefix := range prefixes {
+		iter := store.NewIterator([]byte{byte(prefix)})
+		for iter.Next() {
+			value := append([]byte{}, iter.Value()...)
+			switch prefix {
+			case IX_Unspent:
+				indexes, err := GetUint16Array(value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+				value = ToByteArray(indexes)
+			case IX_Unspent_UTXO:
+				value = sortedUnspents(t, value)
+			}
+			content[string(iter.Key())] = value
+		}
+		iter.Release()
+	}
+
+	return content
+}
+
+func sortedUnspents(t *testing.T, value []byte) []byte {
+	r := bytes.NewReader(value)
+	count, err := serialization.ReadVarUint(r, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unspents := make([]*tx.UTXOUnspent, count)
+	for i := range unspents {
+		unspents[i] = new(tx.UTXOUnspent)
+		if err := unspents[i].Deserialize(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Slice(unspents, func(i, j int) bool {
+		if c := unspents[i].Txid.CompareTo(unspents[j].Txid); c != 0 {
+			return c < 0
+		}
+		return unspents[i].Index < unspents[j].Index
+	})
+
+	w := bytes.NewBuffer(nil)
+	serialization.WriteVarUint(w, count)
+	for _, u := range unspents {
+		u.Serialize(w)
+	}
+	return w.Bytes()
+}
+
+// checkStoreContent reports the entries which differ from want.
+func checkStoreContent(t *testing.T, name string, got, want map[string][]byte) {
+	for key, value := range want {
+		gotValue, ok := got[key]
+		if !ok {
+			t.Errorf("%s: entry %x is missing", name, key)
+		} else if !bytes.Equal(gotValue, value) {
+			t.Errorf("%s: entry %x is %x, want %x", name, key, gotValue, value)
+		}
+	}
+	for key := range got {
+		if _, ok := want[key]; !ok {
+			t.Errorf("%s: unexpected entry %x", name, key)
+		}
+	}
+}
+
+// interruptingStore fails the batch commit number failAt, as a node
+// stopped while writing it.
+type interruptingStore struct {
+	*MemoryStore.MemoryStore
+	commits int
+	failAt  int
+}
+
+var errInterrupted = errors.New("interrupted")
+
+func (s *interruptingStore) BatchCommit() error {
+	s.commits++
+	if s.commits == s.failAt {
+		s.MemoryStore.NewBatch()
+		return errInterrupted
+	}
+	return s.MemoryStore.BatchCommit()
+}
+
+// newTestChainWithSpends returns a chain of 4 blocks spending outputs,
+// registering assets and moving a balance, in a store interrupted on
+// demand.
+func newTestChainWithSpends(t *testing.T) (*ChainStore, *Blockchain, *interruptingStore) {
+	st := &interruptingStore{MemoryStore: MemoryStore.NewMemoryStore()}
+	store := NewChainStore(st)
+	DefaultLedger = &Ledger{Store: store}
+	if err := store.InitLedgerStore(DefaultLedger); err != nil {
+		t.Fatal(err)
+	}
+	bc, err := NewBlockchainWithGenesisBlock()
+	if err != nil {
+		store.Close()
+		t.Fatal(err)
+	}
+
+	ela := DefaultLedger.Blockchain.AssetID
+	cb1 := persistTestBlock(t, store, bc).Transactions[0]
+	balanceAsset := newTestRegisterAsset("balance", Balance)
+	utxoAsset := newTestRegisterAsset("utxo", UTXO)
+	issue := newTestTransaction(tx.IssueAsset, &payload.IssueAsset{}, nil,
+		&tx.TxOutput{AssetID: balanceAsset.Hash(), Value: 100, ProgramHash: Uint168{1}},
+		&tx.TxOutput{AssetID: utxoAsset.Hash(), Value: 100, ProgramHash: Uint168{1}})
+	persistTestBlock(t, store, bc, balanceAsset, utxoAsset, issue)
+	spend := newTestTransaction(tx.TransferAsset, &payload.TransferAsset{},
+		[]*tx.UTXOTxInput{newTestInput(cb1, 0), newTestInput(issue, 1)},
+		&tx.TxOutput{AssetID: ela, Value: cb1.Outputs[0].Value, ProgramHash: Uint168{2}},
+		&tx.TxOutput{AssetID: utxoAsset.Hash(), Value: 100, ProgramHash: Uint168{2}})
+	persistTestBlock(t, store, bc, spend, newTestBalanceTransfer(balanceAsset.Hash(), 40, Uint168{1}, Uint168{2}))
+	persistTestBlock(t, store, bc, newTestTransaction(tx.TransferAsset, &payload.TransferAsset{},
+		[]*tx.UTXOTxInput{newTestInput(spend, 0)},
+		&tx.TxOutput{AssetID: ela, Value: cb1.Outputs[0].Value, ProgramHash: Uint168{3}}))
+
+	return store, bc, st
+}
+
+// reopenTestStore closes the chain store and opens its database again, as
+// a node restarted with the same data directory.
+func reopenTestStore(t *testing.T, store *ChainStore) (*ChainStore, error) {
+	hash, err := store.GetBlockHash(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis, err := store.GetBlock(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	reopened := NewChainStore(store.IStore)
+	DefaultLedger = &Ledger{Store: reopened}
+	DefaultLedger.Blockchain = NewBlockchain(0, DefaultLedger)
+	if err := reopened.InitLedgerStore(DefaultLedger); err != nil {
+		t.Fatal(err)
+	}
+	_, err = reopened.InitLedgerStoreWithGenesisBlock(genesis)
+
+	return reopened, err
+}
+
+func TestRollbackToGenesis(t *testing.T) {
+	store, bc := newTestChain(t)
+	defer store.Close()
+	blocks := extendTestChain(t, bc, 3)
+
+	notified := make(chan []*Block, 2)
+	bc.BCEvents.Subscribe(events.EventRollbackTransaction, func(v interface{}) {
+		notified <- v.([]*Block)
+	})
+
+	// The rollback subcommand asks for height 0, or for the genesis hash.
+	if err := bc.RollbackToBlock(bc.GenesisHash); err != nil {
+		t.Fatal(err)
+	}
+	if bc.BestChain.Hash.CompareTo(bc.GenesisHash) != 0 || bc.BestChain.Height != 0 {
+		t.Fatalf("best chain is at height %d, want the genesis block", bc.BestChain.Height)
+	}
+	if height := store.GetHeight(); height != 0 {
+		t.Fatalf("store height is %d, want 0", height)
+	}
+	if !store.IsBlockInStore(bc.GenesisHash) {
+		t.Fatal("genesis block is not in the store")
+	}
+	for _, block := range blocks {
+		if store.IsBlockInStore(block.Hash()) {
+			t.Fatalf("block at height %d is still in the store", block.Blockdata.Height)
+		}
+	}
+
+	// The disconnected blocks are notified once, in ascending height.
+	select {
+	case got := <-notified:
+		if len(got) != len(blocks) {
+			t.Fatalf("notified %d blocks, want %d", len(got), len(blocks))
+		}
+		for i, block := range got {
+			if block.Hash() != blocks[i].Hash() {
+				t.Fatalf("notified block %d is at height %d", i, block.Blockdata.Height)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the rollback is not notified")
+	}
+
+	// Nothing is left to roll back, the genesis block stays.
+	if err := bc.RollbackTo(0); err == nil {
+		t.Fatal("rollback below the genesis block succeeded")
+	}
+	if bc.BestChain.Hash.CompareTo(bc.GenesisHash) != 0 {
+		t.Fatal("genesis block was disconnected")
+	}
+	select {
+	case <-notified:
+		t.Fatal("a failed rollback is notified")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPersistRollbackRoundTrip(t *testing.T) {
+	addressIndex, spentIndex := config.Parameters.AddressIndex, config.Parameters.SpentIndex
+	config.Parameters.AddressIndex, config.Parameters.SpentIndex = true, true
+	defer func() {
+		config.Parameters.AddressIndex, config.Parameters.SpentIndex = addressIndex, spentIndex
+	}()
+
+	store, bc := newTestChain(t)
+	defer store.Close()
+	blocks := extendTestChain(t, bc, 2)
+	ela := DefaultLedger.Blockchain.AssetID
+	before := storeContent(t, store)
+
+	// The block spends the outputs of both coinbases, and an output of
+	// one of its own transactions. It registers a Balance asset and
+	// credits it.
+	cb1, cb2 := blocks[0].Transactions[0], blocks[1].Transactions[0]
+	spend := newTestTransaction(tx.TransferAsset, &payload.TransferAsset{},
+		[]*tx.UTXOTxInput{newTestInput(cb1, 0), newTestInput(cb2, 1)},
+		&tx.TxOutput{AssetID: ela, Value: cb1.Outputs[0].Value, ProgramHash: Uint168{1}},
+		&tx.TxOutput{AssetID: ela, Value: cb2.Outputs[1].Value, ProgramHash: Uint168{2}})
+	spendInBlock := newTestTransaction(tx.TransferAsset, &payload.TransferAsset{},
+		[]*tx.UTXOTxInput{newTestInput(spend, 0)},
+		&tx.TxOutput{AssetID: ela, Value: cb1.Outputs[0].Value, ProgramHash: Uint168{3}})
+	register := newTestRegisterAsset("balance", Balance)
+	issue := newTestTransaction(tx.IssueAsset, &payload.IssueAsset{}, nil,
+		&tx.TxOutput{AssetID: register.Hash(), Value: 100, ProgramHash: Uint168{1}})
+	block := persistTestBlock(t, store, bc, spend, spendInBlock, register, issue)
+
+	undo, err := store.GetBlockUndo(block.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSpent := []struct {
+		txid   Uint256
+		index  uint16
+		height uint32
+	}{{cb1.Hash(), 0, 1}, {cb2.Hash(), 1, 2}, {spend.Hash(), 0, 3}}
+	if len(undo.SpentOutputs) != len(wantSpent) {
+		t.Fatalf("undo data has %d spent outputs, want %d", len(undo.SpentOutputs), len(wantSpent))
+	}
+	for i, want := range wantSpent {
+		spent := undo.SpentOutputs[i]
+		if spent.ReferTxID != want.txid || spent.ReferTxOutputIndex != want.index || spent.Height != want.height {
+			t.Errorf("spent output %d is %x:%d at height %d, want %x:%d at height %d", i,
+				spent.ReferTxID, spent.ReferTxOutputIndex, spent.Height, want.txid, want.index, want.height)
+		}
+	}
+	for _, input := range spend.UTXOInputs {
+		if ok, err := store.ContainsUnspent(input.ReferTxID, input.ReferTxOutputIndex); err != nil || ok {
+			t.Errorf("spent output %x:%d is unspent", input.ReferTxID, input.ReferTxOutputIndex)
+		}
+	}
+	if balance, err := store.GetBalance(Uint168{1}, register.Hash()); err != nil || balance != 100 {
+		t.Errorf("balance is %v, %v, want 100", balance, err)
+	}
+	if issued, err := store.GetQuantityIssued(register.Hash()); err != nil || issued != 100 {
+		t.Errorf("issued amount is %v, %v, want 100", issued, err)
+	}
+	persisted := storeContent(t, store)
+
+	// The balance is debited by the next block.
+	debit := newTestTransaction(tx.TransferAsset, &payload.TransferAsset{}, nil,
+		&tx.TxOutput{AssetID: register.Hash(), Value: 60, ProgramHash: Uint168{2}})
+	debit.BalanceInputs = []*tx.BalanceTxInput{{AssetID: register.Hash(), Value: 60, ProgramHash: Uint168{1}}}
+	next := persistTestBlock(t, store, bc, debit)
+	if balance, err := store.GetBalance(Uint168{1}, register.Hash()); err != nil || balance != 40 {
+		t.Errorf("balance is %v, %v, want 40", balance, err)
+	}
+
+	// Rolling back the blocks restores the store as it was.
+	rollbackTestBlock(t, store, bc, next)
+	checkStoreContent(t, "rollback of the debit", storeContent(t, store), persisted)
+	rollbackTestBlock(t, store, bc, block)
+	checkStoreContent(t, "rollback of the block", storeContent(t, store), before)
+	if height := store.GetHeight(); height != 2 {
+		t.Errorf("store height is %d, want 2", height)
+	}
+}
+
+func TestRegisterAssetQuantityIssued(t *testing.T) {
+	store, bc := newTestChain(t)
+	defer store.Close()
+
+	// A registered asset starts at zero, whatever the outputs of its
+	// RegisterAsset transaction.
+	register := newTestRegisterAsset("registered", UTXO)
+	register.Outputs = []*tx.TxOutput{{AssetID: bc.AssetID, Value: 1, ProgramHash: Uint168{1}}}
+	persistTestBlock(t, store, bc, register)
+	if issued, err := store.GetQuantityIssued(register.Hash()); err != nil || issued != 0 {
+		t.Errorf("issued amount of the registered asset is %v, %v, want 0", issued, err)
+	}
+	before := storeContent(t, store, ST_QuantityIssued)
+
+	// The issues are counted, in the block registering the asset too.
+	registerInBlock := newTestRegisterAsset("registered in block", UTXO)
+	block := persistTestBlock(t, store, bc, registerInBlock,
+		newTestTransaction(tx.IssueAsset, &payload.IssueAsset{}, nil,
+			&tx.TxOutput{AssetID: register.Hash(), Value: 30, ProgramHash: Uint168{1}},
+			&tx.TxOutput{AssetID: registerInBlock.Hash(), Value: 50, ProgramHash: Uint168{1}}))
+	for _, want := range []struct {
+		assetID Uint256
+		issued  Fixed64
+	}{{register.Hash(), 30}, {registerInBlock.Hash(), 50}} {
+		if issued, err := store.GetQuantityIssued(want.assetID); err != nil || issued != want.issued {
+			t.Errorf("issued amount of asset %x is %v, %v, want %v", want.assetID, issued, err, want.issued)
+		}
+	}
+
+	rollbackTestBlock(t, store, bc, block)
+	checkStoreContent(t, "rollback", storeContent(t, store, ST_QuantityIssued), before)
+}
+
+func TestReorganizeJournal(t *testing.T) {
+	store, bc, st := newTestChainWithSpends(t)
+	defer store.Close()
+	tip, err := store.GetBlock(store.GetCurrentBlockHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := storeContent(t, store)
+
+	// The journal is not written before the first block is disconnected,
+	// nor by a disconnect which fails.
+	if err := store.BeginReorganize([]*Block{tip}); err != nil {
+		t.Fatal(err)
+	}
+	checkStoreContent(t, "begin of the reorganization", storeContent(t, store), before)
+	st.failAt = st.commits + 1
+	if err := store.rollback(tip); err != errInterrupted {
+		t.Fatalf("rollback: %v, want it interrupted", err)
+	}
+	checkStoreContent(t, "interrupted disconnect", storeContent(t, store), before)
+
+	// It is written with the disconnect.
+	rollbackTestBlock(t, store, bc, tip)
+	if _, err := store.Get([]byte{byte(SYS_ReorgJournal)}); err != nil {
+		t.Fatalf("journal not written with the first disconnect: %v", err)
+	}
+	if err := store.EndReorganize(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get([]byte{byte(SYS_ReorgJournal)}); err == nil {
+		t.Error("journal kept at the end of the reorganization")
+	}
+}
+
+func TestEnableAddressIndex(t *testing.T) {
+	addressIndex := config.Parameters.AddressIndex
+	defer func() { config.Parameters.AddressIndex = addressIndex }()
+
+	// A chain stored without the address index is not started with it.
+	config.Parameters.AddressIndex = false
+	store, _, _ := newTestChainWithSpends(t)
+	config.Parameters.AddressIndex = true
+	store, err := reopenTestStore(t, store)
+	if err == nil || !strings.Contains(err.Error(), "-reindex") {
+		t.Fatalf("chain without the address index started with it: %v", err)
+	}
+
+	// A full reindex builds it.
+	if err := RequestReindex(store, ReindexFull); err != nil {
+		t.Fatal(err)
+	}
+	store, err = reopenTestStore(t, store)
+	if err != nil {
+		t.Fatalf("start after the reindex: %v", err)
+	}
+	if !store.isAddressIndexed() || len(storeContent(t, store, IX_AddressHistory)) == 0 {
+		t.Fatal("address history is not built by the reindex")
+	}
+	store, err = reopenTestStore(t, store)
+	if err != nil {
+		t.Fatalf("restart with the address index: %v", err)
+	}
+
+	// A start without it leaves the history incomplete.
+	config.Parameters.AddressIndex = false
+	store, err = reopenTestStore(t, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.isAddressIndexed() {
+		t.Error("address index kept complete by a start without it")
+	}
+	config.Parameters.AddressIndex = true
+	store, err = reopenTestStore(t, store)
+	defer store.Close()
+	if err == nil {
+		t.Error("chain started without the address index started with it again")
+	}
+}
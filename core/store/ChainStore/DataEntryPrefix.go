@@ -8,18 +8,30 @@ const (
 	DATA_BlockHash   DataEntryPrefix = 0x00
 	DATA_Header      DataEntryPrefix = 0x01
 	DATA_Transaction DataEntryPrefix = 0x02
+	DATA_BlockUndo   DataEntryPrefix = 0x03
 
 	// INDEX
 	IX_HeaderHashList DataEntryPrefix = 0x80
 	IX_Unspent        DataEntryPrefix = 0x90
 	IX_Unspent_UTXO   DataEntryPrefix = 0x91
+	IX_AddressHistory DataEntryPrefix = 0x92
+	IX_SpentBy        DataEntryPrefix = 0x93
+	IX_PruneQueue     DataEntryPrefix = 0x94
+	IX_BlockSize      DataEntryPrefix = 0x95
 
 	// ASSET
-	ST_Info DataEntryPrefix = 0xc0
+	ST_Info           DataEntryPrefix = 0xc0
+	ST_QuantityIssued DataEntryPrefix = 0xc1
+	ST_Balance        DataEntryPrefix = 0xc2
+	ST_Contract       DataEntryPrefix = 0xc3
 
 	//SYSTEM
 	SYS_CurrentBlock      DataEntryPrefix = 0x40
+	SYS_ReorgJournal      DataEntryPrefix = 0x41
 	SYS_CurrentBookKeeper DataEntryPrefix = 0x42
+	SYS_AddressIndex      DataEntryPrefix = 0x43
+	SYS_Reindex           DataEntryPrefix = 0x44
+	SYS_PruneHeight       DataEntryPrefix = 0x45
 
 	//CONFIG
 	CFG_Version DataEntryPrefix = 0xf0
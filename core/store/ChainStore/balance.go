@@ -0,0 +1,140 @@
+package ChainStore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	. "Elastos.ELA/common"
+	. "Elastos.ELA/core/asset"
+	. "Elastos.ELA/core/ledger"
+	. "Elastos.ELA/core/store"
+	tx "Elastos.ELA/core/transaction"
+	"Elastos.ELA/core/transaction/payload"
+)
+
+// The assets registered with the Balance record type are not kept as
+// unspent outputs but as one balance per program hash. Their outputs credit
+// the balance of the output program hash, the balance inputs of a
+// transaction debit the balance of the input program hash.
+
+type balanceKey struct {
+	programHash Uint168
+	assetID     Uint256
+}
+
+// key: ST_Balance || program hash || asset id
+func (k balanceKey) bytes() []byte {
+	key := bytes.NewBuffer(nil)
+	key.WriteByte(byte(ST_Balance))
+	key.Write(k.programHash.ToArray())
+	key.Write(k.assetID.ToArray())
+
+	return key.Bytes()
+}
+
+// balanceAssets returns the assets of the outputs of the block which have
+// the Balance record type, the assets registered by the block included.
+func (db *ChainStore) balanceAssets(b *Block) map[Uint256]bool {
+	assets := make(map[Uint256]bool)
+	for _, txn := range b.Transactions {
+		if txn.TxType == tx.RegisterAsset {
+			regPayload := txn.Payload.(*payload.RegisterAsset)
+			assets[txn.Hash()] = regPayload.Asset.RecordType == Balance
+		}
+	}
+	for _, txn := range b.Transactions {
+		for _, output := range txn.Outputs {
+			if _, ok := assets[output.AssetID]; ok {
+				continue
+			}
+			asset, err := db.GetAsset(output.AssetID)
+			assets[output.AssetID] = err == nil && asset.RecordType == Balance
+		}
+	}
+
+	return assets
+}
+
+// blockBalances returns the change of the balances by the block.
+func (db *ChainStore) blockBalances(b *Block) map[balanceKey]Fixed64 {
+	assets := db.balanceAssets(b)
+	balances := make(map[balanceKey]Fixed64)
+	for _, txn := range b.Transactions {
+		for _, input := range txn.BalanceInputs {
+			balances[balanceKey{input.ProgramHash, input.AssetID}] -= input.Value
+		}
+		for _, output := range txn.Outputs {
+			if assets[output.AssetID] {
+				balances[balanceKey{output.ProgramHash, output.AssetID}] += output.Value
+			}
+		}
+	}
+
+	return balances
+}
+
+// GetBalance returns the balance of the program hash in a Balance record
+// type asset, 0 if it never held the asset.
+func (db *ChainStore) GetBalance(programHash Uint168, assetID Uint256) (Fixed64, error) {
+	data, err := db.Get(balanceKey{programHash, assetID}.bytes())
+	if err == ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var balance Fixed64
+	if err := balance.Deserialize(bytes.NewReader(data)); err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+// batchPutBalance writes the balance, an empty balance is deleted.
+func (db *ChainStore) batchPutBalance(k balanceKey, balance Fixed64) error {
+	if balance < 0 {
+		return errors.New(fmt.Sprintf("balance of %x in asset %x would be %v",
+			k.programHash.ToArray(), k.assetID.ToArrayReverse(), balance))
+	}
+	if balance == 0 {
+		return db.BatchDelete(k.bytes())
+	}
+
+	w := bytes.NewBuffer(nil)
+	balance.Serialize(w)
+
+	return db.BatchPut(k.bytes(), w.Bytes())
+}
+
+// key: ST_Balance || program hash || asset id
+// value: balance
+func (db *ChainStore) PersistBalances(b *Block) error {
+	for k, amount := range db.blockBalances(b) {
+		balance, err := db.GetBalance(k.programHash, k.assetID)
+		if err != nil {
+			return err
+		}
+		if err := db.batchPutBalance(k, balance+amount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *ChainStore) RollbackBalances(b *Block) error {
+	for k, amount := range db.blockBalances(b) {
+		balance, err := db.GetBalance(k.programHash, k.assetID)
+		if err != nil {
+			return err
+		}
+		if err := db.batchPutBalance(k, balance-amount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package ChainStore
+
+import (
+	"errors"
+	"testing"
+
+	. "Elastos.ELA/common"
+	. "Elastos.ELA/core/asset"
+	. "Elastos.ELA/core/ledger"
+	"Elastos.ELA/core/store/MemoryStore"
+	tx "Elastos.ELA/core/transaction"
+	"Elastos.ELA/core/transaction/payload"
+)
+
+// failingStore fails to read every key.
+type failingStore struct {
+	*MemoryStore.MemoryStore
+}
+
+var errStoreRead = errors.New("read failed")
+
+func (s failingStore) Get(key []byte) ([]byte, error) {
+	return nil, errStoreRead
+}
+
+func TestGetBalanceErrors(t *testing.T) {
+	programHash := Uint168{1}
+	assetID := Uint256{2}
+
+	store := NewChainStore(MemoryStore.NewMemoryStore())
+	defer store.Close()
+	balance, err := store.GetBalance(programHash, assetID)
+	if err != nil || balance != 0 {
+		t.Fatalf("balance of an unknown program hash is %v, %v, want 0, nil", balance, err)
+	}
+
+	store = NewChainStore(failingStore{MemoryStore.NewMemoryStore()})
+	defer store.Close()
+	if _, err := store.GetBalance(programHash, assetID); err != errStoreRead {
+		t.Fatalf("GetBalance returned %v, want the store error", err)
+	}
+}
+
+// newTestBalanceTransfer returns a transaction moving value of a Balance
+// asset from one program hash to another.
+func newTestBalanceTransfer(assetID Uint256, value Fixed64, from, to Uint168) *tx.Transaction {
+	txn := newTestTransaction(tx.TransferAsset, &payload.TransferAsset{}, nil,
+		&tx.TxOutput{AssetID: assetID, Value: value, ProgramHash: to})
+	txn.BalanceInputs = []*tx.BalanceTxInput{{AssetID: assetID, Value: value, ProgramHash: from}}
+	return txn
+}
+
+func TestBalanceRoundTrip(t *testing.T) {
+	store, bc := newTestChain(t)
+	defer store.Close()
+	before := storeContent(t, store, ST_Balance, ST_QuantityIssued)
+
+	// The Balance asset is kept as balances, the UTXO one as outputs.
+	alice, bob := Uint168{1}, Uint168{2}
+	balanceAsset := newTestRegisterAsset("balance", Balance)
+	utxoAsset := newTestRegisterAsset("utxo", UTXO)
+	issue := newTestTransaction(tx.IssueAsset, &payload.IssueAsset{}, nil,
+		&tx.TxOutput{AssetID: balanceAsset.Hash(), Value: 70, ProgramHash: alice},
+		&tx.TxOutput{AssetID: balanceAsset.Hash(), Value: 30, ProgramHash: alice},
+		&tx.TxOutput{AssetID: utxoAsset.Hash(), Value: 50, ProgramHash: alice})
+	issued := persistTestBlock(t, store, bc, balanceAsset, utxoAsset, issue)
+	transfer := persistTestBlock(t, store, bc, newTestBalanceTransfer(balanceAsset.Hash(), 100, alice, bob))
+	moved := storeContent(t, store)
+
+	tests := []struct {
+		programHash Uint168
+		assetID     Uint256
+		balance     Fixed64
+	}{
+		{alice, balanceAsset.Hash(), 0},
+		{bob, balanceAsset.Hash(), 100},
+		{alice, utxoAsset.Hash(), 0},
+	}
+	for _, test := range tests {
+		if balance, err := store.GetBalance(test.programHash, test.assetID); err != nil || balance != test.balance {
+			t.Errorf("balance of %x is %v, %v, want %v", test.programHash, balance, err, test.balance)
+		}
+	}
+	if _, err := store.Get(balanceKey{alice, balanceAsset.Hash()}.bytes()); err == nil {
+		t.Error("empty balance is stored")
+	}
+	if unspents, err := store.GetUnspentFromProgramHash(alice, utxoAsset.Hash()); err != nil || len(unspents) != 1 {
+		t.Errorf("%d unspent outputs of the UTXO asset, %v, want 1", len(unspents), err)
+	}
+	if unspents, err := store.GetUnspentFromProgramHash(bob, balanceAsset.Hash()); err != nil || len(unspents) != 0 {
+		t.Errorf("%d unspent outputs of the Balance asset, %v, want none", len(unspents), err)
+	}
+
+	// A debit beyond the balance is not persisted, and the error is
+	// returned to the caller saving the block.
+	overdraft := newTestBlock(t, bc, newTestCoinbase(t, bc.BestChain.Height+1, nil),
+		newTestBalanceTransfer(balanceAsset.Hash(), 101, bob, alice))
+	if err := store.SaveBlock(overdraft, DefaultLedger); err == nil {
+		t.Fatal("overdraft persisted")
+	}
+	checkStoreContent(t, "overdraft", storeContent(t, store), moved)
+	if height := store.GetHeight(); height != transfer.Blockdata.Height {
+		t.Errorf("store height is %d after the overdraft, want %d", height, transfer.Blockdata.Height)
+	}
+
+	rollbackTestBlock(t, store, bc, transfer)
+	if balance, err := store.GetBalance(alice, balanceAsset.Hash()); err != nil || balance != 100 {
+		t.Errorf("balance after the rollback is %v, %v, want 100", balance, err)
+	}
+	rollbackTestBlock(t, store, bc, issued)
+	checkStoreContent(t, "rollback", storeContent(t, store, ST_Balance, ST_QuantityIssued), before)
+}
@@ -0,0 +1,54 @@
+package ChainStore
+
+import (
+	"bytes"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/core/signature"
+	"Elastos.ELA/core/transaction/payload"
+)
+
+// key: ST_Contract || program hash of the code
+func contractKey(codeHash Uint168) []byte {
+	return append([]byte{byte(ST_Contract)}, codeHash.ToArray()...)
+}
+
+// key: contractKey
+// value: deploy code payload
+func (db *ChainStore) PersistContract(dc *payload.DeployCode) error {
+	codeHash, err := signature.ToProgramHash(dc.Code.Code)
+	if err != nil {
+		return err
+	}
+	w := bytes.NewBuffer(nil)
+	if err := dc.Serialize(w, payload.DeployCodePayloadVersion); err != nil {
+		return err
+	}
+
+	return db.BatchPut(contractKey(codeHash), w.Bytes())
+}
+
+func (db *ChainStore) RollbackContract(dc *payload.DeployCode) error {
+	codeHash, err := signature.ToProgramHash(dc.Code.Code)
+	if err != nil {
+		return err
+	}
+
+	return db.BatchDelete(contractKey(codeHash))
+}
+
+// GetContract returns the contract deployed with the code of the program
+// hash.
+func (db *ChainStore) GetContract(codeHash Uint168) (*payload.DeployCode, error) {
+	data, err := db.Get(contractKey(codeHash))
+	if err != nil {
+		return nil, err
+	}
+
+	dc := new(payload.DeployCode)
+	if err := dc.Deserialize(bytes.NewReader(data), payload.DeployCodePayloadVersion); err != nil {
+		return nil, err
+	}
+
+	return dc, nil
+}
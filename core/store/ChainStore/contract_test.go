@@ -0,0 +1,46 @@
+package ChainStore
+
+import (
+	"bytes"
+	"testing"
+
+	"Elastos.ELA/core/code"
+	"Elastos.ELA/core/signature"
+	tx "Elastos.ELA/core/transaction"
+	"Elastos.ELA/core/transaction/payload"
+)
+
+func TestContractRoundTrip(t *testing.T) {
+	store, bc := newTestChain(t)
+	defer store.Close()
+	before := storeContent(t, store)
+
+	// A single signature code, the address of a deployed code is the one
+	// its outputs are spent from.
+	deployed := []byte{33}
+	deployed = append(deployed, make([]byte, 33)...)
+	deployed = append(deployed, signature.STANDARD)
+	deploy := newTestTransaction(tx.Deploy, &payload.DeployCode{
+		Code: &code.FunctionCode{Code: deployed},
+		Name: "contract",
+	}, nil)
+	block := persistTestBlock(t, store, bc, deploy)
+
+	programHash, err := signature.ToProgramHash(deployed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc, err := store.GetContract(programHash)
+	if err != nil {
+		t.Fatalf("contract is not stored under the program hash of its code: %v", err)
+	}
+	if dc.Name != "contract" || !bytes.Equal(dc.Code.Code, deployed) {
+		t.Errorf("contract %q with code %x stored, want the deployed one", dc.Name, dc.Code.Code)
+	}
+
+	rollbackTestBlock(t, store, bc, block)
+	if _, err := store.GetContract(programHash); err == nil {
+		t.Error("contract is kept after the rollback")
+	}
+	checkStoreContent(t, "rollback", storeContent(t, store), before)
+}
@@ -0,0 +1,286 @@
+package ChainStore
+
+import (
+	"errors"
+	"fmt"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/log"
+	tx "Elastos.ELA/core/transaction"
+)
+
+// CurrentSchemaVersion is the version of the on-disk layout written by this
+// node, it is stored under CFG_Version. It must be bumped together with a
+// migration from the previous version on any change to the layout.
+const CurrentSchemaVersion byte = 0x05
+
+// migration upgrades the store from one schema version to the next one.
+type migration struct {
+	from        byte
+	description string
+	migrate     func(db *ChainStore) error
+}
+
+var migrations = make(map[byte]*migration)
+
+func registerMigration(from byte, description string, migrate func(db *ChainStore) error) {
+	if _, ok := migrations[from]; ok {
+		panic(fmt.Sprintf("migration from schema version %d registered twice", from))
+	}
+	migrations[from] = &migration{from: from, description: description, migrate: migrate}
+}
+
+func init() {
+	registerMigration(0x01, "write the undo data of the blocks persisted without it", migrateBlockUndo)
+	registerMigration(0x02, "write the block sizes and the prune queue of the kept blocks", migratePruneIndexes)
+	registerMigration(0x03, "write the issued amount of every asset", migrateQuantityIssued)
+	registerMigration(0x04, "index the inputs and outputs of the RegisterAsset transactions", migrateRegisterAssets)
+}
+
+// getSchemaVersion returns the schema version of the store, 0x00 for a new
+// store.
+func (bd *ChainStore) getSchemaVersion() (byte, error) {
+	version, err := bd.Get([]byte{byte(CFG_Version)})
+	if err != nil {
+		return 0x00, nil
+	}
+	if len(version) != 1 {
+		return 0, errors.New(fmt.Sprintf("invalid schema version %x", version))
+	}
+	return version[0], nil
+}
+
+func (bd *ChainStore) putSchemaVersion(version byte) error {
+	return bd.Put([]byte{byte(CFG_Version)}, []byte{version})
+}
+
+// upgradeSchema runs the migrations from version to CurrentSchemaVersion one
+// at a time, storing the version reached after each of them so an
+// interrupted upgrade resumes where it stopped.
+func (bd *ChainStore) upgradeSchema(version byte) error {
+	if version > CurrentSchemaVersion {
+		return errors.New(fmt.Sprintf("database schema version %d is newer than the supported version %d",
+			version, CurrentSchemaVersion))
+	}
+
+	for ; version < CurrentSchemaVersion; version++ {
+		m, ok := migrations[version]
+		if !ok {
+			return errors.New(fmt.Sprintf("no migration from database schema version %d, the chain has to be synchronized again",
+				version))
+		}
+
+		log.Infof("Migrating database schema from version %d to %d: %s", version, version+1, m.description)
+		if err := m.migrate(bd); err != nil {
+			return errors.New(fmt.Sprintf("migration from database schema version %d failed: %v", version, err))
+		}
+		if err := bd.putSchemaVersion(version + 1); err != nil {
+			return err
+		}
+		log.Infof("Database schema migrated to version %d", version+1)
+	}
+
+	return nil
+}
+
+// migrateBlockUndo writes the undo data of the blocks persisted before it
+// was recorded, instead of rebuilding it from the spent transactions at
+// every rollback.
+func migrateBlockUndo(db *ChainStore) error {
+	_, height, err := db.getCurrentBlock()
+	if err != nil {
+		return err
+	}
+
+	const batchBlocks = 1000
+	db.BatchInit()
+	for h := uint32(0); h <= height; h++ {
+		hash, err := db.GetBlockHash(h)
+		if err != nil {
+			return err
+		}
+		if _, err := db.Get(append([]byte{byte(DATA_BlockUndo)}, hash.ToArray()...)); err == nil {
+			continue
+		}
+		b, err := db.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+		undo, err := db.buildBlockUndo(b)
+		if err != nil {
+			return err
+		}
+		if err := db.PersistBlockUndo(b, undo); err != nil {
+			return err
+		}
+
+		if (h+1)%batchBlocks == 0 {
+			if err := db.BatchFinish(); err != nil {
+				return err
+			}
+			log.Infof("Migrated block undo data %d/%d", h+1, height+1)
+			db.BatchInit()
+		}
+	}
+
+	return db.BatchFinish()
+}
+
+// migratePruneIndexes writes IX_BlockSize and IX_PruneQueue for the kept
+// blocks persisted before they were recorded for every block. A fully spent
+// transaction is queued under the height of the block spending its last
+// output.
+func migratePruneIndexes(db *ChainStore) error {
+	if err := db.loadPruneState(); err != nil {
+		return err
+	}
+	_, height, err := db.getCurrentBlock()
+	if err != nil {
+		return err
+	}
+
+	const batchBlocks = 1000
+	spentHeights := make(map[Uint256]uint32)
+	db.BatchInit()
+	for h := db.pruneHeight; h <= height; h++ {
+		hash, err := db.GetBlockHash(h)
+		if err != nil {
+			return err
+		}
+		b, err := db.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+		if err := db.PersistBlockSize(b); err != nil {
+			return err
+		}
+		for _, txn := range b.Transactions {
+			if txn.IsCoinBaseTx() {
+				continue
+			}
+			for _, input := range txn.UTXOInputs {
+				spentHeights[input.ReferTxID] = h
+			}
+		}
+
+		if (h+1)%batchBlocks == 0 {
+			if err := db.BatchFinish(); err != nil {
+				return err
+			}
+			log.Infof("Migrated block sizes %d/%d", h+1, height+1)
+			db.BatchInit()
+		}
+	}
+
+	for txid, h := range spentHeights {
+		if _, err := db.Get(append([]byte{byte(IX_Unspent)}, txid.ToArray()...)); err == nil {
+			continue
+		}
+		if err := db.PersistPruneQueue(h, txid); err != nil {
+			return err
+		}
+	}
+
+	return db.BatchFinish()
+}
+
+// migrateQuantityIssued sums the unspent outputs of every asset, which is
+// the amount the blocks issued so far.
+func migrateQuantityIssued(db *ChainStore) error {
+	amounts := make(map[Uint256]Fixed64)
+	for assetID := range db.GetAssets() {
+		amounts[assetID] = 0
+	}
+
+	iter := db.NewIterator([]byte{byte(IX_Unspent)})
+	defer iter.Release()
+	for iter.Next() {
+		txid, err := Uint256ParseFromBytes(iter.Key()[1:])
+		if err != nil {
+			return err
+		}
+		indexes, err := GetUint16Array(iter.Value())
+		if err != nil {
+			return err
+		}
+		txn, _, err := db.GetTransaction(txid)
+		if err != nil {
+			return err
+		}
+		for _, index := range indexes {
+			if int(index) >= len(txn.Outputs) {
+				return errors.New(fmt.Sprintf("transaction %x has no output %d", txid.ToArrayReverse(), index))
+			}
+			output := txn.Outputs[index]
+			amounts[output.AssetID] += output.Value
+		}
+	}
+
+	db.BatchInit()
+	for assetID, amount := range amounts {
+		if err := db.batchPutQuantityIssued(assetID, amount); err != nil {
+			return err
+		}
+	}
+
+	return db.BatchFinish()
+}
+
+// migrateRegisterAssets requests a full reindex if a stored RegisterAsset
+// transaction has inputs or outputs. They were skipped when the unspent
+// outputs, the undo data, the address history, the issued amounts and the
+// balances were written, the reindex runs right after the upgrade and
+// rebuilds them with the current rules. The RegisterAsset transaction of
+// the genesis block has neither.
+//
+// The transactions of the pruned blocks are gone and can not be checked,
+// nor replayed, so a pruned store has to be synchronized again.
+func migrateRegisterAssets(db *ChainStore) error {
+	if err := db.loadPruneState(); err != nil {
+		return err
+	}
+	_, height, err := db.getCurrentBlock()
+	if err != nil {
+		return err
+	}
+	if db.pruneHeight > 0 {
+		return errors.New(fmt.Sprintf("blocks below height %d are pruned and can not be checked for RegisterAsset transactions, the chain has to be synchronized again",
+			db.pruneHeight))
+	}
+
+	for h := uint32(1); h <= height; h++ {
+		hash, err := db.GetBlockHash(h)
+		if err != nil {
+			return err
+		}
+		b, err := db.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+		for _, txn := range b.Transactions {
+			if txn.TxType != tx.RegisterAsset {
+				continue
+			}
+			if len(txn.UTXOInputs) == 0 && len(txn.BalanceInputs) == 0 && len(txn.Outputs) == 0 {
+				continue
+			}
+
+			txHash := txn.Hash()
+			log.Infof("RegisterAsset transaction %x at height %d has inputs or outputs, reindex the chain",
+				txHash.ToArrayReverse(), h)
+			progress, err := db.getReindexProgress()
+			if err != nil {
+				return err
+			}
+			if progress != nil && progress.mode == ReindexFull {
+				// the full reindex in progress resumes with the current rules
+				return nil
+			}
+			db.BatchInit()
+			db.batchPutReindexProgress(&reindexProgress{mode: ReindexFull, tipHeight: height})
+			return db.BatchFinish()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package ChainStore
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	. "Elastos.ELA/common"
+	. "Elastos.ELA/core/asset"
+	tx "Elastos.ELA/core/transaction"
+	"Elastos.ELA/core/transaction/payload"
+)
+
+// newTestChainForMigration returns a chain of 3 blocks spending outputs and
+// issuing a UTXO asset, the layout every schema version can hold.
+func newTestChainForMigration(t *testing.T) *ChainStore {
+	store, bc := newTestChain(t)
+	ela := bc.AssetID
+
+	cb1 := persistTestBlock(t, store, bc).Transactions[0]
+	register := newTestRegisterAsset("utxo", UTXO)
+	issue := newTestTransaction(tx.IssueAsset, &payload.IssueAsset{}, nil,
+		&tx.TxOutput{AssetID: register.Hash(), Value: 100, ProgramHash: Uint168{1}})
+	spend := newTestTransaction(tx.TransferAsset, &payload.TransferAsset{},
+		[]*tx.UTXOTxInput{newTestInput(cb1, 0), newTestInput(cb1, 1)},
+		&tx.TxOutput{AssetID: ela, Value: cb1.Outputs[0].Value + cb1.Outputs[1].Value, ProgramHash: Uint168{2}})
+	persistTestBlock(t, store, bc, register, issue, spend)
+	persistTestBlock(t, store, bc, newTestTransaction(tx.TransferAsset, &payload.TransferAsset{},
+		[]*tx.UTXOTxInput{newTestInput(issue, 0)},
+		&tx.TxOutput{AssetID: register.Hash(), Value: 100, ProgramHash: Uint168{3}}))
+
+	return store
+}
+
+// setSchemaVersion turns the store back into one of an older schema
+// version, without the entries written from that version on.
+func setSchemaVersion(t *testing.T, store *ChainStore, version byte, removed ...DataEntryPrefix) {
+	store.BatchInit()
+	for _, prefix := range removed {
+		iter := store.NewIterator([]byte{byte(prefix)})
+		for iter.Next() {
+			store.BatchDelete(iter.Key())
+		}
+		iter.Release()
+	}
+	if err := store.BatchFinish(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.putSchemaVersion(version); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	tests := []struct {
+		version byte
+		removed []DataEntryPrefix
+	}{
+		{0x01, []DataEntryPrefix{DATA_BlockUndo}},
+		{0x02, []DataEntryPrefix{IX_BlockSize, IX_PruneQueue}},
+		{0x03, []DataEntryPrefix{ST_QuantityIssued}},
+		{0x04, nil},
+		{CurrentSchemaVersion, nil},
+	}
+	for _, test := range tests {
+		store := newTestChainForMigration(t)
+		want := storeContent(t, store)
+
+		setSchemaVersion(t, store, test.version, test.removed...)
+		store, err := reopenTestStore(t, store)
+		if err != nil {
+			t.Fatalf("migration from schema version %d: %v", test.version, err)
+		}
+		name := fmt.Sprintf("migration from schema version %d", test.version)
+		checkStoreContent(t, name, storeContent(t, store), want)
+		if version, err := store.getSchemaVersion(); err != nil || version != CurrentSchemaVersion {
+			t.Errorf("schema version is %d, %v after the migration from %d", version, err, test.version)
+		}
+		store.Close()
+	}
+}
+
+func TestMigrateRegisterAssets(t *testing.T) {
+	store, bc := newTestChain(t)
+	register := newTestRegisterAsset("utxo", UTXO)
+	register.Outputs = []*tx.TxOutput{{AssetID: bc.AssetID, Value: 1, ProgramHash: Uint168{1}}}
+	persistTestBlock(t, store, bc, register)
+	persistTestBlock(t, store, bc)
+	want := storeContent(t, store)
+
+	// The outputs of a RegisterAsset transaction were not indexed before
+	// schema version 5, the migration reindexes the chain.
+	registerHash := register.Hash()
+	store.BatchInit()
+	store.BatchDelete(append([]byte{byte(IX_Unspent)}, registerHash.ToArray()...))
+	if err := store.BatchFinish(); err != nil {
+		t.Fatal(err)
+	}
+	setSchemaVersion(t, store, 0x04)
+	store, err := reopenTestStore(t, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStoreContent(t, "migration", storeContent(t, store), want)
+
+	// A pruned store can not be checked.
+	setSchemaVersion(t, store, 0x04)
+	store.BatchInit()
+	store.BatchPut([]byte{byte(SYS_PruneHeight)}, []byte{1, 0, 0, 0})
+	if err := store.BatchFinish(); err != nil {
+		t.Fatal(err)
+	}
+	store, err = reopenTestStore(t, store)
+	defer store.Close()
+	if err == nil || !strings.Contains(err.Error(), "synchronized again") {
+		t.Fatalf("migration of a pruned store: %v", err)
+	}
+	if version, _ := store.getSchemaVersion(); version != 0x04 {
+		t.Errorf("schema version is %d after a failed migration, want 4", version)
+	}
+}
+
+func TestUpgradeSchemaErrors(t *testing.T) {
+	store, _ := newTestChain(t)
+	defer store.Close()
+
+	if err := store.upgradeSchema(CurrentSchemaVersion + 1); err == nil {
+		t.Error("store of a newer schema version upgraded")
+	}
+	if err := store.upgradeSchema(0x00); err == nil {
+		t.Error("store without schema version upgraded")
+	}
+	for version := byte(0x01); version < CurrentSchemaVersion; version++ {
+		if _, ok := migrations[version]; !ok {
+			t.Errorf("no migration from schema version %d", version)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package ChainStore
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/config"
+	"Elastos.ELA/common/log"
+	"Elastos.ELA/common/serialization"
+	. "Elastos.ELA/core/ledger"
+)
+
+// A pruned node discards the transactions of the blocks below its prune
+// height, together with their undo data. The headers and the transactions
+// with unspent outputs are kept, so it still validates new blocks.
+//
+// A transaction is only discarded once the block spending its last output
+// is pruned too, so every block which can still be rolled back finds the
+// transactions it spent. The transactions fully spent by a block are kept
+// in IX_PruneQueue under the height of that block until then, whether
+// pruning is enabled or not, so it can be enabled on an existing node.
+
+func pruneEnabled() bool {
+	return config.Parameters.PruneBlocks > 0 || config.Parameters.PruneSizeMB > 0
+}
+
+func heightKey(prefix DataEntryPrefix, height uint32) []byte {
+	key := []byte{byte(prefix), 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(key[1:], height)
+	return key
+}
+
+// GetPruneHeight returns the lowest height of the blocks with their
+// transactions, 0 on a node which never pruned.
+func (bd *ChainStore) GetPruneHeight() uint32 {
+	bd.mu.RLock()
+	defer bd.mu.RUnlock()
+
+	return bd.pruneHeight
+}
+
+// loadPruneState reads the prune height and sums the size of the blocks
+// kept above it.
+func (bd *ChainStore) loadPruneState() error {
+	data, err := bd.Get([]byte{byte(SYS_PruneHeight)})
+	if err == nil {
+		height, err := serialization.ReadUint32(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		bd.pruneHeight = height
+	}
+
+	bd.keptSize = 0
+	iter := bd.NewIterator([]byte{byte(IX_BlockSize)})
+	defer iter.Release()
+	for iter.Next() {
+		if len(iter.Value()) == 4 {
+			bd.keptSize += uint64(binary.LittleEndian.Uint32(iter.Value()))
+		}
+	}
+
+	return nil
+}
+
+// blockSize returns the size of the transactions of the block, the data
+// discarded when it is pruned.
+func blockSize(b *Block) uint64 {
+	size := 0
+	for _, txn := range b.Transactions {
+		if s := txn.GetSize(); s > 0 {
+			size += s
+		}
+	}
+	return uint64(size)
+}
+
+// key: IX_BlockSize || height
+// value: size of the transactions of the block
+func (db *ChainStore) PersistBlockSize(b *Block) error {
+	value := make([]byte, 4)
+	binary.LittleEndian.PutUint32(value, uint32(blockSize(b)))
+
+	return db.BatchPut(heightKey(IX_BlockSize, b.Blockdata.Height), value)
+}
+
+func (db *ChainStore) RollbackBlockSize(b *Block) error {
+	return db.BatchDelete(heightKey(IX_BlockSize, b.Blockdata.Height))
+}
+
+func (db *ChainStore) getBlockSize(height uint32) uint64 {
+	value, err := db.Get(heightKey(IX_BlockSize, height))
+	if err != nil || len(value) != 4 {
+		return 0
+	}
+	return uint64(binary.LittleEndian.Uint32(value))
+}
+
+// key: IX_PruneQueue || height || txid
+// value: none
+func (db *ChainStore) PersistPruneQueue(height uint32, txid Uint256) error {
+	return db.BatchPut(append(heightKey(IX_PruneQueue, height), txid.ToArray()...), []byte{})
+}
+
+func (db *ChainStore) RollbackPruneQueue(height uint32, txid Uint256) error {
+	return db.BatchDelete(append(heightKey(IX_PruneQueue, height), txid.ToArray()...))
+}
+
+// prune discards blocks from the prune height on while more blocks than
+// PruneBlocks, or more than PruneSizeMB of transactions, are kept.
+func (bd *ChainStore) prune() {
+	if !pruneEnabled() {
+		return
+	}
+
+	for {
+		bd.mu.RLock()
+		height, kept, tip := bd.pruneHeight, bd.keptSize, bd.currentBlockHeight
+		bd.mu.RUnlock()
+
+		if tip < height || tip-height < config.MinPruneBlocks {
+			return
+		}
+		byBlocks := config.Parameters.PruneBlocks > 0 && tip-height >= config.Parameters.PruneBlocks
+		bySize := config.Parameters.PruneSizeMB > 0 && kept > uint64(config.Parameters.PruneSizeMB)<<20
+		if !byBlocks && !bySize {
+			return
+		}
+
+		if err := bd.pruneBlock(height); err != nil {
+			log.Errorf("prune block at height %d failed: %v", height, err)
+			return
+		}
+	}
+}
+
+// pruneBlock discards the undo data of the block at height and the
+// transactions fully spent by it.
+func (bd *ChainStore) pruneBlock(height uint32) error {
+	hash, err := bd.GetBlockHash(height)
+	if err != nil {
+		return err
+	}
+	size := bd.getBlockSize(height)
+
+	bd.BatchInit()
+	queue := heightKey(IX_PruneQueue, height)
+	iter := bd.NewIterator(queue)
+	for iter.Next() {
+		txid := iter.Key()[len(queue):]
+		bd.BatchDelete(append([]byte{byte(DATA_Transaction)}, txid...))
+		bd.BatchDelete(iter.Key())
+	}
+	iter.Release()
+	bd.BatchDelete(append([]byte{byte(DATA_BlockUndo)}, hash.ToArray()...))
+	bd.BatchDelete(heightKey(IX_BlockSize, height))
+
+	value := bytes.NewBuffer(nil)
+	serialization.WriteUint32(value, height+1)
+	bd.BatchPut([]byte{byte(SYS_PruneHeight)}, value.Bytes())
+	if err := bd.BatchFinish(); err != nil {
+		return err
+	}
+
+	bd.mu.Lock()
+	bd.pruneHeight = height + 1
+	if bd.keptSize >= size {
+		bd.keptSize -= size
+	} else {
+		bd.keptSize = 0
+	}
+	bd.mu.Unlock()
+
+	log.Debugf("Pruned block at height %d", height)
+	return nil
+}
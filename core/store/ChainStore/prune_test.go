@@ -0,0 +1,120 @@
+package ChainStore
+
+import (
+	"testing"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/config"
+	tx "Elastos.ELA/core/transaction"
+	"Elastos.ELA/core/transaction/payload"
+)
+
+func TestPruneRollback(t *testing.T) {
+	store, bc := newTestChain(t)
+	defer store.Close()
+	ela := bc.AssetID
+
+	// The coinbase of block 1 is fully spent by block 2, the transaction
+	// spending it by block 3.
+	block1 := persistTestBlock(t, store, bc)
+	cb1 := block1.Transactions[0]
+	spend := newTestTransaction(tx.TransferAsset, &payload.TransferAsset{},
+		[]*tx.UTXOTxInput{newTestInput(cb1, 0), newTestInput(cb1, 1)},
+		&tx.TxOutput{AssetID: ela, Value: cb1.Outputs[0].Value + cb1.Outputs[1].Value, ProgramHash: Uint168{1}})
+	block2 := persistTestBlock(t, store, bc, spend)
+	unspents := storeContent(t, store, IX_Unspent, IX_Unspent_UTXO)
+	block3 := persistTestBlock(t, store, bc, newTestTransaction(tx.TransferAsset, &payload.TransferAsset{},
+		[]*tx.UTXOTxInput{newTestInput(spend, 0)},
+		&tx.TxOutput{AssetID: ela, Value: spend.Outputs[0].Value, ProgramHash: Uint168{2}}))
+	block4 := persistTestBlock(t, store, bc)
+
+	queue := storeContent(t, store, IX_PruneQueue)
+	for _, want := range []struct {
+		height uint32
+		txid   Uint256
+	}{{2, cb1.Hash()}, {3, spend.Hash()}} {
+		if _, ok := queue[string(append(heightKey(IX_PruneQueue, want.height), want.txid.ToArray()...))]; !ok {
+			t.Errorf("transaction %x is not queued at height %d", want.txid, want.height)
+		}
+	}
+	if len(queue) != 2 {
+		t.Errorf("%d transactions queued, want 2", len(queue))
+	}
+
+	for h := uint32(0); h <= 2; h++ {
+		if err := store.pruneBlock(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if height := store.GetPruneHeight(); height != 3 {
+		t.Fatalf("prune height is %d, want 3", height)
+	}
+	if _, _, err := store.GetTransaction(cb1.Hash()); err == nil {
+		t.Error("transaction spent by a pruned block is kept")
+	}
+	if _, _, err := store.GetTransaction(spend.Hash()); err != nil {
+		t.Errorf("transaction spent by a kept block is pruned: %v", err)
+	}
+	if _, _, err := store.GetTransaction(block2.Transactions[0].Hash()); err != nil {
+		t.Errorf("transaction with unspent outputs is pruned: %v", err)
+	}
+	if sizes := storeContent(t, store, IX_BlockSize); len(sizes) != 2 {
+		t.Errorf("%d block sizes kept, want 2", len(sizes))
+	}
+	if size := blockSize(block3) + blockSize(block4); store.keptSize != size {
+		t.Errorf("kept size is %d, want %d", store.keptSize, size)
+	}
+
+	// The kept blocks are rolled back as if nothing was pruned.
+	rollbackTestBlock(t, store, bc, block4)
+	rollbackTestBlock(t, store, bc, block3)
+	checkStoreContent(t, "rollback", storeContent(t, store, IX_Unspent, IX_Unspent_UTXO), unspents)
+	if queue := storeContent(t, store, IX_PruneQueue); len(queue) != 0 {
+		t.Errorf("%d transactions queued after the rollback, want none", len(queue))
+	}
+	if size := store.keptSize; size != 0 {
+		t.Errorf("kept size is %d after the rollback, want 0", size)
+	}
+
+	// The pruned blocks are not.
+	before := storeContent(t, store)
+	if err := store.rollback(block2); err == nil {
+		t.Error("pruned block rolled back")
+	}
+	checkStoreContent(t, "rollback of a pruned block", storeContent(t, store), before)
+}
+
+func TestPruneBlocks(t *testing.T) {
+	pruneBlocks := config.Parameters.PruneBlocks
+	config.Parameters.PruneBlocks = config.MinPruneBlocks
+	defer func() { config.Parameters.PruneBlocks = pruneBlocks }()
+
+	store, bc := newTestChain(t)
+	defer store.Close()
+
+	const tip = config.MinPruneBlocks + 12
+	for h := 1; h <= tip; h++ {
+		persistTestBlock(t, store, bc)
+	}
+	store.prune()
+
+	// PruneBlocks blocks are kept below the tip
+	if height := store.GetPruneHeight(); height != tip-config.MinPruneBlocks+1 {
+		t.Fatalf("prune height is %d, want %d", height, tip-config.MinPruneBlocks+1)
+	}
+	if sizes := storeContent(t, store, IX_BlockSize); len(sizes) != config.MinPruneBlocks {
+		t.Errorf("%d block sizes kept, want %d", len(sizes), config.MinPruneBlocks)
+	}
+	if undos := storeContent(t, store, DATA_BlockUndo); len(undos) != config.MinPruneBlocks {
+		t.Errorf("undo data of %d blocks kept, want %d", len(undos), config.MinPruneBlocks)
+	}
+
+	// The prune height is read back at the next start.
+	store.pruneHeight = 0
+	if err := store.loadPruneState(); err != nil {
+		t.Fatal(err)
+	}
+	if height := store.GetPruneHeight(); height != tip-config.MinPruneBlocks+1 {
+		t.Errorf("prune height is %d after a restart, want %d", height, tip-config.MinPruneBlocks+1)
+	}
+}
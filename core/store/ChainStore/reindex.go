@@ -0,0 +1,204 @@
+package ChainStore
+
+import (
+	"bytes"
+	"errors"
+
+	"Elastos.ELA/common/config"
+	"Elastos.ELA/common/log"
+	"Elastos.ELA/common/serialization"
+	. "Elastos.ELA/core/ledger"
+)
+
+type ReindexMode byte
+
+const (
+	// ReindexFull rebuilds every index and the asset records by replaying
+	// the stored blocks through persist.
+	ReindexFull ReindexMode = iota + 1
+	// ReindexChainState only rebuilds the UTXO set from the stored blocks.
+	ReindexChainState
+)
+
+func (mode ReindexMode) String() string {
+	if mode == ReindexChainState {
+		return "chain state"
+	}
+	return "full"
+}
+
+// reindexPrefixes are the entries derived from the stored blocks which are
+// removed before the blocks are replayed.
+var reindexPrefixes = map[ReindexMode][]DataEntryPrefix{
+	ReindexFull:       {DATA_BlockUndo, IX_Unspent, IX_Unspent_UTXO, IX_AddressHistory, IX_SpentBy, ST_Info, ST_QuantityIssued, ST_Balance, ST_Contract},
+	ReindexChainState: {IX_Unspent, IX_Unspent_UTXO, ST_QuantityIssued, ST_Balance},
+}
+
+// reindexProgress is stored under SYS_Reindex while a reindex runs, so an
+// interrupted reindex resumes from the next block at the next start.
+type reindexProgress struct {
+	mode       ReindexMode
+	nextHeight uint32
+	tipHeight  uint32
+}
+
+func (bd *ChainStore) getReindexProgress() (*reindexProgress, error) {
+	data, err := bd.Get([]byte{byte(SYS_Reindex)})
+	if err != nil {
+		return nil, nil
+	}
+
+	r := bytes.NewReader(data)
+	mode, err := serialization.ReadUint8(r)
+	if err != nil {
+		return nil, err
+	}
+	progress := &reindexProgress{mode: ReindexMode(mode)}
+	if progress.nextHeight, err = serialization.ReadUint32(r); err != nil {
+		return nil, err
+	}
+	if progress.tipHeight, err = serialization.ReadUint32(r); err != nil {
+		return nil, err
+	}
+
+	return progress, nil
+}
+
+func (bd *ChainStore) batchPutReindexProgress(progress *reindexProgress) error {
+	value := bytes.NewBuffer(nil)
+	serialization.WriteUint8(value, uint8(progress.mode))
+	serialization.WriteUint32(value, progress.nextHeight)
+	serialization.WriteUint32(value, progress.tipHeight)
+
+	return bd.BatchPut([]byte{byte(SYS_Reindex)}, value.Bytes())
+}
+
+// RequestReindex makes the store replay its blocks when the chain is
+// initialized. A reindex already in progress is resumed instead.
+func RequestReindex(store ILedgerStore, mode ReindexMode) error {
+	bd, ok := store.(*ChainStore)
+	if !ok {
+		return errors.New("reindex is not supported by the ledger store")
+	}
+	if progress, err := bd.getReindexProgress(); err != nil || progress != nil {
+		return err
+	}
+	if _, err := bd.Get([]byte{byte(SYS_PruneHeight)}); err == nil {
+		return errors.New("reindex needs the transactions of every block, a pruned node does not have them")
+	}
+	_, height, err := bd.getCurrentBlock()
+	if err != nil {
+		// nothing has been stored yet
+		return nil
+	}
+
+	if err := bd.BatchInit(); err != nil {
+		return err
+	}
+	if err := bd.batchPutReindexProgress(&reindexProgress{mode: mode, tipHeight: height}); err != nil {
+		return err
+	}
+	return bd.BatchFinish()
+}
+
+// reindex runs the requested or interrupted reindex, if any.
+func (bd *ChainStore) reindex() error {
+	progress, err := bd.getReindexProgress()
+	if err != nil || progress == nil {
+		return err
+	}
+	prefixes, ok := reindexPrefixes[progress.mode]
+	if !ok {
+		return errors.New("unknown reindex mode")
+	}
+
+	if progress.nextHeight == 0 {
+		log.Infof("Start %v reindex of %d blocks", progress.mode, progress.tipHeight+1)
+		bd.BatchInit()
+		for _, prefix := range prefixes {
+			iter := bd.NewIterator([]byte{byte(prefix)})
+			for iter.Next() {
+				bd.BatchDelete(iter.Key())
+			}
+			iter.Release()
+		}
+		if progress.mode == ReindexFull {
+			if err := bd.batchPutAddressIndexed(config.Parameters.AddressIndex); err != nil {
+				return err
+			}
+		}
+		if err := bd.BatchFinish(); err != nil {
+			return err
+		}
+	} else {
+		log.Infof("Resume %v reindex at block %d of %d", progress.mode, progress.nextHeight, progress.tipHeight+1)
+	}
+
+	for ; progress.nextHeight <= progress.tipHeight; progress.nextHeight++ {
+		hash, err := bd.GetBlockHash(progress.nextHeight)
+		if err != nil {
+			return err
+		}
+		b, err := bd.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+
+		var undo *BlockUndo
+		if progress.mode == ReindexFull {
+			undo, err = bd.buildBlockUndo(b)
+		} else {
+			undo, err = bd.GetBlockUndo(hash)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := bd.BatchInit(); err != nil {
+			return err
+		}
+		if progress.mode == ReindexFull {
+			err = bd.batchPersist(b, undo)
+		} else {
+			err = bd.batchPersistChainState(b, undo)
+		}
+		if err != nil {
+			return err
+		}
+		next := *progress
+		next.nextHeight++
+		if err := bd.batchPutReindexProgress(&next); err != nil {
+			return err
+		}
+		if err := bd.BatchFinish(); err != nil {
+			return err
+		}
+
+		if next.nextHeight%1000 == 0 {
+			log.Infof("Reindexed %d/%d blocks", next.nextHeight, progress.tipHeight+1)
+		}
+	}
+
+	if err := bd.Delete([]byte{byte(SYS_Reindex)}); err != nil {
+		return err
+	}
+	log.Infof("Finished %v reindex of %d blocks", progress.mode, progress.tipHeight+1)
+
+	return nil
+}
+
+// batchPersistChainState adds the unspent outputs, the issued amounts and
+// the balances of the block to the current batch.
+func (bd *ChainStore) batchPersistChainState(b *Block, undo *BlockUndo) error {
+	if err := bd.PersistUnspendUTXOs(b, undo); err != nil {
+		return err
+	}
+	if err := bd.PersistUnspend(b); err != nil {
+		return err
+	}
+	if err := bd.PersistQuantityIssued(b, undo); err != nil {
+		return err
+	}
+
+	return bd.PersistBalances(b)
+}
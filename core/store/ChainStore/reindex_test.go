@@ -0,0 +1,67 @@
+package ChainStore
+
+import (
+	"testing"
+)
+
+func TestReindexResume(t *testing.T) {
+	for _, mode := range []ReindexMode{ReindexFull, ReindexChainState} {
+		store, _, st := newTestChainWithSpends(t)
+		want := storeContent(t, store)
+
+		if err := RequestReindex(store, mode); err != nil {
+			t.Fatal(err)
+		}
+		progress, err := store.getReindexProgress()
+		if err != nil || progress == nil || progress.mode != mode || progress.nextHeight != 0 || progress.tipHeight != 4 {
+			t.Fatalf("%v reindex: progress %+v, %v after the request", mode, progress, err)
+		}
+
+		// The first batch removes the derived entries, the next ones
+		// replay the blocks from the genesis block. The replay of block 2
+		// is interrupted.
+		st.failAt = st.commits + 4
+		if err := store.reindex(); err != errInterrupted {
+			t.Fatalf("%v reindex: %v, want it interrupted", mode, err)
+		}
+		progress, err = store.getReindexProgress()
+		if err != nil || progress == nil || progress.nextHeight != 2 {
+			t.Fatalf("%v reindex: progress %+v, %v after the interruption, want block 2 next", mode, progress, err)
+		}
+
+		// A request does not restart the reindex in progress.
+		other := ReindexChainState
+		if mode == ReindexChainState {
+			other = ReindexFull
+		}
+		if err := RequestReindex(store, other); err != nil {
+			t.Fatal(err)
+		}
+		if progress, _ := store.getReindexProgress(); progress.mode != mode || progress.nextHeight != 2 {
+			t.Fatalf("%v reindex: progress %+v after another request", mode, progress)
+		}
+
+		if err := store.reindex(); err != nil {
+			t.Fatalf("%v reindex: %v", mode, err)
+		}
+		checkStoreContent(t, mode.String()+" reindex", storeContent(t, store), want)
+		store.Close()
+	}
+}
+
+func TestReindexPrunedStore(t *testing.T) {
+	store, _, _ := newTestChainWithSpends(t)
+	defer store.Close()
+
+	store.BatchInit()
+	store.BatchPut([]byte{byte(SYS_PruneHeight)}, []byte{1, 0, 0, 0})
+	if err := store.BatchFinish(); err != nil {
+		t.Fatal(err)
+	}
+	if err := RequestReindex(store, ReindexChainState); err == nil {
+		t.Fatal("reindex of a pruned store requested")
+	}
+	if progress, _ := store.getReindexProgress(); progress != nil {
+		t.Fatalf("progress %+v stored", progress)
+	}
+}
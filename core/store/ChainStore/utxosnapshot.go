@@ -0,0 +1,489 @@
+package ChainStore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/config"
+	"Elastos.ELA/common/log"
+	"Elastos.ELA/common/serialization"
+	. "Elastos.ELA/core/asset"
+	. "Elastos.ELA/core/ledger"
+	tx "Elastos.ELA/core/transaction"
+)
+
+// A UTXO snapshot file holds, in this order:
+// header: "ELAUTXO" || snapshot version || network magic || height || block hash
+// blocks: block hash || DATA_Header value, for every height
+// assets: 0x01 || asset id || asset, for every asset, then 0x00
+// utxos: 0x01 || txid || DATA_Transaction value || unspent output indexes,
+// for every transaction with unspent outputs, then 0x00
+// balances: 0x01 || program hash || asset id || balance, for every balance,
+// then 0x00, since version 2
+// contracts: 0x01 || program hash || deploy code payload, for every
+// contract, then 0x00, since version 3
+// trailer: sha256 of all the sections above
+// The blocks are stored without their transactions, a node bootstrapped
+// from a snapshot is pruned up to the height of the snapshot.
+const (
+	utxoSnapshotMagic   = "ELAUTXO"
+	UTXOSnapshotVersion = 3
+)
+
+type dumpUTXOSetTask struct {
+	w     io.Writer
+	info  *UTXOSetInfo
+	reply chan error
+}
+
+// GetUTXOSetInfo returns the description of the UTXO set at the current
+// block.
+func (bd *ChainStore) GetUTXOSetInfo() (*UTXOSetInfo, error) {
+	info := new(UTXOSetInfo)
+	reply := make(chan error)
+	bd.taskCh <- &dumpUTXOSetTask{w: ioutil.Discard, info: info, reply: reply}
+	if err := <-reply; err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// DumpUTXOSet writes a snapshot of the UTXO set at the current block to
+// the file at path.
+func (bd *ChainStore) DumpUTXOSet(path string) (*UTXOSetInfo, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	info := new(UTXOSetInfo)
+	reply := make(chan error)
+	bd.taskCh <- &dumpUTXOSetTask{w: w, info: info, reply: reply}
+	if err := <-reply; err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	return info, file.Sync()
+}
+
+// handleDumpUTXOSetTask runs on the loop goroutine, so no block is persisted
+// while the snapshot is written.
+func (bd *ChainStore) handleDumpUTXOSetTask(w io.Writer, info *UTXOSetInfo) error {
+	blockHash, height, err := bd.getCurrentBlock()
+	if err != nil {
+		return err
+	}
+	info.Height = height
+	info.BlockHash = blockHash
+	info.Amounts = make(map[Uint256]Fixed64)
+
+	hasher := sha256.New()
+	hw := io.MultiWriter(w, hasher)
+
+	// header
+	hw.Write([]byte(utxoSnapshotMagic))
+	serialization.WriteUint32(hw, UTXOSnapshotVersion)
+	serialization.WriteUint32(hw, config.Parameters.Magic)
+	serialization.WriteUint32(hw, height)
+	blockHash.Serialize(hw)
+
+	// blocks
+	for h := uint32(0); h <= height; h++ {
+		hash, err := bd.GetBlockHash(h)
+		if err != nil {
+			return err
+		}
+		data, err := bd.Get(append([]byte{byte(DATA_Header)}, hash.ToArray()...))
+		if err != nil {
+			return err
+		}
+		hash.Serialize(hw)
+		serialization.WriteVarBytes(hw, data)
+	}
+
+	// assets
+	iter := bd.NewIterator([]byte{byte(ST_Info)})
+	for iter.Next() {
+		serialization.WriteUint8(hw, 0x01)
+		hw.Write(iter.Key()[1:])
+		hw.Write(iter.Value())
+	}
+	iter.Release()
+	serialization.WriteUint8(hw, 0x00)
+
+	// utxos
+	iter = bd.NewIterator([]byte{byte(IX_Unspent)})
+	for iter.Next() {
+		var txid Uint256
+		if err := txid.Deserialize(bytes.NewReader(iter.Key()[1:])); err != nil {
+			iter.Release()
+			return err
+		}
+		indexes, err := GetUint16Array(iter.Value())
+		if err != nil {
+			iter.Release()
+			return err
+		}
+		data, err := bd.Get(append([]byte{byte(DATA_Transaction)}, txid.ToArray()...))
+		if err != nil {
+			iter.Release()
+			return err
+		}
+		txn := new(tx.Transaction)
+		if err := txn.Deserialize(bytes.NewReader(data[4:])); err != nil {
+			iter.Release()
+			return err
+		}
+
+		sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+		for _, index := range indexes {
+			if int(index) >= len(txn.Outputs) {
+				iter.Release()
+				return errors.New(fmt.Sprintf("transaction %x has no output %d", txid.ToArrayReverse(), index))
+			}
+			output := txn.Outputs[index]
+			info.Amounts[output.AssetID] += output.Value
+		}
+		info.Transactions++
+		info.TxOuts += uint64(len(indexes))
+
+		serialization.WriteUint8(hw, 0x01)
+		txid.Serialize(hw)
+		serialization.WriteVarBytes(hw, data)
+		serialization.WriteVarBytes(hw, ToByteArray(indexes))
+	}
+	iter.Release()
+	serialization.WriteUint8(hw, 0x00)
+
+	// balances
+	iter = bd.NewIterator([]byte{byte(ST_Balance)})
+	for iter.Next() {
+		var balance Fixed64
+		if err := balance.Deserialize(bytes.NewReader(iter.Value())); err != nil {
+			iter.Release()
+			return err
+		}
+		assetID, err := Uint256ParseFromBytes(iter.Key()[1+UINT168SIZE:])
+		if err != nil {
+			iter.Release()
+			return err
+		}
+		info.Amounts[assetID] += balance
+		info.Balances++
+
+		serialization.WriteUint8(hw, 0x01)
+		hw.Write(iter.Key()[1:])
+		balance.Serialize(hw)
+	}
+	iter.Release()
+	serialization.WriteUint8(hw, 0x00)
+
+	// contracts
+	iter = bd.NewIterator([]byte{byte(ST_Contract)})
+	for iter.Next() {
+		serialization.WriteUint8(hw, 0x01)
+		hw.Write(iter.Key()[1:])
+		serialization.WriteVarBytes(hw, iter.Value())
+	}
+	iter.Release()
+	serialization.WriteUint8(hw, 0x00)
+
+	info.Hash, err = Uint256ParseFromBytes(hasher.Sum(nil))
+	if err != nil {
+		return err
+	}
+	_, err = info.Hash.Serialize(w)
+
+	return err
+}
+
+// deserializeHeaderData parses a DATA_Header value, the system fee
+// followed by the header.
+func deserializeHeaderData(data []byte) (*Header, error) {
+	r := bytes.NewReader(data)
+	if _, err := serialization.ReadUint64(r); err != nil {
+		return nil, err
+	}
+	header := new(Header)
+	if err := header.Deserialize(r); err != nil {
+		return nil, err
+	}
+
+	return header, nil
+}
+
+// LoadUTXOSnapshot fills an empty store with the UTXO set and the blocks
+// of a snapshot, the node then synchronizes from the block after it.
+func LoadUTXOSnapshot(store ILedgerStore, path string) error {
+	bd, ok := store.(*ChainStore)
+	if !ok {
+		return errors.New("UTXO snapshots are not supported by the ledger store")
+	}
+	if _, _, err := bd.getCurrentBlock(); err == nil {
+		return errors.New("a UTXO snapshot can only be loaded into an empty store")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+	hasher := sha256.New()
+	hr := io.TeeReader(r, hasher)
+
+	// header
+	magic, err := serialization.ReadBytes(hr, uint64(len(utxoSnapshotMagic)))
+	if err != nil || string(magic) != utxoSnapshotMagic {
+		return errors.New("not a UTXO snapshot file")
+	}
+	version, err := serialization.ReadUint32(hr)
+	if err != nil {
+		return err
+	}
+	if version < 1 || version > UTXOSnapshotVersion {
+		return errors.New(fmt.Sprintf("unsupported UTXO snapshot version %d", version))
+	}
+	netMagic, err := serialization.ReadUint32(hr)
+	if err != nil {
+		return err
+	}
+	if netMagic != config.Parameters.Magic {
+		return errors.New(fmt.Sprintf("UTXO snapshot of network %d, expect %d", netMagic, config.Parameters.Magic))
+	}
+	height, err := serialization.ReadUint32(hr)
+	if err != nil {
+		return err
+	}
+	var blockHash Uint256
+	if err := blockHash.Deserialize(hr); err != nil {
+		return err
+	}
+	log.Infof("Load UTXO snapshot at height %d, block %x", height, blockHash.ToArrayReverse())
+
+	bd.BatchInit()
+
+	// blocks, every header has to link to the previous one up to the
+	// block of the snapshot
+	var prevHash Uint256
+	for h := uint32(0); h <= height; h++ {
+		var hash Uint256
+		if err := hash.Deserialize(hr); err != nil {
+			return err
+		}
+		data, err := serialization.ReadVarBytes(hr)
+		if err != nil {
+			return err
+		}
+		header, err := deserializeHeaderData(data)
+		if err != nil {
+			return err
+		}
+		if header.Blockdata.Hash() != hash || header.Blockdata.Height != h ||
+			(h > 0 && header.Blockdata.PrevBlockHash != prevHash) {
+			return errors.New(fmt.Sprintf("UTXO snapshot header %x at height %d does not link to the previous one",
+				hash.ToArrayReverse(), h))
+		}
+		prevHash = hash
+		bd.BatchPut(append([]byte{byte(DATA_Header)}, hash.ToArray()...), data)
+
+		key := bytes.NewBuffer(nil)
+		key.WriteByte(byte(DATA_BlockHash))
+		serialization.WriteUint32(key, h)
+		bd.BatchPut(key.Bytes(), hash.ToArray())
+	}
+	if prevHash != blockHash {
+		return errors.New(fmt.Sprintf("UTXO snapshot blocks end at %x, expect %x",
+			prevHash.ToArrayReverse(), blockHash.ToArrayReverse()))
+	}
+
+	issued := make(map[Uint256]Fixed64)
+
+	// assets
+	for {
+		flag, err := serialization.ReadUint8(hr)
+		if err != nil {
+			return err
+		}
+		if flag == 0x00 {
+			break
+		}
+		var assetID Uint256
+		if err := assetID.Deserialize(hr); err != nil {
+			return err
+		}
+		asset := new(Asset)
+		if err := asset.Deserialize(hr); err != nil {
+			return err
+		}
+		if err := bd.PersistAsset(assetID, asset); err != nil {
+			return err
+		}
+		issued[assetID] = 0
+	}
+
+	// utxos
+	unspendUTXOs := make(map[Uint168]map[Uint256]map[uint32][]*tx.UTXOUnspent)
+	count := 0
+	for {
+		flag, err := serialization.ReadUint8(hr)
+		if err != nil {
+			return err
+		}
+		if flag == 0x00 {
+			break
+		}
+		var txid Uint256
+		if err := txid.Deserialize(hr); err != nil {
+			return err
+		}
+		data, err := serialization.ReadVarBytes(hr)
+		if err != nil {
+			return err
+		}
+		unspentArray, err := serialization.ReadVarBytes(hr)
+		if err != nil {
+			return err
+		}
+		indexes, err := GetUint16Array(unspentArray)
+		if err != nil {
+			return err
+		}
+
+		dr := bytes.NewReader(data)
+		txHeight, err := serialization.ReadUint32(dr)
+		if err != nil {
+			return err
+		}
+		txn := new(tx.Transaction)
+		if err := txn.Deserialize(dr); err != nil {
+			return err
+		}
+		for _, index := range indexes {
+			if int(index) >= len(txn.Outputs) {
+				return errors.New(fmt.Sprintf("transaction %x has no output %d", txid.ToArrayReverse(), index))
+			}
+			output := txn.Outputs[index]
+			issued[output.AssetID] += output.Value
+			if _, ok := unspendUTXOs[output.ProgramHash]; !ok {
+				unspendUTXOs[output.ProgramHash] = make(map[Uint256]map[uint32][]*tx.UTXOUnspent)
+			}
+			if _, ok := unspendUTXOs[output.ProgramHash][output.AssetID]; !ok {
+				unspendUTXOs[output.ProgramHash][output.AssetID] = make(map[uint32][]*tx.UTXOUnspent)
+			}
+			unspendUTXOs[output.ProgramHash][output.AssetID][txHeight] = append(
+				unspendUTXOs[output.ProgramHash][output.AssetID][txHeight],
+				&tx.UTXOUnspent{Txid: txid, Index: uint32(index), Value: output.Value})
+		}
+
+		bd.BatchPut(append([]byte{byte(DATA_Transaction)}, txid.ToArray()...), data)
+		bd.BatchPut(append([]byte{byte(IX_Unspent)}, txid.ToArray()...), unspentArray)
+
+		count++
+		if count%100000 == 0 {
+			log.Infof("Loaded %d transactions with unspent outputs", count)
+		}
+	}
+
+	for programHash, programHash_value := range unspendUTXOs {
+		for assetId, unspents := range programHash_value {
+			for h, unspent := range unspents {
+				if err := bd.PersistUnspentWithProgramHash(programHash, assetId, h, unspent); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// balances
+	for version >= 2 {
+		flag, err := serialization.ReadUint8(hr)
+		if err != nil {
+			return err
+		}
+		if flag == 0x00 {
+			break
+		}
+		var k balanceKey
+		if err := k.programHash.Deserialize(hr); err != nil {
+			return err
+		}
+		if err := k.assetID.Deserialize(hr); err != nil {
+			return err
+		}
+		var balance Fixed64
+		if err := balance.Deserialize(hr); err != nil {
+			return err
+		}
+		if err := bd.batchPutBalance(k, balance); err != nil {
+			return err
+		}
+		issued[k.assetID] += balance
+	}
+
+	// contracts
+	for version >= 3 {
+		flag, err := serialization.ReadUint8(hr)
+		if err != nil {
+			return err
+		}
+		if flag == 0x00 {
+			break
+		}
+		var codeHash Uint168
+		if err := codeHash.Deserialize(hr); err != nil {
+			return err
+		}
+		data, err := serialization.ReadVarBytes(hr)
+		if err != nil {
+			return err
+		}
+		bd.BatchPut(contractKey(codeHash), data)
+	}
+
+	for assetID, amount := range issued {
+		if err := bd.batchPutQuantityIssued(assetID, amount); err != nil {
+			return err
+		}
+	}
+
+	// trailer
+	var setHash Uint256
+	if err := setHash.Deserialize(r); err != nil {
+		return err
+	}
+	sum, _ := Uint256ParseFromBytes(hasher.Sum(nil))
+	if sum != setHash {
+		return errors.New(fmt.Sprintf("UTXO snapshot hash mismatch, got %x expect %x",
+			sum.ToArrayReverse(), setHash.ToArrayReverse()))
+	}
+
+	currentBlock := bytes.NewBuffer(nil)
+	blockHash.Serialize(currentBlock)
+	serialization.WriteUint32(currentBlock, height)
+	bd.BatchPut([]byte{byte(SYS_CurrentBlock)}, currentBlock.Bytes())
+	pruneHeight := bytes.NewBuffer(nil)
+	serialization.WriteUint32(pruneHeight, height+1)
+	bd.BatchPut([]byte{byte(SYS_PruneHeight)}, pruneHeight.Bytes())
+	bd.BatchPut([]byte{byte(CFG_Version)}, []byte{CurrentSchemaVersion})
+	if err := bd.BatchFinish(); err != nil {
+		return err
+	}
+
+	log.Infof("Loaded UTXO snapshot with %d transactions, hash %x", count, setHash.ToArrayReverse())
+	return nil
+}
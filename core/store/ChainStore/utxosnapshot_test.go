@@ -0,0 +1,119 @@
+package ChainStore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"Elastos.ELA/core/store/MemoryStore"
+)
+
+func TestUTXOSnapshotRoundTrip(t *testing.T) {
+	source, _, _ := newTestChainWithSpends(t)
+	defer source.Close()
+
+	dir, err := ioutil.TempDir("", "utxosnapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot")
+
+	info, err := source.DumpUTXOSet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Height != 4 || info.BlockHash != source.GetCurrentBlockHash() {
+		t.Fatalf("snapshot at height %d, block %x, want the current block", info.Height, info.BlockHash)
+	}
+	if _, err := source.DumpUTXOSet(path); err == nil {
+		t.Fatal("snapshot overwrote an existing file")
+	}
+
+	loaded := NewChainStore(MemoryStore.NewMemoryStore())
+	defer loaded.Close()
+	if err := LoadUTXOSnapshot(loaded, path); err != nil {
+		t.Fatal(err)
+	}
+
+	// The loaded store has the UTXO set, the assets and the balances of
+	// the source, and the headers of its blocks.
+	prefixes := []DataEntryPrefix{DATA_BlockHash, DATA_Header, IX_Unspent, IX_Unspent_UTXO,
+		ST_Info, ST_QuantityIssued, ST_Balance, ST_Contract, SYS_CurrentBlock}
+	checkStoreContent(t, "loaded store", storeContent(t, loaded, prefixes...), storeContent(t, source, prefixes...))
+
+	// Only the transactions with unspent outputs are loaded, the blocks
+	// of the snapshot are pruned.
+	sourceTxns := storeContent(t, source, DATA_Transaction)
+	loadedTxns := storeContent(t, loaded, DATA_Transaction)
+	if len(loadedTxns) != int(info.Transactions) {
+		t.Errorf("%d transactions loaded, want %d", len(loadedTxns), info.Transactions)
+	}
+	for key, value := range loadedTxns {
+		if !bytes.Equal(sourceTxns[key], value) {
+			t.Errorf("loaded transaction %x differs", key)
+		}
+	}
+	if err := loaded.loadPruneState(); err != nil {
+		t.Fatal(err)
+	}
+	if height := loaded.GetPruneHeight(); height != info.Height+1 {
+		t.Errorf("prune height is %d, want %d", height, info.Height+1)
+	}
+
+	// The loaded UTXO set dumps to the same snapshot.
+	loadedInfo, err := loaded.GetUTXOSetInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loadedInfo, info) {
+		t.Errorf("loaded UTXO set is %+v, want %+v", loadedInfo, info)
+	}
+
+	// A snapshot is only loaded into an empty store.
+	if err := LoadUTXOSnapshot(loaded, path); err == nil {
+		t.Error("snapshot loaded into a store with blocks")
+	}
+}
+
+func TestUTXOSnapshotCorrupted(t *testing.T) {
+	source, _, _ := newTestChainWithSpends(t)
+	defer source.Close()
+
+	dir, err := ioutil.TempDir("", "utxosnapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot")
+	if _, err := source.DumpUTXOSet(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A byte changed anywhere after the magic makes the load fail, and
+	// nothing is written to the store.
+	for _, offset := range []int{len(utxoSnapshotMagic) + 2, len(data) / 2, len(data) - 1} {
+		corrupted := append([]byte{}, data...)
+		corrupted[offset] ^= 0x01
+		corruptedPath := filepath.Join(dir, "corrupted")
+		if err := ioutil.WriteFile(corruptedPath, corrupted, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		store := NewChainStore(MemoryStore.NewMemoryStore())
+		if err := LoadUTXOSnapshot(store, corruptedPath); err == nil {
+			t.Errorf("snapshot with byte %d changed loaded", offset)
+		}
+		if content := storeContent(t, store); len(content) != 0 {
+			t.Errorf("snapshot with byte %d changed wrote %d entries", offset, len(content))
+		}
+		store.Close()
+	}
+}
@@ -0,0 +1,409 @@
+package ChainStore
+
+import (
+	"bytes"
+	"fmt"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/log"
+	"Elastos.ELA/common/serialization"
+	. "Elastos.ELA/core/ledger"
+	tx "Elastos.ELA/core/transaction"
+	"Elastos.ELA/crypto"
+)
+
+type verifyChainTask struct {
+	depth  uint32
+	issues *[]*ChainInconsistency
+	reply  chan error
+}
+
+type outPoint struct {
+	txid  Uint256
+	index uint16
+}
+
+// unspentEntry is an output of IX_Unspent together with the
+// IX_Unspent_UTXO bucket expected to hold it.
+type unspentEntry struct {
+	programHash Uint168
+	assetID     Uint256
+	height      uint32
+	value       Fixed64
+	indexed     bool
+}
+
+type chainVerifier struct {
+	*ChainStore
+	issues   []*ChainInconsistency
+	unspents map[outPoint]*unspentEntry
+}
+
+func (v *chainVerifier) report(key []byte, format string, args ...interface{}) {
+	issue := &ChainInconsistency{Key: key, Message: fmt.Sprintf(format, args...)}
+	log.Warnf("verifychain: %v", issue)
+	v.issues = append(v.issues, issue)
+}
+
+// VerifyChain checks that the block hashes, headers, transactions, undo
+// data and unspent indexes of the depth latest blocks agree with each
+// other, depth 0 checks every block. The unspent indexes and the issued
+// amounts are always cross-checked as a whole.
+func (bd *ChainStore) VerifyChain(depth uint32) ([]*ChainInconsistency, error) {
+	var issues []*ChainInconsistency
+	reply := make(chan error)
+	bd.taskCh <- &verifyChainTask{depth: depth, issues: &issues, reply: reply}
+	if err := <-reply; err != nil {
+		return nil, err
+	}
+
+	return issues, nil
+}
+
+// handleVerifyChainTask runs on the loop goroutine, so no block is persisted
+// while the records are checked.
+func (bd *ChainStore) handleVerifyChainTask(depth uint32) ([]*ChainInconsistency, error) {
+	v := &chainVerifier{ChainStore: bd, unspents: make(map[outPoint]*unspentEntry)}
+
+	tipHash, tip, err := bd.getCurrentBlock()
+	if err != nil {
+		return nil, err
+	}
+	if hash, err := bd.GetBlockHash(tip); err != nil || hash != tipHash {
+		v.report([]byte{byte(SYS_CurrentBlock)}, "current block %x is not the block at height %d",
+			tipHash.ToArrayReverse(), tip)
+	}
+
+	v.verifyUnspentIndexes()
+	v.verifyQuantityIssued()
+
+	start := uint32(0)
+	if depth > 0 && depth <= tip {
+		start = tip - depth + 1
+	}
+	log.Infof("Verify chain from height %d to %d", start, tip)
+
+	created := make(map[outPoint]bool)
+	spent := make(map[outPoint]uint32)
+	complete := true
+	var prevHash Uint256
+	if start > 0 {
+		prevHash, _ = bd.GetBlockHash(start - 1)
+	}
+	for h := start; h <= tip; h++ {
+		b := v.verifyBlock(h, prevHash)
+		prevHash, _ = bd.GetBlockHash(h)
+		if b == nil {
+			if h >= bd.GetPruneHeight() {
+				complete = false
+			}
+			continue
+		}
+
+		balanceAssets := bd.balanceAssets(b)
+		for _, txn := range b.Transactions {
+			if !txn.IsCoinBaseTx() {
+				for _, input := range txn.UTXOInputs {
+					spent[outPoint{input.ReferTxID, input.ReferTxOutputIndex}] = h
+				}
+			}
+			for index, output := range txn.Outputs {
+				if !balanceAssets[output.AssetID] {
+					created[outPoint{txn.Hash(), uint16(index)}] = true
+				}
+			}
+		}
+	}
+
+	// every output created in the checked blocks is unspent unless a
+	// later checked block spent it, the outputs they spent are gone.
+	if !complete {
+		log.Warn("verifychain: skip the UTXO delta check, some blocks could not be read")
+		created, spent = nil, nil
+	}
+	for op := range created {
+		if _, ok := spent[op]; ok {
+			continue
+		}
+		if _, ok := v.unspents[op]; !ok {
+			v.report(append([]byte{byte(IX_Unspent)}, op.txid.ToArray()...),
+				"unspent output %d is missing", op.index)
+		}
+	}
+	for op, height := range spent {
+		if _, ok := v.unspents[op]; ok {
+			v.report(append([]byte{byte(IX_Unspent)}, op.txid.ToArray()...),
+				"output %d spent at height %d is still unspent", op.index, height)
+		}
+	}
+
+	log.Infof("Verify chain finished, %d inconsistencies", len(v.issues))
+	return v.issues, nil
+}
+
+// verifyUnspentIndexes checks that IX_Unspent and IX_Unspent_UTXO hold the
+// same outputs, in the buckets of their program hash, asset and height.
+func (v *chainVerifier) verifyUnspentIndexes() {
+	iter := v.NewIterator([]byte{byte(IX_Unspent)})
+	for iter.Next() {
+		key := append([]byte{}, iter.Key()...)
+		txid, err := Uint256ParseFromBytes(key[1:])
+		if err != nil {
+			v.report(key, "invalid key")
+			continue
+		}
+		indexes, err := GetUint16Array(iter.Value())
+		if err != nil {
+			v.report(key, "invalid output list: %v", err)
+			continue
+		}
+		txn, height, err := v.GetTransaction(txid)
+		if err != nil {
+			v.report(key, "transaction is missing: %v", err)
+			continue
+		}
+		for _, index := range indexes {
+			if int(index) >= len(txn.Outputs) {
+				v.report(key, "transaction has no output %d", index)
+				continue
+			}
+			output := txn.Outputs[index]
+			v.unspents[outPoint{txid, index}] = &unspentEntry{
+				programHash: output.ProgramHash,
+				assetID:     output.AssetID,
+				height:      height,
+				value:       output.Value,
+			}
+		}
+	}
+	iter.Release()
+
+	iter = v.NewIterator([]byte{byte(IX_Unspent_UTXO)})
+	for iter.Next() {
+		key := append([]byte{}, iter.Key()...)
+		rk := bytes.NewReader(key[1:])
+		var programHash Uint168
+		var assetID Uint256
+		if err := programHash.Deserialize(rk); err != nil {
+			v.report(key, "invalid key")
+			continue
+		}
+		if err := assetID.Deserialize(rk); err != nil {
+			v.report(key, "invalid key")
+			continue
+		}
+		height, err := serialization.ReadUint32(rk)
+		if err != nil {
+			v.report(key, "invalid key")
+			continue
+		}
+
+		r := bytes.NewReader(iter.Value())
+		listNum, err := serialization.ReadVarUint(r, 0)
+		if err != nil {
+			v.report(key, "invalid unspent list: %v", err)
+			continue
+		}
+		for i := uint64(0); i < listNum; i++ {
+			uu := new(tx.UTXOUnspent)
+			if err := uu.Deserialize(r); err != nil {
+				v.report(key, "invalid unspent list: %v", err)
+				break
+			}
+			entry, ok := v.unspents[outPoint{uu.Txid, uint16(uu.Index)}]
+			if !ok {
+				v.report(key, "output %x:%d is not in IX_Unspent", uu.Txid.ToArrayReverse(), uu.Index)
+				continue
+			}
+			if entry.indexed {
+				v.report(key, "output %x:%d is indexed twice", uu.Txid.ToArrayReverse(), uu.Index)
+			}
+			entry.indexed = true
+			if entry.programHash != programHash || entry.assetID != assetID || entry.height != height {
+				v.report(key, "output %x:%d belongs to the bucket of height %d", uu.Txid.ToArrayReverse(), uu.Index, entry.height)
+			}
+			if entry.value != uu.Value {
+				v.report(key, "output %x:%d has value %v, expect %v", uu.Txid.ToArrayReverse(), uu.Index, uu.Value, entry.value)
+			}
+		}
+	}
+	iter.Release()
+
+	for op, entry := range v.unspents {
+		if !entry.indexed {
+			v.report(append([]byte{byte(IX_Unspent)}, op.txid.ToArray()...),
+				"output %d is not in IX_Unspent_UTXO", op.index)
+		}
+	}
+}
+
+// verifyQuantityIssued checks that the issued amount of every asset is the
+// amount of its unspent outputs and balances.
+func (v *chainVerifier) verifyQuantityIssued() {
+	amounts := make(map[Uint256]Fixed64)
+	for _, entry := range v.unspents {
+		amounts[entry.assetID] += entry.value
+	}
+
+	iter := v.NewIterator([]byte{byte(ST_Balance)})
+	for iter.Next() {
+		key := append([]byte{}, iter.Key()...)
+		if len(key) != 1+UINT168SIZE+UINT256SIZE {
+			v.report(key, "invalid key")
+			continue
+		}
+		assetID, err := Uint256ParseFromBytes(key[1+UINT168SIZE:])
+		if err != nil {
+			v.report(key, "invalid key")
+			continue
+		}
+		var balance Fixed64
+		if err := balance.Deserialize(bytes.NewReader(iter.Value())); err != nil {
+			v.report(key, "invalid balance: %v", err)
+			continue
+		}
+		if balance <= 0 {
+			v.report(key, "balance is %v", balance)
+		}
+		amounts[assetID] += balance
+	}
+	iter.Release()
+
+	iter = v.NewIterator([]byte{byte(ST_QuantityIssued)})
+	for iter.Next() {
+		key := append([]byte{}, iter.Key()...)
+		assetID, err := Uint256ParseFromBytes(key[1:])
+		if err != nil {
+			v.report(key, "invalid key")
+			continue
+		}
+		var issued Fixed64
+		if err := issued.Deserialize(bytes.NewReader(iter.Value())); err != nil {
+			v.report(key, "invalid issued amount: %v", err)
+			continue
+		}
+		if issued != amounts[assetID] {
+			v.report(key, "issued amount is %v, unspent outputs and balances hold %v", issued, amounts[assetID])
+		}
+		delete(amounts, assetID)
+	}
+	iter.Release()
+
+	for assetID, amount := range amounts {
+		v.report(append([]byte{byte(ST_QuantityIssued)}, assetID.ToArray()...),
+			"issued amount is missing, unspent outputs and balances hold %v", amount)
+	}
+}
+
+// verifyBlock checks the records of the block at height and returns it with
+// its transactions, nil if it is pruned or inconsistent.
+func (v *chainVerifier) verifyBlock(height uint32, prevHash Uint256) *Block {
+	hashKey := bytes.NewBuffer(nil)
+	hashKey.WriteByte(byte(DATA_BlockHash))
+	serialization.WriteUint32(hashKey, height)
+	hash, err := v.GetBlockHash(height)
+	if err != nil {
+		v.report(hashKey.Bytes(), "no block hash at height %d", height)
+		return nil
+	}
+
+	headerKey := append([]byte{byte(DATA_Header)}, hash.ToArray()...)
+	data, err := v.Get(headerKey)
+	if err != nil || len(data) < 8 {
+		v.report(headerKey, "header of block at height %d is missing", height)
+		return nil
+	}
+
+	// first 8 bytes is sys_fee, then the trimmed block
+	r := bytes.NewReader(data[8:])
+	header := new(Blockdata)
+	if err := header.Deserialize(r); err != nil {
+		v.report(headerKey, "invalid header: %v", err)
+		return nil
+	}
+	if headerHash := header.Hash(); headerHash != hash {
+		v.report(headerKey, "header hashes to %x", headerHash.ToArrayReverse())
+	}
+	if header.Height != height {
+		v.report(headerKey, "header height is %d, indexed at %d", header.Height, height)
+	}
+	if height > 0 && header.PrevBlockHash != prevHash {
+		v.report(headerKey, "previous block is %x, expect %x",
+			header.PrevBlockHash.ToArrayReverse(), prevHash.ToArrayReverse())
+	}
+
+	count, err := serialization.ReadUint32(r)
+	if err != nil {
+		v.report(headerKey, "invalid transaction list: %v", err)
+		return nil
+	}
+	hashes := make([]Uint256, count)
+	for i := range hashes {
+		if err := hashes[i].Deserialize(r); err != nil {
+			v.report(headerKey, "invalid transaction list: %v", err)
+			return nil
+		}
+	}
+	root, err := crypto.ComputeRoot(hashes)
+	if err != nil {
+		v.report(headerKey, "compute merkle root failed: %v", err)
+	} else if root != header.TransactionsRoot {
+		v.report(headerKey, "transactions rebuild to merkle root %x, expect %x",
+			root.ToArrayReverse(), header.TransactionsRoot.ToArrayReverse())
+	}
+
+	if height < v.GetPruneHeight() {
+		return nil
+	}
+
+	b := &Block{Blockdata: header}
+	consistent := true
+	for _, txHash := range hashes {
+		txKey := append([]byte{byte(DATA_Transaction)}, txHash.ToArray()...)
+		txn, txHeight, err := v.GetTransaction(txHash)
+		if err != nil {
+			v.report(txKey, "transaction of block at height %d is missing", height)
+			consistent = false
+			continue
+		}
+		if hash := txn.Hash(); hash != txHash {
+			v.report(txKey, "transaction hashes to %x", hash.ToArrayReverse())
+			consistent = false
+		}
+		if txHeight != height {
+			v.report(txKey, "transaction height is %d, expect %d", txHeight, height)
+		}
+		b.Transactions = append(b.Transactions, txn)
+	}
+	if !consistent {
+		return nil
+	}
+
+	undoKey := append([]byte{byte(DATA_BlockUndo)}, hash.ToArray()...)
+	if data, err := v.Get(undoKey); err == nil {
+		stored := new(BlockUndo)
+		if err := stored.Deserialize(bytes.NewReader(data)); err != nil {
+			v.report(undoKey, "invalid undo data: %v", err)
+			return b
+		}
+		undo, err := v.buildBlockUndo(b)
+		if err != nil {
+			v.report(undoKey, "rebuild undo data failed: %v", err)
+			return b
+		}
+		if len(stored.SpentOutputs) != len(undo.SpentOutputs) {
+			v.report(undoKey, "undo data has %d spent outputs, expect %d",
+				len(stored.SpentOutputs), len(undo.SpentOutputs))
+			return b
+		}
+		for i, spent := range undo.SpentOutputs {
+			if *stored.SpentOutputs[i] != *spent {
+				v.report(undoKey, "spent output %d is %x:%d, expect %x:%d", i,
+					stored.SpentOutputs[i].ReferTxID.ToArrayReverse(), stored.SpentOutputs[i].ReferTxOutputIndex,
+					spent.ReferTxID.ToArrayReverse(), spent.ReferTxOutputIndex)
+			}
+		}
+	}
+
+	return b
+}
@@ -18,6 +18,12 @@ type LevelDBStore struct {
 // too small will lead to high false positive rate.
 const BITSPERKEY = 10
 
+func init() {
+	RegisterBackend("leveldb", func(path string) (IStore, error) {
+		return NewLevelDBStore(path)
+	})
+}
+
 func NewLevelDBStore(file string) (*LevelDBStore, error) {
 
 	// default Options
@@ -48,6 +54,9 @@ func (self *LevelDBStore) Put(key []byte, value []byte) error {
 
 func (self *LevelDBStore) Get(key []byte) ([]byte, error) {
 	dat, err := self.db.Get(key, nil)
+	if err == errors.ErrNotFound {
+		return nil, ErrNotFound
+	}
 	return dat, err
 }
 
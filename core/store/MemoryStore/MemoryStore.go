@@ -0,0 +1,150 @@
+package MemoryStore
+
+import (
+	"sort"
+	"sync"
+
+	. "Elastos.ELA/core/store"
+)
+
+func init() {
+	RegisterBackend("memory", func(path string) (IStore, error) {
+		return NewMemoryStore(), nil
+	})
+}
+
+type batchOp struct {
+	key    string
+	value  []byte
+	delete bool
+}
+
+// MemoryStore is an IStore keeping everything in memory, for tests and
+// ephemeral nodes. The keys are kept sorted for the iterators.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	values map[string][]byte
+	keys   []string
+	batch  []batchOp
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		values: make(map[string][]byte),
+	}
+}
+
+func (self *MemoryStore) put(key string, value []byte) {
+	if _, ok := self.values[key]; !ok {
+		i := sort.SearchStrings(self.keys, key)
+		self.keys = append(self.keys, "")
+		copy(self.keys[i+1:], self.keys[i:])
+		self.keys[i] = key
+	}
+	self.values[key] = append([]byte{}, value...)
+}
+
+func (self *MemoryStore) delete(key string) {
+	if _, ok := self.values[key]; !ok {
+		return
+	}
+	delete(self.values, key)
+	i := sort.SearchStrings(self.keys, key)
+	self.keys = append(self.keys[:i], self.keys[i+1:]...)
+}
+
+func (self *MemoryStore) Put(key []byte, value []byte) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.put(string(key), value)
+	return nil
+}
+
+func (self *MemoryStore) Get(key []byte) ([]byte, error) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
+	value, ok := self.values[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, value...), nil
+}
+
+func (self *MemoryStore) Delete(key []byte) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.delete(string(key))
+	return nil
+}
+
+func (self *MemoryStore) NewBatch() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.batch = nil
+	return nil
+}
+
+func (self *MemoryStore) BatchPut(key []byte, value []byte) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.batch = append(self.batch, batchOp{key: string(key), value: append([]byte{}, value...)})
+	return nil
+}
+
+func (self *MemoryStore) BatchDelete(key []byte) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	self.batch = append(self.batch, batchOp{key: string(key), delete: true})
+	return nil
+}
+
+// BatchCommit applies the batch at once, readers see all of it or none.
+func (self *MemoryStore) BatchCommit() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for _, op := range self.batch {
+		if op.delete {
+			self.delete(op.key)
+		} else {
+			self.put(op.key, op.value)
+		}
+	}
+	self.batch = nil
+	return nil
+}
+
+func (self *MemoryStore) Close() error {
+	return nil
+}
+
+// NewIterator returns an iterator over a snapshot of the keys with prefix.
+func (self *MemoryStore) NewIterator(prefix []byte) IIterator {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+
+	start := sort.SearchStrings(self.keys, string(prefix))
+	end := start
+	for end < len(self.keys) && len(self.keys[end]) >= len(prefix) &&
+		self.keys[end][:len(prefix)] == string(prefix) {
+		end++
+	}
+
+	iter := &Iterator{
+		keys:   make([]string, end-start),
+		values: make([][]byte, end-start),
+		pos:    -1,
+	}
+	copy(iter.keys, self.keys[start:end])
+	for i, key := range iter.keys {
+		iter.values[i] = self.values[key]
+	}
+
+	return iter
+}
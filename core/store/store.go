@@ -1,5 +1,13 @@
 package store
 
+import (
+	"errors"
+)
+
+// ErrNotFound is returned by IStore.Get for a key which is not in the
+// store.
+var ErrNotFound = errors.New("store: not found")
+
 type IIterator interface {
 	Next() bool
 	Prev() bool
@@ -22,3 +30,26 @@ type IStore interface {
 	Close() error
 	NewIterator(prefix []byte) IIterator
 }
+
+// NewStoreFunc opens the store of a backend at path.
+type NewStoreFunc func(path string) (IStore, error)
+
+var backends = make(map[string]NewStoreFunc)
+
+// RegisterBackend makes a store backend available by name, it is called
+// from the init function of the backend package.
+func RegisterBackend(name string, newStore NewStoreFunc) {
+	if _, ok := backends[name]; ok {
+		panic("store backend " + name + " registered twice")
+	}
+	backends[name] = newStore
+}
+
+// NewStore opens the store at path with the named backend.
+func NewStore(backend string, path string) (IStore, error) {
+	newStore, ok := backends[backend]
+	if !ok {
+		return nil, errors.New("unknown store backend " + backend)
+	}
+	return newStore(path)
+}
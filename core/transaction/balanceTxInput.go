@@ -20,6 +20,10 @@ func (self BalanceTxInput) String() string {
 		"}"
 }
 
+func (bi *BalanceTxInput) ToString() string {
+	return bi.ProgramHash.String() + bi.AssetID.String()
+}
+
 func (bi *BalanceTxInput) Serialize(w io.Writer)  {
 	bi.AssetID.Serialize(w)
 	bi.Value.Serialize(w)
@@ -3,11 +3,24 @@ package payload
 import (
 	"Elastos.ELA/common/serialization"
 	. "Elastos.ELA/core/code"
+	"Elastos.ELA/core/contract"
 	"io"
+	"bytes"
+	"errors"
 )
 
 const DeployCodePayloadVersion byte = 0x00
 
+// limits of the code, its parameter types and the texts describing it
+const (
+	MaxDeployCodeSize       = contract.MaxScriptSize
+	MaxDeployParameterCount = 252
+	MaxDeployInfoSize       = 1024
+)
+
+// DeployCode stores Code in the ledger under its program hash, with the
+// texts describing it. Outputs sent to the program hash are spent with Code
+// as the program code.
 type DeployCode struct {
 	Code        *FunctionCode
 	Name        string
@@ -18,12 +31,18 @@ type DeployCode struct {
 }
 
 func (dc *DeployCode) Data(version byte) []byte {
-	// TODO: Data()
+	buf := new(bytes.Buffer)
+	if err := dc.Serialize(buf, version); err != nil {
+		return nil
+	}
 
-	return []byte{0}
+	return buf.Bytes()
 }
 
 func (dc *DeployCode) Serialize(w io.Writer, version byte) error {
+	if dc.Code == nil {
+		return errors.New("[DeployCode], Code is nil.")
+	}
 
 	err := dc.Code.Serialize(w)
 	if err != nil {
@@ -59,6 +78,7 @@ func (dc *DeployCode) Serialize(w io.Writer, version byte) error {
 }
 
 func (dc *DeployCode) Deserialize(r io.Reader, version byte) error {
+	dc.Code = new(FunctionCode)
 	err := dc.Code.Deserialize(r)
 	if err != nil {
 		return err
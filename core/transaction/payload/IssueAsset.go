@@ -0,0 +1,23 @@
+package payload
+
+import "io"
+
+const IssueAssetPayloadVersion byte = 0x00
+
+// IssueAsset creates new units of registered assets: the outputs of an
+// asset exceeding its inputs are issued, and the transaction must be signed
+// by the controller of every asset it issues.
+type IssueAsset struct {
+}
+
+func (a *IssueAsset) Data(version byte) []byte {
+	return []byte{}
+}
+
+func (a *IssueAsset) Serialize(w io.Writer, version byte) error {
+	return nil
+}
+
+func (a *IssueAsset) Deserialize(r io.Reader, version byte) error {
+	return nil
+}
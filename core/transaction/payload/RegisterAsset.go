@@ -4,11 +4,16 @@ import (
 	"Elastos.ELA/common"
 	"Elastos.ELA/core/asset"
 	"io"
+	"bytes"
 	"errors"
 )
 
 const RegisterPayloadVersion byte = 0x00
 
+// RegisterAsset registers Asset under the hash of the transaction, which is
+// the asset id. Amount is the maximum quantity of the asset in unspent
+// outputs and balances, and Controller the program hash which must sign the
+// IssueAsset transactions of it.
 type RegisterAsset struct {
 	Asset      *asset.Asset
 	Amount     common.Fixed64
@@ -16,9 +21,12 @@ type RegisterAsset struct {
 }
 
 func (a *RegisterAsset) Data(version byte) []byte {
-	//TODO: implement RegisterAsset.Data()
-	return []byte{0}
+	buf := new(bytes.Buffer)
+	if err := a.Serialize(buf, version); err != nil {
+		return nil
+	}
 
+	return buf.Bytes()
 }
 
 func (a *RegisterAsset) Serialize(w io.Writer, version byte) error {
@@ -0,0 +1,85 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"Elastos.ELA/common/serialization"
+)
+
+// The transaction data committed to by signatures with a hash type, see
+// signature.SignatureHash.
+
+// SighashTransaction is a transaction with the outputs spent by its inputs,
+// it implements signature.SighashData.
+type SighashTransaction struct {
+	*Transaction
+	References map[*UTXOTxInput]*TxOutput
+}
+
+func (tx *SighashTransaction) SerializeSpentOutput(w io.Writer, index int) error {
+	output, ok := tx.References[tx.UTXOInputs[index]]
+	if !ok {
+		return errors.New(fmt.Sprintf("output spent by input %d not found", index))
+	}
+	return output.Serialize(w)
+}
+
+// SerializeSighashCommon writes the type, the payload, the attributes and
+// the balance inputs of the transaction.
+func (tx *Transaction) SerializeSighashCommon(w io.Writer) error {
+	if tx.Payload == nil {
+		return errors.New("Transaction Payload is nil.")
+	}
+	if _, err := w.Write([]byte{byte(tx.TxType), tx.PayloadVersion}); err != nil {
+		return err
+	}
+	if err := tx.Payload.Serialize(w, tx.PayloadVersion); err != nil {
+		return err
+	}
+	if err := serialization.WriteVarUint(w, uint64(len(tx.Attributes))); err != nil {
+		return err
+	}
+	for _, attr := range tx.Attributes {
+		if err := attr.Serialize(w); err != nil {
+			return err
+		}
+	}
+	if err := serialization.WriteVarUint(w, uint64(len(tx.BalanceInputs))); err != nil {
+		return err
+	}
+	for _, input := range tx.BalanceInputs {
+		input.Serialize(w)
+	}
+
+	return nil
+}
+
+func (tx *Transaction) GetInputCount() int {
+	return len(tx.UTXOInputs)
+}
+
+func (tx *Transaction) SerializeInputReference(w io.Writer, index int) error {
+	input := tx.UTXOInputs[index]
+	if _, err := input.ReferTxID.Serialize(w); err != nil {
+		return err
+	}
+	return serialization.WriteUint16(w, input.ReferTxOutputIndex)
+}
+
+func (tx *Transaction) GetInputSequence(index int) uint32 {
+	return tx.UTXOInputs[index].Sequence
+}
+
+func (tx *Transaction) GetOutputCount() int {
+	return len(tx.Outputs)
+}
+
+func (tx *Transaction) SerializeOutput(w io.Writer, index int) error {
+	return tx.Outputs[index].Serialize(w)
+}
+
+func (tx *Transaction) GetLockTime() uint32 {
+	return tx.LockTime
+}
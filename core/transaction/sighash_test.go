@@ -0,0 +1,279 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/core/contract"
+	. "Elastos.ELA/core/signature"
+	"Elastos.ELA/core/transaction/payload"
+)
+
+// newSighashTransaction returns a transaction with 3 inputs and 3 outputs,
+// and the outputs spent by its inputs.
+func newSighashTransaction() *SighashTransaction {
+	txn := newTestTransaction(100, 1, 2, 3)
+	references := make(map[*UTXOTxInput]*TxOutput)
+	for i, input := range txn.UTXOInputs {
+		txn.Outputs = append(txn.Outputs, &TxOutput{
+			AssetID:     Uint256{0xe1},
+			Value:       Fixed64(i + 1),
+			ProgramHash: Uint168{byte(i + 1)},
+		})
+		references[input] = &TxOutput{
+			AssetID:     Uint256{0xe1},
+			Value:       Fixed64(10 * (i + 1)),
+			ProgramHash: Uint168{byte(0x10 + i)},
+		}
+	}
+	return &SighashTransaction{Transaction: txn, References: references}
+}
+
+func TestSignatureHashCommitments(t *testing.T) {
+	const inputIndex = 1
+	changes := []struct {
+		name   string
+		change func(txn *SighashTransaction)
+	}{
+		{"own outpoint", func(txn *SighashTransaction) { txn.UTXOInputs[1].ReferTxOutputIndex++ }},
+		{"own sequence", func(txn *SighashTransaction) { txn.UTXOInputs[1].Sequence++ }},
+		{"own spent value", func(txn *SighashTransaction) { txn.References[txn.UTXOInputs[1]].Value++ }},
+		{"other outpoint", func(txn *SighashTransaction) { txn.UTXOInputs[0].ReferTxID = Uint256{0xff} }},
+		{"other sequence", func(txn *SighashTransaction) { txn.UTXOInputs[0].Sequence++ }},
+		{"other spent value", func(txn *SighashTransaction) { txn.References[txn.UTXOInputs[0]].Value++ }},
+		{"added input", func(txn *SighashTransaction) {
+			input := &UTXOTxInput{}
+			txn.UTXOInputs = append(txn.UTXOInputs, input)
+			txn.References[input] = &TxOutput{}
+		}},
+		{"own output", func(txn *SighashTransaction) { txn.Outputs[1].Value++ }},
+		{"other output", func(txn *SighashTransaction) { txn.Outputs[0].ProgramHash = Uint168{0xff} }},
+		{"added output", func(txn *SighashTransaction) { txn.Outputs = append(txn.Outputs, &TxOutput{}) }},
+		{"lock time", func(txn *SighashTransaction) { txn.LockTime++ }},
+		{"attribute", func(txn *SighashTransaction) {
+			attr := NewTxAttribute(Nonce, []byte{1})
+			txn.Attributes = append(txn.Attributes, &attr)
+		}},
+		{"payload", func(txn *SighashTransaction) { txn.TxType, txn.Payload = Record, &payload.Record{} }},
+	}
+
+	// the changes which alter the digest of each hash type, in the order
+	// of changes
+	tests := []struct {
+		hashType byte
+		commits  []bool
+	}{
+		{SIGHASH_ALL, []bool{true, true, true, true, true, true, true, true, true, true, true, true, true}},
+		{SIGHASH_NONE, []bool{true, true, true, true, false, true, true, false, false, false, true, true, true}},
+		{SIGHASH_SINGLE, []bool{true, true, true, true, false, true, true, true, false, false, true, true, true}},
+		{SIGHASH_ALL | SIGHASH_ANYONECANPAY, []bool{true, true, true, false, false, false, false, true, true, true, true, true, true}},
+		{SIGHASH_NONE | SIGHASH_ANYONECANPAY, []bool{true, true, true, false, false, false, false, false, false, false, true, true, true}},
+		{SIGHASH_SINGLE | SIGHASH_ANYONECANPAY, []bool{true, true, true, false, false, false, false, true, false, false, true, true, true}},
+	}
+	for _, test := range tests {
+		digest, err := SignatureHash(newSighashTransaction(), inputIndex, test.hashType)
+		if err != nil {
+			t.Fatalf("hash type 0x%02x: %v", test.hashType, err)
+		}
+		for i, change := range changes {
+			txn := newSighashTransaction()
+			change.change(txn)
+			changed, err := SignatureHash(txn, inputIndex, test.hashType)
+			if err != nil {
+				t.Fatalf("hash type 0x%02x, %s: %v", test.hashType, change.name, err)
+			}
+			if (changed != digest) != test.commits[i] {
+				t.Errorf("hash type 0x%02x, %s: digest changed %v, want %v",
+					test.hashType, change.name, changed != digest, test.commits[i])
+			}
+		}
+	}
+
+	// the digest differs between the hash types and the inputs
+	seen := make(map[Uint256]bool)
+	for _, test := range tests {
+		for i := 0; i < 3; i++ {
+			digest, err := SignatureHash(newSighashTransaction(), i, test.hashType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seen[digest] {
+				t.Errorf("hash type 0x%02x of input %d has the digest of another", test.hashType, i)
+			}
+			seen[digest] = true
+		}
+	}
+}
+
+func TestSignatureHashErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		outputs    int
+		inputIndex int
+		hashType   byte
+		valid      bool
+	}{
+		{"hash type 0", 3, 0, 0x00, false},
+		{"hash type 4", 3, 0, 0x04, false},
+		{"hash type 0x7f", 3, 0, 0x7f, false},
+		{"ANYONECANPAY alone", 3, 0, SIGHASH_ANYONECANPAY, false},
+		{"ANYONECANPAY with type 4", 3, 0, SIGHASH_ANYONECANPAY | 0x04, false},
+		{"ALL without input", 3, -1, SIGHASH_ALL, true},
+		{"NONE without input", 3, -1, SIGHASH_NONE, true},
+		{"SINGLE without input", 3, -1, SIGHASH_SINGLE, false},
+		{"ALL|ANYONECANPAY without input", 3, -1, SIGHASH_ALL | SIGHASH_ANYONECANPAY, false},
+		{"NONE|ANYONECANPAY without input", 3, -1, SIGHASH_NONE | SIGHASH_ANYONECANPAY, false},
+		{"input index -2", 3, -2, SIGHASH_ALL, false},
+		{"input index past the inputs", 3, 3, SIGHASH_ALL, false},
+		{"SINGLE with its output", 3, 2, SIGHASH_SINGLE, true},
+		{"SINGLE without its output", 2, 2, SIGHASH_SINGLE, false},
+		{"SINGLE|ANYONECANPAY without its output", 2, 2, SIGHASH_SINGLE | SIGHASH_ANYONECANPAY, false},
+		{"NONE without its output", 2, 2, SIGHASH_NONE, true},
+	}
+	for _, test := range tests {
+		txn := newSighashTransaction()
+		txn.Outputs = txn.Outputs[:test.outputs]
+		_, err := SignatureHash(txn, test.inputIndex, test.hashType)
+		if test.valid && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
+
+// testSigner signs with a P-256 key behind a standard program code.
+type testSigner struct {
+	key  *ecdsa.PrivateKey
+	code []byte
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := make([]byte, 33)
+	publicKey[0] = 0x02 | byte(key.Y.Bit(0))
+	x := key.X.Bytes()
+	copy(publicKey[33-len(x):], x)
+
+	code := append([]byte{byte(len(publicKey))}, publicKey...)
+	return &testSigner{key: key, code: append(code, contract.OP_CHECKSIG)}
+}
+
+// sign returns the parameter pushing the signature of data.
+func (s *testSigner) sign(t *testing.T, data []byte) []byte {
+	digest := sha256.Sum256(data)
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	rb, sb := r.Bytes(), sig.Bytes()
+	copy(signature[32-len(rb):], rb)
+	copy(signature[64-len(sb):], sb)
+	return signature
+}
+
+func pushSignature(signature []byte) []byte {
+	return append([]byte{byte(len(signature))}, signature...)
+}
+
+func TestSignatureCheckerRoundTrip(t *testing.T) {
+	signer := newTestSigner(t)
+	const inputIndex = 1
+
+	verify := func(txn *SighashTransaction, inputCount int, param []byte) error {
+		return verifyProgram(txn, inputIndex, inputCount, signer.code, param, txn.GetDataContent(),
+			VerifyScripts|VerifySigHashTypes)
+	}
+	signHashType := func(txn *SighashTransaction, hashType byte) []byte {
+		digest, err := SignatureHash(txn, inputIndex, hashType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pushSignature(append(signer.sign(t, digest[:]), hashType))
+	}
+
+	// 64 byte signatures sign the whole unsigned transaction
+	txn := newSighashTransaction()
+	param := pushSignature(signer.sign(t, txn.GetDataContent()))
+	if err := verify(txn, 1, param); err != nil {
+		t.Errorf("64 byte signature: %v", err)
+	}
+	if err := verify(txn, 2, param); err != nil {
+		t.Errorf("64 byte signature of a signer with 2 inputs: %v", err)
+	}
+	txn.Outputs[0].Value++
+	if err := verify(txn, 1, param); err == nil {
+		t.Error("64 byte signature of a changed transaction passed")
+	}
+
+	// 65 byte signatures sign the signature hash of their hash type
+	for _, hashType := range []byte{SIGHASH_ALL, SIGHASH_NONE, SIGHASH_SINGLE,
+		SIGHASH_ALL | SIGHASH_ANYONECANPAY, SIGHASH_NONE | SIGHASH_ANYONECANPAY, SIGHASH_SINGLE | SIGHASH_ANYONECANPAY} {
+		txn := newSighashTransaction()
+		param := signHashType(txn, hashType)
+		if err := verify(txn, 1, param); err != nil {
+			t.Errorf("hash type 0x%02x: %v", hashType, err)
+		}
+		if err := verifyProgram(txn, inputIndex, 1, signer.code, param, txn.GetDataContent(), VerifyScripts); err == nil {
+			t.Errorf("hash type 0x%02x passed before hash types are active", hashType)
+		}
+
+		// only SIGHASH_ALL binds every input of a signer with several
+		err := verify(txn, 2, param)
+		if hashType == SIGHASH_ALL && err != nil {
+			t.Errorf("hash type 0x%02x of a signer with 2 inputs: %v", hashType, err)
+		}
+		if hashType != SIGHASH_ALL && err == nil {
+			t.Errorf("hash type 0x%02x of a signer with 2 inputs passed", hashType)
+		}
+
+		// another hash type appended to the signature
+		other := append([]byte{}, param...)
+		other[len(other)-1] ^= SIGHASH_ANYONECANPAY
+		if err := verify(txn, 1, other); err == nil {
+			t.Errorf("hash type 0x%02x signature passed as 0x%02x", hashType, other[len(other)-1])
+		}
+
+		// the outputs other than the own output are not committed to by
+		// NONE and SINGLE
+		txn.Outputs[0].Value++
+		err = verify(txn, 1, param)
+		if hashType&^SIGHASH_ANYONECANPAY == SIGHASH_ALL && err == nil {
+			t.Errorf("hash type 0x%02x signature of a changed output passed", hashType)
+		}
+		if hashType&^SIGHASH_ANYONECANPAY != SIGHASH_ALL && err != nil {
+			t.Errorf("hash type 0x%02x signature of another changed output: %v", hashType, err)
+		}
+	}
+
+	// the signature of a signer without an input signs for input -1
+	txn = newSighashTransaction()
+	digest, err := SignatureHash(txn, -1, SIGHASH_ALL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	param = pushSignature(append(signer.sign(t, digest[:]), SIGHASH_ALL))
+	if err := verifyProgram(txn, -1, 0, signer.code, param, txn.GetDataContent(),
+		VerifyScripts|VerifySigHashTypes); err != nil {
+		t.Errorf("signer without input: %v", err)
+	}
+	if err := verify(txn, 1, param); err == nil {
+		t.Error("signature for input -1 passed for input 1")
+	}
+
+	// signatures of other lengths
+	for _, length := range []int{63, 66} {
+		if err := verify(txn, 1, pushSignature(make([]byte, length))); err == nil {
+			t.Errorf("%d byte signature passed", length)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package transaction
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	. "Elastos.ELA/common"
+)
+
+// MaxSignatureCacheSize is the number of verified programs remembered by
+// the signature cache.
+const MaxSignatureCacheSize = 100000
+
+type signatureJob struct {
+	txn        *SighashTransaction
+	txHash     Uint256
+	index      int
+	inputIndex int
+	inputCount int
+	content    []byte
+	flags      VerifyFlags
+	result     chan *signatureError
+}
+
+type signatureError struct {
+	txn *Transaction
+	err error
+}
+
+// run verifies the program of the job, unless it is already known valid
+// from the signature cache.
+func (job *signatureJob) run() *signatureError {
+	program := job.txn.GetPrograms()[job.index]
+	key := signatureCacheKey{txHash: job.txHash, index: job.index, flags: job.flags}
+	digest := programDigest(program.Code, program.Parameter)
+	if sigCache.exists(key, digest) {
+		return nil
+	}
+
+	if err := verifyProgram(job.txn, job.inputIndex, job.inputCount, program.Code, program.Parameter, job.content, job.flags); err != nil {
+		return &signatureError{txn: job.txn.Transaction, err: err}
+	}
+	sigCache.add(key, digest)
+
+	return nil
+}
+
+// signatureCacheKey identifies a program of a transaction verified with
+// the flags. The transaction hash does not cover the programs, so an entry
+// also keeps the digest of the program it was verified with.
+type signatureCacheKey struct {
+	txHash Uint256
+	index  int
+	flags  VerifyFlags
+}
+
+type signatureCache struct {
+	sync.RWMutex
+	entries map[signatureCacheKey][sha256.Size]byte
+}
+
+var sigCache = &signatureCache{
+	entries: make(map[signatureCacheKey][sha256.Size]byte),
+}
+
+func programDigest(code, param []byte) [sha256.Size]byte {
+	return sha256.Sum256(append(append([]byte{}, code...), param...))
+}
+
+func (c *signatureCache) exists(key signatureCacheKey, digest [sha256.Size]byte) bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	d, ok := c.entries[key]
+	return ok && d == digest
+}
+
+func (c *signatureCache) add(key signatureCacheKey, digest [sha256.Size]byte) {
+	c.Lock()
+	defer c.Unlock()
+
+	// Evict an arbitrary entry when the cache is full, map iteration
+	// order is random.
+	if len(c.entries) >= MaxSignatureCacheSize {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = digest
+}
+
+// signatureJobs is the queue of the signature verification workers, jobs
+// are run by the caller when no workers are started.
+var signatureJobs chan *signatureJob
+
+// StartSignatureVerifier starts the workers verifying the signatures of
+// blocks and transactions, it is called once on start up.
+func StartSignatureVerifier(workers int) {
+	signatureJobs = make(chan *signatureJob, workers*2)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range signatureJobs {
+				job.result <- job.run()
+			}
+		}()
+	}
+}
+
+// runSignatureJobs verifies all the jobs and returns the first failure.
+func runSignatureJobs(jobs []*signatureJob) *signatureError {
+	if signatureJobs == nil {
+		for _, job := range jobs {
+			if err := job.run(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	result := make(chan *signatureError, len(jobs))
+	go func() {
+		for _, job := range jobs {
+			job.result = result
+			signatureJobs <- job
+		}
+	}()
+
+	var failure *signatureError
+	for range jobs {
+		if err := <-result; err != nil && failure == nil {
+			failure = err
+		}
+	}
+
+	return failure
+}
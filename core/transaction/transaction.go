@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"io"
+	"math"
 	"sort"
 
 	"fmt"
@@ -27,8 +28,35 @@ const (
 	Record        TransactionType = 0x03
 	Deploy        TransactionType = 0x04
 	SideMining    TransactionType = 0x05
+	IssueAsset    TransactionType = 0x06
 )
 
+// TxBalanceInputsFlag is set in the serialized transaction type when the
+// transaction has balance inputs, they follow the UTXO inputs. Transactions
+// without balance inputs keep their former serialization and hash.
+const TxBalanceInputsFlag byte = 0x80
+
+const (
+	// LockTimeThreshold is the number below which a lock time is a block
+	// height, and from which it is a unix time.
+	LockTimeThreshold = 500000000
+
+	// SequenceFinal is the sequence of an input which does not enable the
+	// lock time of its transaction once sequence locks are active.
+	SequenceFinal = math.MaxUint32
+)
+
+// FinalSequence returns the sequence of an input which does not enable the
+// lock time of its transaction, SequenceFinal once sequence locks are
+// active and math.MaxUint16 before. The lock time is only enforced when one
+// of the inputs is not final.
+func FinalSequence(sequenceLocks bool) uint32 {
+	if sequenceLocks {
+		return SequenceFinal
+	}
+	return math.MaxUint16
+}
+
 func (self TransactionType) Name() string {
 	switch self {
 	case CoinBase:
@@ -43,6 +71,8 @@ func (self TransactionType) Name() string {
 		return "Deploy"
 	case SideMining:
 		return "SideMining"
+	case IssueAsset:
+		return "IssueAsset"
 	default:
 		return "Unknown"
 	}
@@ -138,7 +168,11 @@ func (tx *Transaction) Serialize(w io.Writer) error {
 //Serialize the Transaction data without contracts
 func (tx *Transaction) SerializeUnsigned(w io.Writer) error {
 	//txType
-	w.Write([]byte{byte(tx.TxType)})
+	if len(tx.BalanceInputs) > 0 {
+		w.Write([]byte{byte(tx.TxType) | TxBalanceInputsFlag})
+	} else {
+		w.Write([]byte{byte(tx.TxType)})
+	}
 	//PayloadVersion
 	w.Write([]byte{tx.PayloadVersion})
 	//Payload
@@ -166,7 +200,16 @@ func (tx *Transaction) SerializeUnsigned(w io.Writer) error {
 			utxo.Serialize(w)
 		}
 	}
-	// TODO BalanceInputs
+	//[]*BalanceInputs
+	if len(tx.BalanceInputs) > 0 {
+		err = serialization.WriteVarUint(w, uint64(len(tx.BalanceInputs)))
+		if err != nil {
+			return errors.New("Transaction item BalanceInputs length serialization failed.")
+		}
+		for _, input := range tx.BalanceInputs {
+			input.Serialize(w)
+		}
+	}
 	//[]*Outputs
 	err = serialization.WriteVarUint(w, uint64(len(tx.Outputs)))
 	if err != nil {
@@ -221,11 +264,15 @@ func (tx *Transaction) DeserializeUnsigned(r io.Reader) error {
 	if err != nil {
 		return err
 	}
-	tx.TxType = TransactionType(txType[0])
-	return tx.DeserializeUnsignedWithoutType(r)
+	tx.TxType = TransactionType(txType[0] &^ TxBalanceInputsFlag)
+	return tx.deserializeUnsigned(r, txType[0]&TxBalanceInputsFlag != 0)
 }
 
 func (tx *Transaction) DeserializeUnsignedWithoutType(r io.Reader) error {
+	return tx.deserializeUnsigned(r, false)
+}
+
+func (tx *Transaction) deserializeUnsigned(r io.Reader, hasBalanceInputs bool) error {
 	var payloadVersion [1]byte
 	_, err := io.ReadFull(r, payloadVersion[:])
 	tx.PayloadVersion = payloadVersion[0]
@@ -246,6 +293,8 @@ func (tx *Transaction) DeserializeUnsignedWithoutType(r io.Reader) error {
 		tx.Payload = new(payload.DeployCode)
 	case SideMining:
 		tx.Payload = new(payload.SideMining)
+	case IssueAsset:
+		tx.Payload = new(payload.IssueAsset)
 	default:
 		return errors.New("[Transaction], invalid transaction type.")
 	}
@@ -283,7 +332,24 @@ func (tx *Transaction) DeserializeUnsignedWithoutType(r io.Reader) error {
 			tx.UTXOInputs = append(tx.UTXOInputs, utxo)
 		}
 	}
-	//TODO balanceInputs
+	//BalanceInputs
+	if hasBalanceInputs {
+		Len, err = serialization.ReadVarUint(r, 0)
+		if err != nil {
+			return err
+		}
+		if Len == uint64(0) {
+			return errors.New("[Transaction], balance inputs flag without balance inputs.")
+		}
+		for i := uint64(0); i < Len; i++ {
+			input := new(BalanceTxInput)
+			err = input.Deserialize(r)
+			if err != nil {
+				return err
+			}
+			tx.BalanceInputs = append(tx.BalanceInputs, input)
+		}
+	}
 	//Outputs
 	Len, err = serialization.ReadVarUint(r, 0)
 	if err != nil {
@@ -328,13 +394,19 @@ func (tx *Transaction) GetFee(assetID Uint256) int64 {
 }
 
 func (tx *Transaction) GetProgramHashes() ([]Uint168, error) {
+	return tx.GetProgramHashesFrom(TxStore)
+}
+
+// GetProgramHashesFrom returns the program hashes which must sign the
+// transaction, the referenced outputs are looked up in store.
+func (tx *Transaction) GetProgramHashesFrom(store ILedgerStore) ([]Uint168, error) {
 	if tx == nil {
 		return []Uint168{}, errors.New("[Transaction],GetProgramHashes transaction is nil.")
 	}
 	hashs := []Uint168{}
 	uniqHashes := []Uint168{}
 	// add inputUTXO's transaction
-	referenceWithUTXO_Output, err := tx.GetReference()
+	referenceWithUTXO_Output, err := tx.GetReferenceFrom(store)
 	if err != nil {
 		return nil, errors.New("[Transaction], GetProgramHashes failed.")
 	}
@@ -342,6 +414,10 @@ func (tx *Transaction) GetProgramHashes() ([]Uint168, error) {
 		programHash := output.ProgramHash
 		hashs = append(hashs, programHash)
 	}
+	// add balance inputs' program hashes
+	for _, input := range tx.BalanceInputs {
+		hashs = append(hashs, input.ProgramHash)
+	}
 	for _, attribute := range tx.Attributes {
 		if attribute.Usage == Script {
 			dataHash, err := Uint168FromBytes(attribute.Data)
@@ -357,6 +433,26 @@ func (tx *Transaction) GetProgramHashes() ([]Uint168, error) {
 	case Record:
 	case Deploy:
 	case SideMining:
+	case IssueAsset:
+		// the controllers of the issued assets
+		results, err := tx.GetTransactionResultsFrom(store)
+		if err != nil {
+			return nil, errors.New("[Transaction], GetProgramHashes failed.")
+		}
+		for assetID, amount := range results {
+			if amount >= 0 {
+				continue
+			}
+			registerTx, _, err := store.GetTransaction(assetID)
+			if err != nil {
+				return nil, errors.New("[Transaction], GetProgramHashes issued asset not found.")
+			}
+			registerPayload, ok := registerTx.Payload.(*payload.RegisterAsset)
+			if !ok {
+				return nil, errors.New("[Transaction], GetProgramHashes issued asset is not registered.")
+			}
+			hashs = append(hashs, registerPayload.Controller)
+		}
 	default:
 	}
 
@@ -412,14 +508,17 @@ func (tx *Transaction) SetHash(hash Uint256) {
 }
 
 func (tx *Transaction) GetReference() (map[*UTXOTxInput]*TxOutput, error) {
-	if tx.TxType == RegisterAsset {
-		return nil, nil
-	}
+	return tx.GetReferenceFrom(TxStore)
+}
+
+// GetReferenceFrom returns the outputs spent by the transaction inputs, the
+// referenced transactions are looked up in store.
+func (tx *Transaction) GetReferenceFrom(store ILedgerStore) (map[*UTXOTxInput]*TxOutput, error) {
 	//UTXO input /  Outputs
 	reference := make(map[*UTXOTxInput]*TxOutput)
 	// Key index，v UTXOInput
 	for _, utxo := range tx.UTXOInputs {
-		transaction, _, err := TxStore.GetTransaction(utxo.ReferTxID)
+		transaction, _, err := store.GetTransaction(utxo.ReferTxID)
 		if err != nil {
 			return nil, errors.New("[Transaction], GetReference failed.")
 		}
@@ -432,9 +531,15 @@ func (tx *Transaction) GetReference() (map[*UTXOTxInput]*TxOutput, error) {
 	return reference, nil
 }
 func (tx *Transaction) GetTransactionResults() (TransactionResult, error) {
+	return tx.GetTransactionResultsFrom(TxStore)
+}
+
+// GetTransactionResultsFrom returns the inputs minus the outputs of each
+// asset, the referenced outputs are looked up in store.
+func (tx *Transaction) GetTransactionResultsFrom(store ILedgerStore) (TransactionResult, error) {
 	result := make(map[Uint256]Fixed64)
 	outputResult := tx.GetMergedAssetIDValueFromOutputs()
-	InputResult, err := tx.GetMergedAssetIDValueFromReference()
+	InputResult, err := tx.getMergedAssetIDValueFromReference(store)
 	if err != nil {
 		return nil, err
 	}
@@ -468,7 +573,11 @@ func (tx *Transaction) GetMergedAssetIDValueFromOutputs() TransactionResult {
 }
 
 func (tx *Transaction) GetMergedAssetIDValueFromReference() (TransactionResult, error) {
-	reference, err := tx.GetReference()
+	return tx.getMergedAssetIDValueFromReference(TxStore)
+}
+
+func (tx *Transaction) getMergedAssetIDValueFromReference(store ILedgerStore) (TransactionResult, error) {
+	reference, err := tx.GetReferenceFrom(store)
 	if err != nil {
 		return nil, err
 	}
@@ -481,6 +590,9 @@ func (tx *Transaction) GetMergedAssetIDValueFromReference() (TransactionResult,
 			result[v.AssetID] = v.Value
 		}
 	}
+	for _, v := range tx.BalanceInputs {
+		result[v.AssetID] += v.Value
+	}
 	return result, nil
 }
 
@@ -497,6 +609,10 @@ func (tx *Transaction) GetMultiSignPublicKeys() ([][]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	return getMultiSignPublicKeys(code)
+}
+
+func getMultiSignPublicKeys(code []byte) ([][]byte, error) {
 	if len(code) < MinMultiSignCodeLength || code[len(code)-1] != MULTISIG {
 		return nil, errors.New("not a valid multi sign transaction code, length not enough")
 	}
@@ -526,6 +642,10 @@ func (tx *Transaction) GetTransactionType() (byte, error) {
 	if err != nil {
 		return 0, err
 	}
+	return getCodeType(code)
+}
+
+func getCodeType(code []byte) (byte, error) {
 	if len(code) != PublicKeyScriptLength && len(code) < MinMultiSignCodeLength {
 		return 0, errors.New("invalid transaction type, redeem script not a standard or multi sign type")
 	}
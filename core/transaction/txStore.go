@@ -7,5 +7,5 @@ import (
 // ILedgerStore provides func with store package.
 type ILedgerStore interface {
 	GetTransaction(hash Uint256) (*Transaction, uint32, error)
-	//GetQuantityIssued(AssetId Uint256) (Fixed64, error)
+	GetQuantityIssued(assetID Uint256) (Fixed64, error)
 }
@@ -3,114 +3,321 @@ package transaction
 import (
 	"errors"
 
-	"Elastos.ELA/crypto"
+	. "Elastos.ELA/common"
+	"Elastos.ELA/core/contract"
 	. "Elastos.ELA/core/signature"
+	"Elastos.ELA/crypto"
 )
 
-func VerifySignature(txn *Transaction) (bool, error) {
-	hashes, err := txn.GetProgramHashes()
+// VerifyFlags are the rules programs are verified with, they depend on the
+// deployments active in the block of the transaction.
+type VerifyFlags uint32
+
+const (
+	// VerifyScripts runs the programs through the script engine, only
+	// single and multi signature codes are verified without it.
+	VerifyScripts VerifyFlags = 1 << iota
+
+	// VerifySigHashTypes accepts the signatures with a hash type in the
+	// programs run through the script engine.
+	VerifySigHashTypes
+
+	// VerifySequenceLocks takes the final sequence of the inputs checking
+	// lock times to be the one of active sequence locks.
+	VerifySequenceLocks
+)
+
+func VerifySignature(txn *Transaction, flags VerifyFlags) (bool, error) {
+	return VerifySignatureFrom(txn, TxStore, flags)
+}
+
+// VerifySignatureFrom verifies the programs of the transaction against the
+// outputs it spends, which are looked up in store.
+func VerifySignatureFrom(txn *Transaction, store ILedgerStore, flags VerifyFlags) (bool, error) {
+	jobs, err := newSignatureJobs(txn, store, flags)
 	if err != nil {
 		return false, err
 	}
+	if err := runSignatureJobs(jobs); err != nil {
+		return false, err.err
+	}
+
+	return true, nil
+}
+
+// VerifySignaturesFrom verifies the programs of the transactions as one
+// batch. It returns the index of a transaction failing the verification
+// together with the error, or -1.
+func VerifySignaturesFrom(txns []*Transaction, store ILedgerStore, flags VerifyFlags) (int, error) {
+	var jobs []*signatureJob
+	for i, txn := range txns {
+		txJobs, err := newSignatureJobs(txn, store, flags)
+		if err != nil {
+			return i, err
+		}
+		jobs = append(jobs, txJobs...)
+	}
+	if err := runSignatureJobs(jobs); err != nil {
+		for i, txn := range txns {
+			if txn == err.txn {
+				return i, err.err
+			}
+		}
+		return -1, err.err
+	}
+
+	return -1, nil
+}
+
+// newSignatureJobs checks the programs of the transaction match the program
+// hashes it has to be signed by, and returns a job verifying each of them.
+func newSignatureJobs(txn *Transaction, store ILedgerStore, flags VerifyFlags) ([]*signatureJob, error) {
+	hashes, err := txn.GetProgramHashesFrom(store)
+	if err != nil {
+		return nil, err
+	}
 
 	programs := txn.GetPrograms()
 	Length := len(hashes)
 	if Length != len(programs) {
-		return false, errors.New("The number of data hashes is different with number of programs.")
+		return nil, errors.New("The number of data hashes is different with number of programs.")
 	}
 
-	programs = txn.GetPrograms()
+	references, err := txn.GetReferenceFrom(store)
+	if err != nil {
+		return nil, err
+	}
+	inputs := programInputIndexes(txn, references)
+	sighashTxn := &SighashTransaction{Transaction: txn, References: references}
 
+	txHash := txn.Hash()
+	content := txn.GetDataContent()
+	jobs := make([]*signatureJob, 0, len(programs))
 	for i := 0; i < len(programs); i++ {
-
-		code := programs[i].Code
-		param := programs[i].Parameter
-
-		programHash, err := ToProgramHash(code)
+		programHash, err := ToProgramHash(programs[i].Code)
 		if err != nil {
-			return false, err
+			return nil, err
 		}
 
 		if hashes[i] != programHash {
-			return false, errors.New("The data hashes is different with corresponding program code.")
+			return nil, errors.New("The data hashes is different with corresponding program code.")
 		}
-		// Get transaction type
-		signType, err := txn.GetTransactionType()
-		if err != nil {
-			return false, err
+
+		input, ok := inputs[programHash]
+		if !ok {
+			input = programInputs{first: -1}
 		}
-		if signType == STANDARD {
-			// Remove length byte and sign type byte
-			publicKeyBytes := code[1:len(code)-1]
-			content := txn.GetDataContent()
-			// Remove length byte
-			signature := param[1:]
+		jobs = append(jobs, &signatureJob{
+			txn:        sighashTxn,
+			txHash:     txHash,
+			index:      i,
+			inputIndex: input.first,
+			inputCount: input.count,
+			content:    content,
+			flags:      flags,
+		})
+	}
+
+	return jobs, nil
+}
+
+// programInputs are the inputs of a transaction spending the outputs of a
+// program hash.
+type programInputs struct {
+	// first is the index of the first input, the input signatures with a
+	// hash type sign for
+	first int
+	count int
+}
+
+// programInputIndexes returns the inputs spending the outputs of each
+// program hash. Programs of signers without an input sign for input -1.
+func programInputIndexes(txn *Transaction, references map[*UTXOTxInput]*TxOutput) map[Uint168]programInputs {
+	inputs := make(map[Uint168]programInputs)
+	for i, input := range txn.UTXOInputs {
+		output, ok := references[input]
+		if !ok {
+			continue
+		}
+		programInput, ok := inputs[output.ProgramHash]
+		if !ok {
+			programInput.first = i
+		}
+		programInput.count++
+		inputs[output.ProgramHash] = programInput
+	}
+
+	return inputs
+}
+
+// verifyProgram runs the parameter and the code of a program, the
+// signatures and lock times it checks are checked against the transaction.
+// Signatures with a hash type sign for the input at inputIndex, the first
+// of the inputCount inputs of the signer. Without VerifyScripts the program
+// is verified as a single or multi signature program.
+func verifyProgram(txn *SighashTransaction, inputIndex, inputCount int, code, param, content []byte, flags VerifyFlags) error {
+	if flags&VerifyScripts == 0 {
+		return verifySignatureProgram(code, param, content)
+	}
+
+	checker := &txSignatureChecker{
+		txn:        txn,
+		inputIndex: inputIndex,
+		inputCount: inputCount,
+		content:    content,
+		flags:      flags,
+		digests:    make(map[byte]Uint256),
+	}
+	return contract.ExecuteProgram(code, param, checker)
+}
+
+// txSignatureChecker checks the signatures and lock times of a program of
+// a transaction.
+type txSignatureChecker struct {
+	txn        *SighashTransaction
+	inputIndex int
+	inputCount int
+	content    []byte
+	flags      VerifyFlags
 
-			return checkStandardSignature(publicKeyBytes, content, signature)
+	// the signature hash of each hash type checked so far
+	digests map[byte]Uint256
+}
 
-		} else if signType == MULTISIG {
-			publicKeys, err := txn.GetMultiSignPublicKeys()
+// CheckSignature verifies a signature of the whole unsigned transaction
+// data, or with a hash type appended, of its signature hash. Hash types
+// are accepted with VerifySigHashTypes. A hash type other than SIGHASH_ALL
+// only commits to the input at inputIndex of the signer, it is rejected if
+// the signer spends more than one input.
+func (c *txSignatureChecker) CheckSignature(publicKey, signature []byte) bool {
+	signature, hashType, err := SplitSignature(signature)
+	if err != nil {
+		return false
+	}
+	if hashType != 0 && c.flags&VerifySigHashTypes == 0 {
+		return false
+	}
+	if hashType != 0 && hashType != SIGHASH_ALL && c.inputCount > 1 {
+		return false
+	}
+	data := c.content
+	if hashType != 0 {
+		digest, ok := c.digests[hashType]
+		if !ok {
+			digest, err = SignatureHash(c.txn, c.inputIndex, hashType)
 			if err != nil {
-				return false, err
+				return false
 			}
-			return checkMultiSignSignatures(code, param, txn.GetDataContent(), publicKeys)
+			c.digests[hashType] = digest
+		}
+		data = digest[:]
+	}
+
+	pubKey, err := crypto.DecodePoint(publicKey)
+	if err != nil {
+		return false
+	}
+	return crypto.Verify(*pubKey, data, signature) == nil
+}
 
-		} else {
-			return false, errors.New("unknown signature type")
+// CheckLockTime requires the lock time of the transaction to be of the
+// same kind, height or time, and not below lockTime. The lock time of the
+// transaction is only enforced when one of its inputs is not final, as
+// given by FinalSequence.
+func (c *txSignatureChecker) CheckLockTime(lockTime uint32) bool {
+	if (lockTime < LockTimeThreshold) != (c.txn.LockTime < LockTimeThreshold) {
+		return false
+	}
+	if lockTime > c.txn.LockTime {
+		return false
+	}
+	finalSequence := FinalSequence(c.flags&VerifySequenceLocks != 0)
+	for _, input := range c.txn.UTXOInputs {
+		if input.Sequence != finalSequence {
+			return true
 		}
 	}
+	return false
+}
 
-	return true, nil
+// verifySignatureProgram verifies the signatures in the parameter of a
+// single or multi signature program against the public keys in its code.
+func verifySignatureProgram(code, param, content []byte) error {
+	signType, err := getCodeType(code)
+	if err != nil {
+		return err
+	}
+	if signType == STANDARD {
+		// Remove length byte and sign type byte
+		publicKeyBytes := code[1 : len(code)-1]
+		// Remove length byte
+		if len(param) < 1 {
+			return errors.New("invalid signature parameter")
+		}
+		signature := param[1:]
+
+		return checkStandardSignature(publicKeyBytes, content, signature)
+
+	} else if signType == MULTISIG {
+		publicKeys, err := getMultiSignPublicKeys(code)
+		if err != nil {
+			return err
+		}
+		return checkMultiSignSignatures(code, param, content, publicKeys)
+
+	} else {
+		return errors.New("unknown signature type")
+	}
 }
 
-func checkStandardSignature(publicKeyBytes, content, signature []byte) (bool, error) {
+func checkStandardSignature(publicKeyBytes, content, signature []byte) error {
 	publicKey, err := crypto.DecodePoint(publicKeyBytes)
 	if err != nil {
-		return false, err
-	}
-	err = crypto.Verify(*publicKey, content, signature)
-	if err == nil {
-		return false, err
+		return err
 	}
-	return true, nil
+	return crypto.Verify(*publicKey, content, signature)
 }
 
-func checkMultiSignSignatures(code, param, content []byte, publicKeys [][]byte) (bool, error) {
+func checkMultiSignSignatures(code, param, content []byte, publicKeys [][]byte) error {
 	// Get N parameter
 	n := int(code[len(code)-2]) - PUSH1 + 1
 	// Get M parameter
 	m := int(code[0]) - PUSH1 + 1
 	if m < 1 || m > n {
-		return false, errors.New("invalid multi sign script code")
+		return errors.New("invalid multi sign script code")
 	}
 	if len(publicKeys) != n {
-		return false, errors.New("invalid multi sign public key script count")
+		return errors.New("invalid multi sign public key script count")
+	}
+	if len(param)%SignatureScriptLength != 0 {
+		return errors.New("invalid multi sign signature parameter")
+	}
+
+	pubKeys := make([]*crypto.PubKey, 0, len(publicKeys))
+	for _, publicKey := range publicKeys {
+		pubKey, err := crypto.DecodePoint(publicKey[1:])
+		if err != nil {
+			return err
+		}
+		pubKeys = append(pubKeys, pubKey)
 	}
 
 	signatureCount := 0
 	for i := 0; i < len(param); i += SignatureScriptLength {
 		// Remove length byte
-		sign := param[i:i+SignatureScriptLength][1:]
-		// Get signature index, if signature exists index will not be -1
-		index := -1
-		for i, publicKey := range publicKeys {
-			pubKey, err := crypto.DecodePoint(publicKey[1:])
-			if err != nil {
-				return false, err
-			}
-			err = crypto.Verify(*pubKey, content, sign)
-			if err == nil {
-				index = i
+		sign := param[i : i+SignatureScriptLength][1:]
+		// A signature is counted once it verifies against one of the keys
+		for _, pubKey := range pubKeys {
+			if err := crypto.Verify(*pubKey, content, sign); err == nil {
+				signatureCount++
+				break
 			}
 		}
-		if index != -1 {
-			signatureCount++
-		}
 	}
 	// Check signature count
 	if signatureCount != m {
-		return false, errors.New("invalid signature count")
+		return errors.New("invalid signature count")
 	}
 
-	return true, nil
+	return nil
 }
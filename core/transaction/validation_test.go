@@ -0,0 +1,120 @@
+package transaction
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	. "Elastos.ELA/common"
+	"Elastos.ELA/core/contract"
+	"Elastos.ELA/core/contract/program"
+	. "Elastos.ELA/core/signature"
+	"Elastos.ELA/core/transaction/payload"
+)
+
+func newTestTransaction(lockTime uint32, sequences ...uint32) *Transaction {
+	txn := &Transaction{
+		TxType:   TransferAsset,
+		Payload:  &payload.TransferAsset{},
+		LockTime: lockTime,
+	}
+	for i, sequence := range sequences {
+		txn.UTXOInputs = append(txn.UTXOInputs, &UTXOTxInput{
+			ReferTxID:          Uint256{byte(i + 1)},
+			ReferTxOutputIndex: uint16(i),
+			Sequence:           sequence,
+		})
+	}
+	return txn
+}
+
+// lockTimeCode is <lockTime> CHECKLOCKTIMEVERIFY DROP 1, the lock time is
+// pushed as a 5 byte number.
+func lockTimeCode(lockTime uint32) []byte {
+	code := []byte{5, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(code[1:], lockTime)
+	return append(code, contract.OP_CHECKLOCKTIMEVERIFY, contract.OP_DROP, contract.OP_1)
+}
+
+func TestCheckLockTimeVerify(t *testing.T) {
+	const (
+		height = 1000
+		time   = LockTimeThreshold + 1000
+	)
+	tests := []struct {
+		name          string
+		txn           *Transaction
+		lockTime      uint32
+		sequenceLocks bool
+		valid         bool
+	}{
+		{"height reached", newTestTransaction(height, 0), height, true, true},
+		{"height below", newTestTransaction(height, 0), height - 1, true, true},
+		{"height not reached", newTestTransaction(height, 0), height + 1, true, false},
+		{"time reached", newTestTransaction(time, 0), time, true, true},
+		{"time not reached", newTestTransaction(time, 0), time + 1, true, false},
+		{"height against time", newTestTransaction(time, 0), height, true, false},
+		{"time against height", newTestTransaction(height, 0), LockTimeThreshold, true, false},
+		{"one input not final", newTestTransaction(height, SequenceFinal, SequenceFinal-1), height, true, true},
+		{"all inputs final", newTestTransaction(height, SequenceFinal, SequenceFinal), height, true, false},
+		{"old final sequences", newTestTransaction(height, math.MaxUint16, math.MaxUint16), height, true, true},
+		{"no input", newTestTransaction(height), height, true, false},
+		// the final sequence is math.MaxUint16 before sequence locks
+		{"old final sequences before sequence locks", newTestTransaction(height, math.MaxUint16, math.MaxUint16),
+			height, false, false},
+		{"final sequences before sequence locks", newTestTransaction(height, SequenceFinal, SequenceFinal),
+			height, false, true},
+	}
+	for _, test := range tests {
+		txn := test.txn
+		flags := VerifyScripts
+		if test.sequenceLocks {
+			flags |= VerifySequenceLocks
+		}
+		err := verifyProgram(&SighashTransaction{Transaction: txn}, 0, 1, lockTimeCode(test.lockTime), nil,
+			txn.GetDataContent(), flags)
+		if test.valid && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: lock time %d passed", test.name, test.lockTime)
+		}
+	}
+}
+
+func TestVerifyWithoutScripts(t *testing.T) {
+	signer := newTestSigner(t)
+	txn := newSighashTransaction()
+	content := txn.GetDataContent()
+
+	// signature programs are verified as before the script engine
+	param := pushSignature(signer.sign(t, content))
+	if err := verifyProgram(txn, 0, 1, signer.code, param, content, 0); err != nil {
+		t.Errorf("64 byte signature: %v", err)
+	}
+	digest, err := SignatureHash(txn, 0, SIGHASH_ALL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	param = pushSignature(append(signer.sign(t, digest[:]), SIGHASH_ALL))
+	if err := verifyProgram(txn, 0, 1, signer.code, param, content, 0); err == nil {
+		t.Error("signature with a hash type passed")
+	}
+
+	// other codes are not
+	if err := verifyProgram(txn, 0, 1, lockTimeCode(txn.LockTime), nil, content, 0); err == nil {
+		t.Error("lock time program passed")
+	}
+
+	// a program passing with the script engine is verified again without
+	// it, the signature cache is kept by flags
+	txn.Programs = []*program.Program{{Code: lockTimeCode(txn.LockTime)}}
+	job := &signatureJob{txn: txn, txHash: txn.Hash(), inputCount: 1, content: content, flags: VerifyScripts}
+	if err := job.run(); err != nil {
+		t.Fatalf("lock time program with the script engine: %v", err.err)
+	}
+	job.flags = 0
+	if err := job.run(); err == nil {
+		t.Error("lock time program passed from the signature cache")
+	}
+}
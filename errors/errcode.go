@@ -20,17 +20,28 @@ const (
 	ErrInvalidReferedTxn    ErrCode = 45017
 	ErrIneffectiveCoinbase  ErrCode = 45018
 	ErrUTXOLocked           ErrCode = 45019
+	ErrCoinbaseOverpay      ErrCode = 45020
+	ErrFoundationReward     ErrCode = 45021
+	ErrTxNotFinalized       ErrCode = 45022
+	ErrSequenceLocked       ErrCode = 45023
+	ErrBalanceInput         ErrCode = 45024
+	ErrAssetIssuance        ErrCode = 45025
+	ErrDuplicateContract    ErrCode = 45026
+	ErrInvalidContract      ErrCode = 45027
 	SessionExpired          ErrCode = 41001
 	IllegalDataFormat       ErrCode = 41003
 	OauthTimeout            ErrCode = 41004
 	InvalidMethod           ErrCode = 42001
 	InvalidParams           ErrCode = 42002
 	InvalidToken            ErrCode = 42003
+	MethodForbidden         ErrCode = 42004
 	InvalidTransaction      ErrCode = 43001
 	InvalidAsset            ErrCode = 43002
 	UnknownTransaction      ErrCode = 44001
 	UnknownAsset            ErrCode = 44002
 	UnknownBlock            ErrCode = 44003
+	BlockPruned             ErrCode = 44004
+	UnknownContract         ErrCode = 44005
 	InternalError           ErrCode = 45002
 )
 
@@ -43,11 +54,14 @@ var ErrMap = map[ErrCode]string{
 	InvalidMethod:           "Invalid method",
 	InvalidParams:           "Invalid Params",
 	InvalidToken:            "Verify token error",
+	MethodForbidden:         "Method only allowed from localhost",
 	InvalidTransaction:      "Invalid transaction",
 	InvalidAsset:            "Invalid asset",
 	UnknownTransaction:      "Unknown Transaction",
 	UnknownAsset:            "Unknown asset",
 	UnknownBlock:            "Unknown Block",
+	BlockPruned:             "Block pruned",
+	UnknownContract:         "Unknown contract",
 	InternalError:           "Internal error",
 	ErrInvalidInput:         "INTERNAL ERROR, ErrInvalidInput",
 	ErrInvalidOutput:        "INTERNAL ERROR, ErrInvalidOutput",
@@ -63,6 +77,14 @@ var ErrMap = map[ErrCode]string{
 	ErrUnknownReferedTxn:    "INTERNAL ERROR, ErrUnknownReferedTxn",
 	ErrInvalidReferedTxn:    "INTERNAL ERROR, ErrInvalidReferedTxn",
 	ErrIneffectiveCoinbase:  "INTERNAL ERROR, ErrIneffectiveCoinbase",
+	ErrCoinbaseOverpay:      "INTERNAL ERROR, ErrCoinbaseOverpay",
+	ErrFoundationReward:     "INTERNAL ERROR, ErrFoundationReward",
+	ErrTxNotFinalized:       "INTERNAL ERROR, ErrTxNotFinalized",
+	ErrSequenceLocked:       "INTERNAL ERROR, ErrSequenceLocked",
+	ErrBalanceInput:         "INTERNAL ERROR, ErrBalanceInput",
+	ErrAssetIssuance:        "INTERNAL ERROR, ErrAssetIssuance",
+	ErrDuplicateContract:    "INTERNAL ERROR, ErrDuplicateContract",
+	ErrInvalidContract:      "INTERNAL ERROR, ErrInvalidContract",
 }
 
 func (code ErrCode) Message() string {
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 	"runtime"
+	"strconv"
 	"time"
 
+	"Elastos.ELA/common"
 	"Elastos.ELA/common/config"
 	"Elastos.ELA/common/log"
 	"Elastos.ELA/consensus/pow"
@@ -24,9 +29,16 @@ const (
 	DefaultMultiCoreNum = 4
 )
 
+var coreNum int
+
+var (
+	reindex           = flag.Bool("reindex", false, "rebuild the indexes and asset records from the stored blocks")
+	reindexChainState = flag.Bool("reindex-chainstate", false, "rebuild the UTXO set from the stored blocks")
+	loadTxOutSet      = flag.String("loadtxoutset", "", "bootstrap an empty node from a UTXO snapshot file")
+)
+
 func init() {
 	log.Init(log.Path, log.Stdout)
-	var coreNum int
 	if config.Parameters.MultiCoreNum > DefaultMultiCoreNum {
 		coreNum = int(config.Parameters.MultiCoreNum)
 	} else {
@@ -55,6 +67,24 @@ func handleLogFile() {
 
 }
 
+// rollbackChain is the rollback subcommand, run with the node stopped:
+// node rollback <height|block hash>
+func rollbackChain(target string) error {
+	bc := ledger.DefaultLedger.Blockchain
+	if height, err := strconv.ParseUint(target, 10, 32); err == nil {
+		return bc.RollbackTo(uint32(height))
+	}
+	hex, err := common.HexStringToBytesReverse(target)
+	if err != nil {
+		return errors.New("usage: node rollback <height|block hash>")
+	}
+	hash, err := common.Uint256ParseFromBytes(hex)
+	if err != nil {
+		return errors.New("usage: node rollback <height|block hash>")
+	}
+	return bc.RollbackToBlock(hash)
+}
+
 func startConsensus(noder protocol.Noder) {
 	servers.LocalPow = pow.NewPowService("logPow", noder)
 	if config.Parameters.PowConfiguration.AutoMining {
@@ -67,23 +97,79 @@ func main() {
 	//var blockChain *ledger.Blockchain
 	var err error
 	var noder protocol.Noder
+	flag.Parse()
 	log.Trace("Node version: ", config.Version)
 	log.Info("1. BlockChain init")
 	ledger.DefaultLedger = new(ledger.Ledger)
+	command := flag.Arg(0)
+	if command != "" && command != "rollback" {
+		fmt.Fprintln(os.Stderr, "unknown command", command)
+		os.Exit(1)
+	}
+	if command == "rollback" && config.Parameters.StoreBackend == "memory" {
+		fmt.Fprintln(os.Stderr, "rollback needs a persistent store backend")
+		os.Exit(1)
+	}
 	ledger.DefaultLedger.Store, err = ChainStore.NewLedgerStore()
 	if err != nil {
+		if command == "rollback" {
+			fmt.Fprintln(os.Stderr, "rollback needs exclusive access to the chain database, stop the running node first:", err)
+			os.Exit(1)
+		}
 		log.Fatal("open LedgerStore err:", err)
 		os.Exit(1)
 	}
 	defer ledger.DefaultLedger.Store.Close()
+	if *loadTxOutSet != "" {
+		if err = ChainStore.LoadUTXOSnapshot(ledger.DefaultLedger.Store, *loadTxOutSet); err != nil {
+			log.Fatal("load UTXO snapshot err:", err)
+			goto ERROR
+		}
+	}
 
-	ledger.DefaultLedger.Store.InitLedgerStore(ledger.DefaultLedger)
+	err = ledger.DefaultLedger.Store.InitLedgerStore(ledger.DefaultLedger)
+	if err != nil {
+		log.Fatal("init LedgerStore err:", err)
+		goto ERROR
+	}
+	if *reindex || *reindexChainState {
+		mode := ChainStore.ReindexFull
+		if !*reindex {
+			mode = ChainStore.ReindexChainState
+		}
+		if err = ChainStore.RequestReindex(ledger.DefaultLedger.Store, mode); err != nil {
+			log.Fatal("request reindex err:", err)
+			goto ERROR
+		}
+	}
 	transaction.TxStore = ledger.DefaultLedger.Store
+	transaction.StartSignatureVerifier(coreNum)
 	_, err = ledger.NewBlockchainWithGenesisBlock()
 	if err != nil {
 		log.Fatal(err, "BlockChain generate failed")
 		goto ERROR
 	}
+	if command == "rollback" {
+		if err = rollbackChain(flag.Arg(1)); err != nil {
+			// os.Exit skips the deferred close
+			ledger.DefaultLedger.Store.Close()
+			fmt.Fprintln(os.Stderr, "rollback failed:", err)
+			os.Exit(1)
+		}
+		log.Info("Rolled back to height ", ledger.DefaultLedger.Blockchain.GetBestHeight())
+		return
+	}
+	if depth := config.Parameters.VerifyChainDepth; depth > 0 {
+		issues, err := ledger.DefaultLedger.Store.VerifyChain(depth)
+		if err != nil {
+			log.Fatal("verify chain err:", err)
+			goto ERROR
+		}
+		if len(issues) > 0 {
+			log.Fatal("Corrupted chain database detected, restart with -reindex to rebuild it")
+			goto ERROR
+		}
+	}
 
 	log.Info("2. Start the P2P networks")
 	noder = node.InitNode()
@@ -53,6 +53,11 @@ func (msg block) Handle(node Noder) error {
 		}
 	}
 
+	// Move the request window of a headers first sync.
+	if node.LocalNode().IsSyncHeaders() {
+		ReqBlksInWindow(node.LocalNode())
+	}
+
 	if isOrphan == true && node.LocalNode().IsSyncHeaders() == false {
 		if !node.LocalNode().RequestedBlockExisted(hash) {
 			orphanRoot := ledger.DefaultLedger.Blockchain.GetOrphanRoot(&hash)
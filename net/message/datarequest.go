@@ -20,6 +20,12 @@ type dataReq struct {
 func (msg dataReq) Handle(node Noder) error {
 	hash := msg.hash
 	block, err := NewBlockFromHash(hash)
+	if err == ledger.ErrBlockPruned {
+		log.Debugf("Block %x is pruned, send not found message", hash.ToArrayReverse())
+		b, err := NewNotFound(hash)
+		node.Tx(b)
+		return err
+	}
 	if err != nil {
 		log.Debug("Can't get block from hash: ", hash, " ,send not found message")
 		//call notfound message
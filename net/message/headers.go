@@ -0,0 +1,175 @@
+package message
+
+import (
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/config"
+	"Elastos.ELA/common/log"
+	"Elastos.ELA/common/serialization"
+	"Elastos.ELA/core/ledger"
+	. "Elastos.ELA/net/protocol"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+type headers struct {
+	messageHeader
+	cnt     uint32
+	headers []*ledger.Blockdata
+}
+
+func NewHeaders(hdrs []*ledger.Blockdata) ([]byte, error) {
+	var msg headers
+	msg.headers = hdrs
+	msg.cnt = uint32(len(hdrs))
+	msg.Magic = config.Parameters.Magic
+	cmd := "headers"
+	copy(msg.CMD[0:len(cmd)], cmd)
+	tmpBuffer := bytes.NewBuffer([]byte{})
+	serialization.WriteUint32(tmpBuffer, msg.cnt)
+	for _, header := range hdrs {
+		header.Serialize(tmpBuffer)
+	}
+
+	p := new(bytes.Buffer)
+	err := binary.Write(p, binary.LittleEndian, tmpBuffer.Bytes())
+	if err != nil {
+		log.Error("Binary Write failed at new Msg")
+		return nil, err
+	}
+	s := sha256.Sum256(p.Bytes())
+	s2 := s[:]
+	s = sha256.Sum256(s2)
+	buf := bytes.NewBuffer(s[:4])
+	binary.Read(buf, binary.LittleEndian, &(msg.Checksum))
+	msg.Length = uint32(len(p.Bytes()))
+	log.Debug("The message payload length is ", msg.Length)
+
+	m, err := msg.Serialization()
+	if err != nil {
+		log.Error("Error Convert net message ", err.Error())
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (msg headers) Handle(node Noder) error {
+	log.Debug()
+	if node.LocalNode().IsNeighborNoder(node) == false {
+		log.Trace("received headers message from unknown peer")
+		return errors.New("received headers message from unknown peer")
+	}
+
+	bc := ledger.DefaultLedger.Blockchain
+	if err := bc.HeaderChain.ProcessHeaders(msg.headers, bc.TimeSource); err != nil {
+		log.Warn("Headers process failed: ", err)
+		node.SetSyncHeaders(false)
+		node.LocalNode().SetStartHash(Uint256{})
+		bc.HeaderChain.Reset(bc.BestChain)
+		return err
+	}
+	log.Infof("Header chain height %d, block height %d", bc.HeaderChain.Height(), bc.GetBestHeight())
+
+	// A full batch means the peer has more headers to send.
+	if msg.cnt == MAXBLKHDRCNT {
+		SendMsgSyncHeaders(node, bc.HeaderLocator())
+	}
+
+	ReqBlksInWindow(node.LocalNode())
+	return nil
+}
+
+// ReqBlksInWindow requests the blocks of the header chain which are within
+// BLKREQWINDOW of the best block. The requests are spread over the
+// neighbors having the blocks, a request which is not answered in time is
+// sent again to the next neighbor.
+func ReqBlksInWindow(local Noder) {
+	bc := ledger.DefaultLedger.Blockchain
+	var peers []Noder
+	for _, n := range local.GetNeighborNoder() {
+		if n.GetState() == Establish && !n.IsSyncFailed() {
+			peers = append(peers, n)
+		}
+	}
+	if len(peers) == 0 {
+		return
+	}
+
+	start := bc.GetBestHeight() + 1
+	end := bc.HeaderChain.Height()
+	if end >= start+BLKREQWINDOW {
+		end = start + BLKREQWINDOW - 1
+	}
+
+	next := 0
+	for height := start; height <= end; height++ {
+		hash, ok := bc.HeaderChain.HashAt(height)
+		if !ok {
+			break
+		}
+		if bc.IsKnownOrphan(&hash) {
+			continue
+		}
+		if t, ok := local.RequestedBlockTime(hash); ok &&
+			t.After(time.Now().Add(-BLKREQTIMEOUT*time.Second)) {
+			continue
+		}
+
+		for i := 0; i < len(peers); i++ {
+			peer := peers[next%len(peers)]
+			next++
+			if peer.GetHeight() >= uint64(height) {
+				ReqBlkData(peer, hash)
+				break
+			}
+		}
+	}
+}
+
+func (msg headers) Serialization() ([]byte, error) {
+	hdrBuf, err := msg.messageHeader.Serialization()
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(hdrBuf)
+	err = serialization.WriteUint32(buf, msg.cnt)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range msg.headers {
+		header.Serialize(buf)
+	}
+
+	return buf.Bytes(), err
+}
+
+func (msg *headers) Deserialization(p []byte) error {
+	buf := bytes.NewBuffer(p)
+	err := binary.Read(buf, binary.LittleEndian, &(msg.messageHeader))
+	if err != nil {
+		log.Warn("Parse headers message hdr error")
+		return errors.New("Parse headers message hdr error")
+	}
+
+	msg.cnt, err = serialization.ReadUint32(buf)
+	if err != nil {
+		return err
+	}
+	if msg.cnt > MAXBLKHDRCNT {
+		return errors.New("Too many headers in headers message")
+	}
+
+	for i := uint32(0); i < msg.cnt; i++ {
+		header := new(ledger.Blockdata)
+		if err := header.Deserialize(buf); err != nil {
+			log.Warn("Parse headers message error")
+			return errors.New("Parse headers message error")
+		}
+		msg.headers = append(msg.headers, header)
+	}
+
+	return nil
+}
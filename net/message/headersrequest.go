@@ -0,0 +1,195 @@
+package message
+
+import (
+	. "Elastos.ELA/common"
+	"Elastos.ELA/common/config"
+	"Elastos.ELA/common/log"
+	"Elastos.ELA/common/serialization"
+	"Elastos.ELA/core/ledger"
+	. "Elastos.ELA/net/protocol"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+type headersReq struct {
+	messageHeader
+	p struct {
+		len       uint32
+		hashStart []Uint256
+		hashEnd   Uint256
+	}
+}
+
+func SendMsgSyncHeaders(node Noder, blocator []Uint256) {
+	if node.LocalNode().GetStartHash() == blocator[0] {
+		return
+	}
+
+	buf, err := NewHeadersReq(blocator, Uint256{})
+	if err != nil {
+		log.Error("failed build a new getheadersReq")
+	} else {
+		node.LocalNode().SetSyncHeaders(true)
+		node.SetSyncHeaders(true)
+		go node.Tx(buf)
+		node.LocalNode().SetStartHash(blocator[0])
+		node.LocalNode().SetStopHash(Uint256{})
+	}
+}
+
+func NewHeadersReq(blocator []Uint256, hash Uint256) ([]byte, error) {
+	var msg headersReq
+	msg.Magic = config.Parameters.Magic
+	cmd := "getheaders"
+	copy(msg.CMD[0:len(cmd)], cmd)
+	tmpBuffer := bytes.NewBuffer([]byte{})
+	msg.p.len = uint32(len(blocator))
+	msg.p.hashStart = blocator
+	serialization.WriteUint32(tmpBuffer, msg.p.len)
+
+	for _, hash := range blocator {
+		_, err := hash.Serialize(tmpBuffer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	msg.p.hashEnd = hash
+
+	_, err := msg.p.hashEnd.Serialize(tmpBuffer)
+	if err != nil {
+		return nil, err
+	}
+	p := new(bytes.Buffer)
+	err = binary.Write(p, binary.LittleEndian, tmpBuffer.Bytes())
+	if err != nil {
+		log.Error("Binary Write failed at new Msg")
+		return nil, err
+	}
+	s := sha256.Sum256(p.Bytes())
+	s2 := s[:]
+	s = sha256.Sum256(s2)
+	buf := bytes.NewBuffer(s[:4])
+	binary.Read(buf, binary.LittleEndian, &(msg.Checksum))
+	msg.Length = uint32(len(p.Bytes()))
+	log.Debug("The message payload length is ", msg.Length)
+
+	m, err := msg.Serialization()
+	if err != nil {
+		log.Error("Error Convert net message ", err.Error())
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (msg headersReq) Handle(node Noder) error {
+	log.Debug()
+	node.LocalNode().AcqSyncHdrReqSem()
+	defer node.LocalNode().RelSyncHdrReqSem()
+
+	startHash := ledger.DefaultLedger.Blockchain.LatestLocatorHash(msg.p.hashStart)
+	headers, err := GetHeadersFromHash(startHash, msg.p.hashEnd)
+	if err != nil {
+		return err
+	}
+	buf, err := NewHeaders(headers)
+	if err != nil {
+		return err
+	}
+	go node.Tx(buf)
+	return nil
+}
+
+// GetHeadersFromHash returns at most MAXBLKHDRCNT main chain headers after
+// startHash, up to stopHash if it is not empty.
+func GetHeadersFromHash(startHash Uint256, stopHash Uint256) ([]*ledger.Blockdata, error) {
+	var empty Uint256
+	var startHeight uint32
+	stopHeight := ledger.DefaultLedger.Store.GetHeight()
+
+	if startHash != empty {
+		bkstart, err := ledger.DefaultLedger.Store.GetHeader(startHash)
+		if err != nil {
+			return nil, err
+		}
+		startHeight = bkstart.Blockdata.Height
+	}
+	if stopHash != empty {
+		bkstop, err := ledger.DefaultLedger.Store.GetHeader(stopHash)
+		if err != nil {
+			return nil, err
+		}
+		if bkstop.Blockdata.Height < stopHeight {
+			stopHeight = bkstop.Blockdata.Height
+		}
+	}
+	if stopHeight < startHeight {
+		return nil, errors.New("do not have header to send")
+	}
+
+	count := stopHeight - startHeight
+	if count > MAXBLKHDRCNT {
+		count = MAXBLKHDRCNT
+	}
+
+	headers := make([]*ledger.Blockdata, 0, count)
+	for i := uint32(1); i <= count; i++ {
+		hash, err := ledger.DefaultLedger.Store.GetBlockHash(startHeight + i)
+		if err != nil {
+			return nil, err
+		}
+		header, err := ledger.DefaultLedger.Store.GetHeader(hash)
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, header.Blockdata)
+	}
+
+	return headers, nil
+}
+
+func (msg headersReq) Serialization() ([]byte, error) {
+	hdrBuf, err := msg.messageHeader.Serialization()
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(hdrBuf)
+	err = binary.Write(buf, binary.LittleEndian, msg.p.len)
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range msg.p.hashStart {
+		hash.Serialize(buf)
+	}
+
+	msg.p.hashEnd.Serialize(buf)
+
+	return buf.Bytes(), err
+}
+
+func (msg *headersReq) Deserialization(p []byte) error {
+	buf := bytes.NewBuffer(p)
+	err := binary.Read(buf, binary.LittleEndian, &(msg.messageHeader))
+	if err != nil {
+		return err
+	}
+
+	err = binary.Read(buf, binary.LittleEndian, &(msg.p.len))
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < int(msg.p.len); i++ {
+		var hash Uint256
+		if err := (&hash).Deserialize(buf); err != nil {
+			log.Debug("headers req Deserialization failed")
+			return err
+		}
+		msg.p.hashStart = append(msg.p.hashStart, hash)
+	}
+
+	return msg.p.hashEnd.Deserialize(buf)
+}
@@ -82,6 +82,14 @@ func AllocMsg(t string, length int) Messager {
 		var msg blocksReq
 		copy(msg.CMD[0:len(t)], t)
 		return &msg
+	case "getheaders":
+		var msg headersReq
+		copy(msg.CMD[0:len(t)], t)
+		return &msg
+	case "headers":
+		var msg headers
+		copy(msg.CMD[0:len(t)], t)
+		return &msg
 	case "notfound":
 		var msg notFound
 		copy(msg.CMD[0:len(t)], t)
@@ -40,6 +40,12 @@ func (node *node) SyncBlks() {
 			log.Info(err)
 		}
 		node.LocalNode().ResetRequestedBlock()
+		bc := ledger.DefaultLedger.Blockchain
+		if bc.HeaderChain.Height() != bc.GetBestHeight() {
+			bc.HeaderChain.Reset(bc.BestChain)
+		}
+	} else if headersNode := node.GetBestHeightHeadersNoder(); headersNode != nil {
+		node.syncHeadersFirst(headersNode)
 	} else {
 		var syncNode Noder
 		hasSyncPeer, syncNode := node.local.hasSyncPeer()
@@ -87,6 +93,41 @@ func (node *node) SyncBlks() {
 	}
 }
 
+// syncHeadersFirst downloads the header chain from one node, and the
+// blocks within the request window from all the neighbors.
+func (node *node) syncHeadersFirst(headersNode Noder) {
+	bc := ledger.DefaultLedger.Blockchain
+	hc := bc.HeaderChain
+
+	// Headers behind the best block are left from an earlier sync.
+	if hc.Height() < bc.GetBestHeight() {
+		hc.Reset(bc.BestChain)
+	}
+
+	syncNode, err := node.FindSyncNode()
+	if err != nil {
+		syncNode = headersNode
+	}
+
+	height := hc.Height()
+	if height != node.headersHeight {
+		node.headersHeight = height
+		node.headersTime = time.Now()
+	} else if uint64(height) < syncNode.GetHeight() &&
+		node.headersTime.Before(time.Now().Add(-HDRREQTIMEOUT*time.Second)) {
+		log.Info("header sync stalled, change sync node")
+		syncNode.SetSyncHeaders(false)
+		node.local.SetStartHash(common.Uint256{})
+		node.headersTime = time.Now()
+		return
+	}
+
+	if uint64(height) < syncNode.GetHeight() {
+		SendMsgSyncHeaders(syncNode, bc.HeaderLocator())
+	}
+	ReqBlksInWindow(node.local)
+}
+
 func (node *node) SendPingToNbr() {
 	noders := node.local.GetNeighborNoder()
 	for _, n := range noders {
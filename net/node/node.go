@@ -68,6 +68,8 @@ type node struct {
 	SyncHdrReqSem            Semaphore
 	StartHash                Uint256
 	StopHash                 Uint256
+	headersHeight            uint32
+	headersTime              time.Time
 }
 
 type ConnectingNodes struct {
@@ -158,6 +160,10 @@ func NewNode() *node {
 func InitNode() Noder {
 	n := NewNode()
 	n.version = PROTOCOLVERSION
+	n.services = SFNodeHeaders
+	if Parameters.PruneBlocks > 0 || Parameters.PruneSizeMB > 0 {
+		n.services |= SFNodeNetworkLimited
+	}
 
 	n.SyncBlkReqSem = MakeSemaphore(MAXSYNCHDRREQ)
 	n.SyncHdrReqSem = MakeSemaphore(MAXSYNCHDRREQ)
@@ -463,6 +469,29 @@ func (node *node) GetBestHeightNoder() Noder {
 	return bestnode
 }
 
+// GetBestHeightHeadersNoder returns the neighbor with the best height of
+// those which answer getheaders. A pruned neighbor is only chosen when it
+// still has the blocks after the local best block.
+func (node *node) GetBestHeightHeadersNoder() Noder {
+	node.nbrNodes.RLock()
+	defer node.nbrNodes.RUnlock()
+	height := uint64(ledger.DefaultLedger.Blockchain.BlockHeight)
+	var bestnode Noder
+	for _, n := range node.nbrNodes.List {
+		if n.GetState() != Establish || n.IsSyncFailed() ||
+			n.Services()&SFNodeHeaders == 0 {
+			continue
+		}
+		if n.Services()&SFNodeNetworkLimited != 0 && n.GetHeight() > height+MinPruneBlocks {
+			continue
+		}
+		if bestnode == nil || n.GetHeight() > bestnode.GetHeight() {
+			bestnode = n
+		}
+	}
+	return bestnode
+}
+
 func (node *node) GetRequestBlockList() map[Uint256]time.Time {
 	return node.RequestedBlockList
 }
@@ -480,6 +509,13 @@ func (node *node) AddRequestedBlock(hash Uint256) {
 	node.RequestedBlockList[hash] = time.Now()
 }
 
+func (node *node) RequestedBlockTime(hash Uint256) (time.Time, bool) {
+	node.requestedBlockLock.RLock()
+	defer node.requestedBlockLock.RUnlock()
+	t, ok := node.RequestedBlockList[hash]
+	return t, ok
+}
+
 func (node *node) ResetRequestedBlock() {
 	node.requestedBlockLock.Lock()
 	defer node.requestedBlockLock.Unlock()
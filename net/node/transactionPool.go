@@ -5,8 +5,10 @@ import (
 	"Elastos.ELA/common/config"
 	"Elastos.ELA/common/log"
 	"Elastos.ELA/core/ledger"
+	"Elastos.ELA/core/signature"
 	"Elastos.ELA/core/transaction"
 	tx "Elastos.ELA/core/transaction"
+	"Elastos.ELA/core/transaction/payload"
 	. "Elastos.ELA/errors"
 	"Elastos.ELA/events"
 	"bytes"
@@ -24,7 +26,10 @@ type TXNPool struct {
 	txnCnt  uint64                                      // count
 	txnList map[common.Uint256]*transaction.Transaction // transaction which have been verifyed will put into this map
 	//issueSummary  map[common.Uint256]common.Fixed64           // transaction which pass the verify will summary the amout to this map
-	inputUTXOList map[string]*transaction.Transaction // transaction which pass the verify will add the UTXO to this map
+	inputUTXOList    map[string]*transaction.Transaction         // transaction which pass the verify will add the UTXO to this map
+	inputBalanceList map[string]*transaction.Transaction         // transaction which pass the verify will add the debited balance to this map
+	issueAssetList   map[common.Uint256]*transaction.Transaction // issue transaction which pass the verify will add the issued asset to this map
+	deployCodeList   map[common.Uint168]*transaction.Transaction // deploy transaction which pass the verify will add the contract hash to this map
 }
 
 func (this *TXNPool) init() {
@@ -32,6 +37,9 @@ func (this *TXNPool) init() {
 	defer this.Unlock()
 	this.txnCnt = 0
 	this.inputUTXOList = make(map[string]*transaction.Transaction)
+	this.inputBalanceList = make(map[string]*transaction.Transaction)
+	this.issueAssetList = make(map[common.Uint256]*transaction.Transaction)
+	this.deployCodeList = make(map[common.Uint168]*transaction.Transaction)
 	//this.issueSummary = make(map[common.Uint256]common.Fixed64)
 	this.txnList = make(map[common.Uint256]*transaction.Transaction)
 }
@@ -48,6 +56,10 @@ func (this *TXNPool) AppendToTxnPool(txn *transaction.Transaction) ErrCode {
 		log.Info("Transaction verification with ledger failed", txn.Hash())
 		return errCode
 	}
+	if errCode := ledger.DefaultLedger.Blockchain.CheckTransactionFinality(txn); errCode != Success {
+		log.Info("Transaction lock time verification failed", txn.Hash())
+		return errCode
+	}
 	//verify transaction by pool with lock
 	if ok := this.verifyTransactionWithTxnPool(txn); !ok {
 		return ErrDoubleSpend
@@ -102,8 +114,21 @@ func (this *TXNPool) GetTransaction(hash common.Uint256) *transaction.Transactio
 
 //verify transaction with txnpool
 func (this *TXNPool) verifyTransactionWithTxnPool(txn *transaction.Transaction) bool {
+	// check if the transaction issues an asset issued by another one in the pool
+	if err := this.verifyIssueAsset(txn); err != nil {
+		log.Info(err)
+		return false
+	}
+	// check if the transaction deploys a contract deployed by another one in the pool
+	if err := this.verifyDeployCode(txn); err != nil {
+		this.delIssueAssetList(txn)
+		log.Info(err)
+		return false
+	}
 	// check if the transaction includes double spent UTXO inputs
 	if err := this.verifyDoubleSpend(txn); err != nil {
+		this.delIssueAssetList(txn)
+		this.delDeployCodeList(txn)
 		log.Info(err)
 		return false
 	}
@@ -115,6 +140,8 @@ func (this *TXNPool) verifyTransactionWithTxnPool(txn *transaction.Transaction)
 func (this *TXNPool) removeTransaction(txn *transaction.Transaction) {
 	//1.remove from txnList
 	this.deltxnList(txn)
+	this.delIssueAssetList(txn)
+	this.delDeployCodeList(txn)
 	//2.remove from UTXO list map
 	result, err := txn.GetReference()
 	if err != nil {
@@ -124,6 +151,9 @@ func (this *TXNPool) removeTransaction(txn *transaction.Transaction) {
 	for UTXOTxInput := range result {
 		this.delInputUTXOList(UTXOTxInput)
 	}
+	for _, balanceInput := range txn.BalanceInputs {
+		this.delInputBalanceList(balanceInput)
+	}
 }
 
 //check and add to utxo list pool
@@ -141,9 +171,66 @@ func (this *TXNPool) verifyDoubleSpend(txn *transaction.Transaction) error {
 		}
 		inputs = append(inputs, k)
 	}
+	// only one transaction in the pool may debit a balance
+	for _, input := range txn.BalanceInputs {
+		if txn := this.getInputBalanceList(input); txn != nil {
+			return errors.New(fmt.Sprintf("double spent balance inputs detected, "+
+				"transaction hash: %x, program hash: %s, asset: %s",
+				txn.Hash(), input.ProgramHash.String(), input.AssetID.String()))
+		}
+	}
 	for _, v := range inputs {
 		this.addInputUTXOList(txn, v)
 	}
+	for _, input := range txn.BalanceInputs {
+		this.addInputBalanceList(txn, input)
+	}
+
+	return nil
+}
+
+//check and add to issued asset list pool, the amount of an asset issued
+//by the pool can not exceed the amount it was registered with.
+func (this *TXNPool) verifyIssueAsset(txn *transaction.Transaction) error {
+	if txn.TxType != transaction.IssueAsset {
+		return nil
+	}
+	results, err := txn.GetTransactionResults()
+	if err != nil {
+		return err
+	}
+	assets := []common.Uint256{}
+	for assetID, amount := range results {
+		if amount >= 0 {
+			continue
+		}
+		if txn := this.getIssueAssetList(assetID); txn != nil {
+			return errors.New(fmt.Sprintf("asset %x already issued by transaction %x in the pool",
+				assetID.ToArrayReverse(), txn.Hash()))
+		}
+		assets = append(assets, assetID)
+	}
+	for _, assetID := range assets {
+		this.addIssueAssetList(txn, assetID)
+	}
+
+	return nil
+}
+
+//check and add to deployed contract list pool
+func (this *TXNPool) verifyDeployCode(txn *transaction.Transaction) error {
+	dc, ok := txn.Payload.(*payload.DeployCode)
+	if !ok {
+		return nil
+	}
+	codeHash, err := signature.ToProgramHash(dc.Code.Code)
+	if err != nil {
+		return err
+	}
+	if !this.addDeployCodeList(txn, codeHash) {
+		return errors.New(fmt.Sprintf("contract %x already deployed by a transaction in the pool",
+			codeHash.ToArrayReverse()))
+	}
 
 	return nil
 }
@@ -151,10 +238,15 @@ func (this *TXNPool) verifyDoubleSpend(txn *transaction.Transaction) error {
 //clean txnpool utxo map
 func (this *TXNPool) cleanUTXOList(txs []*transaction.Transaction) {
 	for _, txn := range txs {
+		this.delIssueAssetList(txn)
+		this.delDeployCodeList(txn)
 		inputUtxos, _ := txn.GetReference()
 		for Utxoinput, _ := range inputUtxos {
 			this.delInputUTXOList(Utxoinput)
 		}
+		for _, balanceInput := range txn.BalanceInputs {
+			this.delInputBalanceList(balanceInput)
+		}
 	}
 }
 
@@ -248,6 +340,87 @@ func (this *TXNPool) delInputUTXOList(input *transaction.UTXOTxInput) bool {
 	return true
 }
 
+func (this *TXNPool) getInputBalanceList(input *transaction.BalanceTxInput) *transaction.Transaction {
+	this.RLock()
+	defer this.RUnlock()
+	return this.inputBalanceList[input.ToString()]
+}
+
+func (this *TXNPool) addInputBalanceList(tx *transaction.Transaction, input *transaction.BalanceTxInput) bool {
+	this.Lock()
+	defer this.Unlock()
+	id := input.ToString()
+	_, ok := this.inputBalanceList[id]
+	if ok {
+		return false
+	}
+	this.inputBalanceList[id] = tx
+
+	return true
+}
+
+func (this *TXNPool) delInputBalanceList(input *transaction.BalanceTxInput) bool {
+	this.Lock()
+	defer this.Unlock()
+	id := input.ToString()
+	_, ok := this.inputBalanceList[id]
+	if !ok {
+		return false
+	}
+	delete(this.inputBalanceList, id)
+	return true
+}
+
+func (this *TXNPool) getIssueAssetList(assetID common.Uint256) *transaction.Transaction {
+	this.RLock()
+	defer this.RUnlock()
+	return this.issueAssetList[assetID]
+}
+
+func (this *TXNPool) addIssueAssetList(tx *transaction.Transaction, assetID common.Uint256) bool {
+	this.Lock()
+	defer this.Unlock()
+	_, ok := this.issueAssetList[assetID]
+	if ok {
+		return false
+	}
+	this.issueAssetList[assetID] = tx
+
+	return true
+}
+
+func (this *TXNPool) delIssueAssetList(tx *transaction.Transaction) {
+	this.Lock()
+	defer this.Unlock()
+	for assetID, issueTx := range this.issueAssetList {
+		if issueTx.Hash() == tx.Hash() {
+			delete(this.issueAssetList, assetID)
+		}
+	}
+}
+
+func (this *TXNPool) addDeployCodeList(tx *transaction.Transaction, codeHash common.Uint168) bool {
+	this.Lock()
+	defer this.Unlock()
+	_, ok := this.deployCodeList[codeHash]
+	if ok {
+		return false
+	}
+	this.deployCodeList[codeHash] = tx
+
+	return true
+}
+
+func (this *TXNPool) delDeployCodeList(tx *transaction.Transaction) {
+	this.Lock()
+	defer this.Unlock()
+	for codeHash, deployTx := range this.deployCodeList {
+		if deployTx.Hash() == tx.Hash() {
+			delete(this.deployCodeList, codeHash)
+		}
+	}
+}
+
 func (this *TXNPool) MaybeAcceptTransaction(txn *tx.Transaction) error {
 	txHash := txn.Hash()
 
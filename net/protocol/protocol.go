@@ -42,6 +42,15 @@ const (
 	MaxOutBoundCount  = 8
 	DefaultMaxPeers   = 125
 	MAXIDCACHED       = 5000
+	BLKREQWINDOW      = 512 // Blocks requested ahead of the best block in headers first sync
+	BLKREQTIMEOUT     = 10  // Seconds to wait for a requested block before asking another node
+	HDRREQTIMEOUT     = 30  // Seconds to wait for the header chain to grow before changing sync node
+)
+
+// The services supplied by a node, advertised in the version message
+const (
+	SFNodeHeaders        = 1 << 1 // Answers getheaders with headers messages
+	SFNodeNetworkLimited = 1 << 2 // Serves only the recent blocks, the older ones are pruned
 )
 
 // The node state
@@ -122,6 +131,7 @@ type Noder interface {
 	IsSyncFailed() bool
 	RequestedBlockExisted(hash common.Uint256) bool
 	AddRequestedBlock(hash common.Uint256)
+	RequestedBlockTime(hash common.Uint256) (time.Time, bool)
 	DeleteRequestedBlock(hash common.Uint256)
 	GetRequestBlockList() map[common.Uint256]time.Time
 	IsNeighborNoder(n Noder) bool
@@ -5,6 +5,7 @@ import (
 	"Elastos.ELA/common/log"
 	"Elastos.ELA/consensus/pow"
 	"Elastos.ELA/core/asset"
+	"Elastos.ELA/core/contract"
 	. "Elastos.ELA/core/transaction"
 	tx "Elastos.ELA/core/transaction"
 	"Elastos.ELA/core/transaction/payload"
@@ -139,6 +140,28 @@ type SideMiningInfo struct {
 	SideBlockHash string
 }
 
+type DeployCodeInfo struct {
+	Code           string
+	ParameterTypes string
+	Name           string
+	CodeVersion    string
+	Author         string
+	Email          string
+	Description    string
+}
+
+func deployCodeInfo(dc *payload.DeployCode) *DeployCodeInfo {
+	return &DeployCodeInfo{
+		Code:           BytesToHexString(dc.Code.Code),
+		ParameterTypes: BytesToHexString(contract.ContractParameterTypeToByte(dc.Code.ParameterTypes)),
+		Name:           dc.Name,
+		CodeVersion:    dc.CodeVersion,
+		Author:         dc.Author,
+		Email:          dc.Email,
+		Description:    dc.Description,
+	}
+}
+
 func TransPayloadToHex(p Payload) PayloadInfo {
 	switch object := p.(type) {
 	case *payload.CoinBase:
@@ -156,8 +179,10 @@ func TransPayloadToHex(p Payload) PayloadInfo {
 		obj.SideBlockHash = object.SideBlockHash.String()
 		return obj
 	case *payload.TransferAsset:
+	case *payload.IssueAsset:
 	case *payload.Record:
 	case *payload.DeployCode:
+		return deployCodeInfo(object)
 	}
 	return nil
 }
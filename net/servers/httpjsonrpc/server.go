@@ -2,6 +2,7 @@ package httpjsonrpc
 
 import (
 	"strconv"
+	"net"
 	"net/http"
 
 	"Elastos.ELA/common/log"
@@ -15,8 +16,12 @@ import (
 //an instance of the multiplexer
 var mainMux map[string]func(map[string]interface{}) map[string]interface{}
 
+// adminMux holds the methods only served to requests from localhost.
+var adminMux map[string]func(map[string]interface{}) map[string]interface{}
+
 func StartRPCServer() {
 	mainMux = make(map[string]func(map[string]interface{}) map[string]interface{})
+	adminMux = make(map[string]func(map[string]interface{}) map[string]interface{})
 
 	http.HandleFunc("/", Handle)
 
@@ -32,6 +37,12 @@ func StartRPCServer() {
 	mainMux["getnodestate"] = GetNodeState
 	mainMux["sendrawtransaction"] = SendRawTransaction
 	mainMux["submitblock"] = SubmitBlock
+	mainMux["getchaintips"] = GetChainTips
+	mainMux["getdeploymentinfo"] = GetDeploymentInfo
+	mainMux["getaddresshistory"] = GetAddressHistory
+	mainMux["gettxspender"] = GetTxSpender
+	mainMux["gettotalissued"] = GetTotalIssued
+	mainMux["getcontract"] = GetContract
 
 	// mining interfaces
 	mainMux["getinfo"] = GetInfo
@@ -41,6 +52,14 @@ func StartRPCServer() {
 	mainMux["togglemining"] = ToggleMining
 	mainMux["manualmining"] = ManualMining
 
+	// admin interfaces
+	adminMux["rollbackchain"] = RollbackChain
+	adminMux["invalidateblock"] = InvalidateBlock
+	adminMux["reconsiderblock"] = ReconsiderBlock
+	adminMux["gettxoutsetinfo"] = GetTxOutSetInfo
+	adminMux["dumptxoutset"] = DumpTxOutSet
+	adminMux["verifychain"] = VerifyChain
+
 	// TODO: only listen to localhost
 	err := http.ListenAndServe(":"+strconv.Itoa(Parameters.HttpJsonPort), nil)
 	if err != nil {
@@ -81,6 +100,10 @@ func Handle(w http.ResponseWriter, r *http.Request) {
 		Error(w, errors.InvalidMethod, method)
 		return
 	}
+	if _, admin := adminMux[request["method"].(string)]; admin && !isLoopback(r.RemoteAddr) {
+		Error(w, errors.MethodForbidden, request["method"])
+		return
+	}
 
 	var params map[string]interface{}
 
@@ -120,12 +143,24 @@ func checkMethod(request map[string]interface{}) (func(map[string]interface{}) m
 		return nil, method, false
 	}
 	function, ok := mainMux[request["method"].(string)]
+	if !ok {
+		function, ok = adminMux[request["method"].(string)]
+	}
 	if !ok {
 		return nil, method, false
 	}
 	return function, nil, true
 }
 
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func checkParams(request map[string]interface{}) (map[string]interface{}, bool) {
 	params := request["params"]
 	if params == nil {
@@ -142,7 +177,7 @@ func checkParams(request map[string]interface{}) (map[string]interface{}, bool)
 
 func Error(w http.ResponseWriter, code errors.ErrCode, method interface{}) {
 	//if the function does not exist
-	log.Warn("HTTP JSON RPC Handle - No function to call for ", method)
+	log.Warn("HTTP JSON RPC Handle - ", code.Message(), " for ", method)
 	data, _ := json.Marshal(map[string]interface{}{
 		"jsonpc": "2.0",
 		"code":   code,
@@ -26,10 +26,12 @@ const (
 	Api_GetTotalIssued     = "/api/v1/totalissued/:assetid"
 	Api_Gettransaction     = "/api/v1/transaction/:hash"
 	Api_Getasset           = "/api/v1/asset/:hash"
+	Api_GetContract        = "/api/v1/contract/:hash"
 	Api_GetBalanceByAddr   = "/api/v1/asset/balances/:addr"
 	Api_GetBalancebyAsset  = "/api/v1/asset/balance/:addr/:assetid"
 	Api_GetUTXObyAsset     = "/api/v1/asset/utxo/:addr/:assetid"
 	Api_GetUTXObyAddr      = "/api/v1/asset/utxos/:addr"
+	Api_GetAddressHistory  = "/api/v1/address/history/:addr"
 	Api_SendRawTransaction = "/api/v1/transaction"
 	Api_GetTransactionPool = "/api/v1/transactionpool"
 	Api_Restart            = "/api/v1/restart"
@@ -104,13 +106,16 @@ func (rt *restServer) initializeMethod() {
 		Api_Getblockbyhash:      {name: "getblockbyhash", handler: GetBlockByHash},
 		Api_Getblockheight:      {name: "getblockheight", handler: GetBlockHeight},
 		Api_Getblockhash:        {name: "getblockhash", handler: GetBlockHash},
+		Api_GetTotalIssued:      {name: "gettotalissued", handler: GetTotalIssued},
 		Api_GetTransactionPool:  {name: "gettransactionpool", handler: GetTransactionPool},
 		Api_Gettransaction:      {name: "gettransaction", handler: GetTransactionByHash},
 		Api_Getasset:            {name: "getasset", handler: GetAssetByHash},
+		Api_GetContract:         {name: "getcontract", handler: GetContract},
 		Api_GetUTXObyAddr:       {name: "getutxobyaddr", handler: GetUnspends},
 		Api_GetUTXObyAsset:      {name: "getutxobyasset", handler: GetUnspendOutput},
 		Api_GetBalanceByAddr:    {name: "getbalancebyaddr", handler: GetBalanceByAddr},
 		Api_GetBalancebyAsset:   {name: "getbalancebyasset", handler: GetBalanceByAsset},
+		Api_GetAddressHistory:   {name: "getaddresshistory", handler: GetAddressHistory},
 		Api_Restart:             {name: "restart", handler: rt.Restart},
 	}
 
@@ -145,6 +150,10 @@ func (rt *restServer) getPath(url string) string {
 		return Api_GetUTXObyAsset
 	} else if strings.Contains(url, strings.TrimRight(Api_Getasset, ":hash")) {
 		return Api_Getasset
+	} else if strings.Contains(url, strings.TrimRight(Api_GetAddressHistory, ":addr")) {
+		return Api_GetAddressHistory
+	} else if strings.Contains(url, strings.TrimRight(Api_GetContract, ":hash")) {
+		return Api_GetContract
 	}
 	return url
 }
@@ -178,6 +187,9 @@ func (rt *restServer) getParams(r *http.Request, url string, req map[string]inte
 	case Api_Getasset:
 		req["hash"] = getParam(r, "hash")
 
+	case Api_GetContract:
+		req["hash"] = getParam(r, "hash")
+
 	case Api_GetBalancebyAsset:
 		req["addr"] = getParam(r, "addr")
 		req["assetid"] = getParam(r, "assetid")
@@ -192,6 +204,11 @@ func (rt *restServer) getParams(r *http.Request, url string, req map[string]inte
 		req["addr"] = getParam(r, "addr")
 		req["assetid"] = getParam(r, "assetid")
 
+	case Api_GetAddressHistory:
+		req["addr"] = getParam(r, "addr")
+		req["skip"] = r.URL.Query().Get("skip")
+		req["count"] = r.URL.Query().Get("count")
+
 	case Api_Restart:
 
 	case Api_SendRawTransaction:
@@ -18,6 +18,9 @@ import (
 
 const (
 	AUXBLOCK_GENERATED_INTERVAL_SECONDS = 60
+
+	DefaultAddressHistoryCount = 50
+	MaxAddressHistoryCount     = 1000
 )
 
 var PreChainHeight uint64
@@ -427,6 +430,90 @@ func SubmitBlock(param map[string]interface{}) map[string]interface{} {
 	return ResponsePack(Success, "")
 }
 
+type ChainTipInfo struct {
+	Height    uint32
+	Hash      string
+	BranchLen uint32
+	Status    string
+}
+
+func GetChainTips(param map[string]interface{}) map[string]interface{} {
+	var tips []ChainTipInfo
+	for _, tip := range ledger.DefaultLedger.Blockchain.GetChainTips() {
+		tips = append(tips, ChainTipInfo{
+			Height:    tip.Height,
+			Hash:      BytesToHexString(tip.Hash.ToArrayReverse()),
+			BranchLen: tip.BranchLen,
+			Status:    tip.Status,
+		})
+	}
+	return ResponsePack(Success, tips)
+}
+
+// A JSON example for invalidateblock method as following:
+//   {"jsonrpc": "2.0", "method": "invalidateblock", "params": {"hash": "block hash in hex"}, "id": 0}
+func InvalidateBlock(param map[string]interface{}) map[string]interface{} {
+	if !checkParam(param, "hash") {
+		return ResponsePack(InvalidParams, "")
+	}
+	hex, err := HexStringToBytesReverse(param["hash"].(string))
+	if err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+	hash, err := Uint256ParseFromBytes(hex)
+	if err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+	if err := ledger.DefaultLedger.Blockchain.InvalidateBlock(hash); err != nil {
+		return ResponsePack(UnknownBlock, err.Error())
+	}
+
+	return ResponsePack(Success, "")
+}
+
+// A JSON example for reconsiderblock method as following:
+//   {"jsonrpc": "2.0", "method": "reconsiderblock", "params": {"hash": "block hash in hex"}, "id": 0}
+func ReconsiderBlock(param map[string]interface{}) map[string]interface{} {
+	if !checkParam(param, "hash") {
+		return ResponsePack(InvalidParams, "")
+	}
+	hex, err := HexStringToBytesReverse(param["hash"].(string))
+	if err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+	hash, err := Uint256ParseFromBytes(hex)
+	if err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+	if err := ledger.DefaultLedger.Blockchain.ReconsiderBlock(hash); err != nil {
+		return ResponsePack(UnknownBlock, err.Error())
+	}
+
+	return ResponsePack(Success, "")
+}
+
+type DeploymentInfo struct {
+	Name      string
+	Bit       uint8
+	StartTime int64
+	Timeout   int64
+	State     string
+}
+
+func GetDeploymentInfo(param map[string]interface{}) map[string]interface{} {
+	var deployments []DeploymentInfo
+	for _, d := range ledger.DefaultLedger.Blockchain.GetDeploymentInfo() {
+		deployments = append(deployments, DeploymentInfo{
+			Name:      d.Name,
+			Bit:       d.Bit,
+			StartTime: d.StartTime,
+			Timeout:   d.Timeout,
+			State:     d.State.String(),
+		})
+	}
+	return ResponsePack(Success, deployments)
+}
+
 func GetConnectionCount(param map[string]interface{}) map[string]interface{} {
 	return ResponsePack(Success, NodeForServers.GetConnectionCnt())
 }
@@ -494,6 +581,9 @@ func GetBlockInfo(block *ledger.Block) BlockInfo {
 
 func getBlock(hash Uint256) (interface{}, ErrCode) {
 	block, err := ledger.DefaultLedger.Store.GetBlock(hash)
+	if err == ledger.ErrBlockPruned {
+		return "", BlockPruned
+	}
 	if err != nil {
 		return "", UnknownBlock
 	}
@@ -603,6 +693,9 @@ func GetTransactionsByHeight(param map[string]interface{}) map[string]interface{
 
 	}
 	block, err := ledger.DefaultLedger.Store.GetBlock(hash)
+	if err == ledger.ErrBlockPruned {
+		return ResponsePack(BlockPruned, "")
+	}
 	if err != nil {
 		return ResponsePack(UnknownBlock, "")
 	}
@@ -655,6 +748,63 @@ func GetAssetByHash(param map[string]interface{}) map[string]interface{} {
 	return ResponsePack(Success, asset)
 }
 
+type ContractInfo struct {
+	Hash    string
+	Address string
+	*DeployCodeInfo
+}
+
+// A JSON example for getcontract method as following:
+//   {"jsonrpc": "2.0", "method": "getcontract", "params": {"hash": "program hash of the code"}, "id": 0}
+func GetContract(param map[string]interface{}) map[string]interface{} {
+	if !checkParam(param, "hash") {
+		return ResponsePack(InvalidParams, "")
+	}
+	hex, err := HexStringToBytesReverse(param["hash"].(string))
+	if err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+	codeHash, err := Uint168FromBytes(hex)
+	if err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+	dc, err := ledger.DefaultLedger.Store.GetContract(codeHash)
+	if err != nil {
+		return ResponsePack(UnknownContract, "")
+	}
+	address, err := codeHash.ToAddress()
+	if err != nil {
+		return ResponsePack(InternalError, "")
+	}
+	return ResponsePack(Success, ContractInfo{
+		Hash:           BytesToHexString(codeHash.ToArrayReverse()),
+		Address:        address,
+		DeployCodeInfo: deployCodeInfo(dc),
+	})
+}
+
+// A JSON example for gettotalissued method as following:
+//   {"jsonrpc": "2.0", "method": "gettotalissued", "params": {"assetid": "asset id in hex"}, "id": 0}
+// The result is the amount of the asset in unspent outputs.
+func GetTotalIssued(param map[string]interface{}) map[string]interface{} {
+	if !checkParam(param, "assetid") {
+		return ResponsePack(InvalidParams, "")
+	}
+	hex, err := HexStringToBytesReverse(param["assetid"].(string))
+	if err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+	assetID, err := Uint256ParseFromBytes(hex)
+	if err != nil {
+		return ResponsePack(InvalidAsset, "")
+	}
+	amount, err := ledger.DefaultLedger.Store.GetQuantityIssued(assetID)
+	if err != nil {
+		return ResponsePack(UnknownAsset, "")
+	}
+	return ResponsePack(Success, amount.String())
+}
+
 func GetBalanceByAddr(param map[string]interface{}) map[string]interface{} {
 	if !checkParam(param, "addr") {
 		return ResponsePack(InvalidParams, "")
@@ -695,9 +845,74 @@ func GetBalanceByAsset(param map[string]interface{}) map[string]interface{} {
 			}
 		}
 	}
+
+	// assets of the Balance record type are held as balances, not outputs
+	hex, err := HexStringToBytesReverse(param["assetid"].(string))
+	if err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+	assetID, err := Uint256ParseFromBytes(hex)
+	if err != nil {
+		return ResponsePack(InvalidAsset, "")
+	}
+	recorded, err := ledger.DefaultLedger.Store.GetBalance(programHash, assetID)
+	if err != nil {
+		return ResponsePack(InternalError, "")
+	}
+	balance = balance + recorded
 	return ResponsePack(Success, balance.String())
 }
 
+type AddressHistoryInfo struct {
+	Height    uint32
+	Txid      string
+	Direction string
+	AssetId   string
+	Amount    string
+}
+
+// A JSON example for getaddresshistory method as following:
+//   {"jsonrpc": "2.0", "method": "getaddresshistory", "params": {"addr": "address", "skip": "0", "count": "50"}, "id": 0}
+// skip and count are optional, the newest transactions come first.
+func GetAddressHistory(param map[string]interface{}) map[string]interface{} {
+	if !checkParam(param, "addr") {
+		return ResponsePack(InvalidParams, "")
+	}
+	programHash, err := Uint68FromAddress(param["addr"].(string))
+	if err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+
+	skip, count := 0, DefaultAddressHistoryCount
+	if value, ok := param["skip"].(string); ok && value != "" {
+		if skip, err = strconv.Atoi(value); err != nil || skip < 0 {
+			return ResponsePack(InvalidParams, "")
+		}
+	}
+	if value, ok := param["count"].(string); ok && value != "" {
+		count, err = strconv.Atoi(value)
+		if err != nil || count <= 0 || count > MaxAddressHistoryCount {
+			return ResponsePack(InvalidParams, "")
+		}
+	}
+
+	histories, err := ledger.DefaultLedger.Store.GetAddressHistory(programHash, skip, count)
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	results := make([]AddressHistoryInfo, 0, len(histories))
+	for _, h := range histories {
+		results = append(results, AddressHistoryInfo{
+			Height:    h.Height,
+			Txid:      BytesToHexString(h.TxID.ToArrayReverse()),
+			Direction: h.Direction.String(),
+			AssetId:   BytesToHexString(h.AssetID.ToArrayReverse()),
+			Amount:    h.Amount.String(),
+		})
+	}
+	return ResponsePack(Success, results)
+}
+
 func GetUnspends(param map[string]interface{}) map[string]interface{} {
 	if !checkParam(param, "addr") {
 		return ResponsePack(InvalidParams, "")
@@ -736,6 +951,154 @@ func GetUnspends(param map[string]interface{}) map[string]interface{} {
 	return ResponsePack(Success, results)
 }
 
+type TxOutSetInfo struct {
+	Height       uint32
+	BestBlock    string
+	Transactions uint64
+	TxOuts       uint64
+	Balances     uint64
+	Amounts      map[string]string
+	Hash         string
+}
+
+func txOutSetInfo(info *ledger.UTXOSetInfo) TxOutSetInfo {
+	amounts := make(map[string]string)
+	for assetID, amount := range info.Amounts {
+		amounts[BytesToHexString(assetID.ToArrayReverse())] = amount.String()
+	}
+	return TxOutSetInfo{
+		Height:       info.Height,
+		BestBlock:    BytesToHexString(info.BlockHash.ToArrayReverse()),
+		Transactions: info.Transactions,
+		TxOuts:       info.TxOuts,
+		Balances:     info.Balances,
+		Amounts:      amounts,
+		Hash:         BytesToHexString(info.Hash.ToArrayReverse()),
+	}
+}
+
+func GetTxOutSetInfo(param map[string]interface{}) map[string]interface{} {
+	info, err := ledger.DefaultLedger.Store.GetUTXOSetInfo()
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	return ResponsePack(Success, txOutSetInfo(info))
+}
+
+// A JSON example for dumptxoutset method as following:
+//   {"jsonrpc": "2.0", "method": "dumptxoutset", "params": {"path": "snapshot file path"}, "id": 0}
+// The file must not exist, it is loaded into an empty node with -loadtxoutset.
+func DumpTxOutSet(param map[string]interface{}) map[string]interface{} {
+	if !checkParam(param, "path") {
+		return ResponsePack(InvalidParams, "")
+	}
+	info, err := ledger.DefaultLedger.Store.DumpUTXOSet(param["path"].(string))
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	return ResponsePack(Success, txOutSetInfo(info))
+}
+
+// A JSON example for rollbackchain method as following:
+//   {"jsonrpc": "2.0", "method": "rollbackchain", "params": {"height": "1000"}, "id": 0}
+//   {"jsonrpc": "2.0", "method": "rollbackchain", "params": {"hash": "block hash in hex"}, "id": 0}
+// It is only served to requests from localhost, the result is the height after the rollback.
+func RollbackChain(param map[string]interface{}) map[string]interface{} {
+	bc := ledger.DefaultLedger.Blockchain
+	if value, ok := param["hash"].(string); ok && value != "" {
+		hex, err := HexStringToBytesReverse(value)
+		if err != nil {
+			return ResponsePack(InvalidParams, "")
+		}
+		hash, err := Uint256ParseFromBytes(hex)
+		if err != nil {
+			return ResponsePack(InvalidParams, "")
+		}
+		if err := bc.RollbackToBlock(hash); err != nil {
+			return ResponsePack(InternalError, err.Error())
+		}
+	} else if value, ok := param["height"].(string); ok && value != "" {
+		height, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return ResponsePack(InvalidParams, "")
+		}
+		if err := bc.RollbackTo(uint32(height)); err != nil {
+			return ResponsePack(InternalError, err.Error())
+		}
+	} else {
+		return ResponsePack(InvalidParams, "")
+	}
+
+	return ResponsePack(Success, bc.GetBestHeight())
+}
+
+type ChainInconsistencyInfo struct {
+	Key     string
+	Message string
+}
+
+// A JSON example for verifychain method as following:
+//   {"jsonrpc": "2.0", "method": "verifychain", "params": {"depth": "288"}, "id": 0}
+// Depth 0 or no depth checks every block, an empty result means the chain database is consistent.
+func VerifyChain(param map[string]interface{}) map[string]interface{} {
+	depth := 0
+	if value, ok := param["depth"].(string); ok && value != "" {
+		var err error
+		if depth, err = strconv.Atoi(value); err != nil || depth < 0 {
+			return ResponsePack(InvalidParams, "")
+		}
+	}
+
+	issues, err := ledger.DefaultLedger.Store.VerifyChain(uint32(depth))
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	results := make([]ChainInconsistencyInfo, 0, len(issues))
+	for _, issue := range issues {
+		results = append(results, ChainInconsistencyInfo{
+			Key:     BytesToHexString(issue.Key),
+			Message: issue.Message,
+		})
+	}
+	return ResponsePack(Success, results)
+}
+
+type TxSpenderInfo struct {
+	Txid   string
+	Index  uint16
+	Height uint32
+}
+
+// A JSON example for gettxspender method as following:
+//   {"jsonrpc": "2.0", "method": "gettxspender", "params": {"txid": "transaction hash in hex", "index": "0"}, "id": 0}
+func GetTxSpender(param map[string]interface{}) map[string]interface{} {
+	if !checkParam(param, "txid", "index") {
+		return ResponsePack(InvalidParams, "")
+	}
+	hex, err := HexStringToBytesReverse(param["txid"].(string))
+	if err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+	txid, err := Uint256ParseFromBytes(hex)
+	if err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+	index, err := strconv.ParseUint(param["index"].(string), 10, 16)
+	if err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+
+	spentBy, err := ledger.DefaultLedger.Store.GetSpendingTransaction(txid, uint16(index))
+	if err != nil {
+		return ResponsePack(UnknownTransaction, err.Error())
+	}
+	return ResponsePack(Success, TxSpenderInfo{
+		Txid:   BytesToHexString(spentBy.TxID.ToArrayReverse()),
+		Index:  spentBy.Index,
+		Height: spentBy.Height,
+	})
+}
+
 func GetUnspendOutput(param map[string]interface{}) map[string]interface{} {
 	if !checkParam(param, "addr", "assetid") {
 		return ResponsePack(InvalidParams, "")